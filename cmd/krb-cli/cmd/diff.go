@@ -0,0 +1,101 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wcrum/kube-recycle-bin/internal/restore"
+	"github.com/wcrum/kube-recycle-bin/pkg/logging"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type DiffFlags struct {
+	ToNamespace       string
+	Name              string
+	RewriteReferences bool
+}
+
+var diffFlags DiffFlags
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Diff recycled resource objects from RecycleItem against their live objects",
+	Long:  "Diff recycled resource objects from RecycleItem against the live objects of the same namespace and name, as a unified diff of what restoring them would change. Both are sanitized as they are on restore.",
+	Example: `
+# Diff the recycled resource object of RecycleItem foo against its live object
+krb-cli diff foo
+
+# Diff the recycled resource object of RecycleItem foo against the live object it would be restored over in namespace scratch
+krb-cli diff --to-namespace scratch foo
+`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runDiff(args)
+	},
+	ValidArgsFunction: completer.RecycleItem,
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringVarP(&diffFlags.ToNamespace, "to-namespace", "", "", "Diff against the live objects of the specified namespace instead of the one the objects were deleted from")
+	diffCmd.Flags().StringVarP(&diffFlags.Name, "name", "", "", "Diff against the live objects of the specified name instead of the one the objects were deleted with")
+	diffCmd.Flags().BoolVarP(&diffFlags.RewriteReferences, "rewrite-references", "", false, "Rewrite the labels, selectors and ConfigMap/Secret references holding the former name of the objects to the name specified by --name")
+
+	diffCmd.RegisterFlagCompletionFunc("to-namespace", completer.Namespace)
+}
+
+func runDiff(args []string) {
+	if len(args) == 0 {
+		fatal(nil, "please specify recycle items to diff")
+	}
+	if diffFlags.RewriteReferences && diffFlags.Name == "" {
+		fatal(nil, "--rewrite-references requires --name")
+	}
+	opts := restore.Options{
+		Namespace:         diffFlags.ToNamespace,
+		Name:              diffFlags.Name,
+		RewriteReferences: diffFlags.RewriteReferences,
+	}
+
+	clients := mustClients()
+	// diffs restore nothing, they record no Events.
+	restorer := restore.NewRestorer(clients, nil, restore.DefaultSanitizer())
+
+	ctx := context.Background()
+	for _, recycleItemName := range args {
+		recycleItem, err := clients.RecycleItem().Get(ctx, recycleItemName, client.GetOptions{})
+		if err != nil {
+			logging.Logger().Error(err, "failed to get RecycleItem, ignored", logging.KeyItem, recycleItemName)
+			continue
+		}
+
+		diff, err := restorer.Diff(ctx, recycleItem, opts)
+		if err != nil {
+			logging.Logger().Error(err, "failed to diff RecycleItem", logging.KeyItem, recycleItemName)
+			continue
+		}
+		if diff.Live != nil && diff.Unified == "" {
+			logging.Logger().Info("recycled object is identical to the live object", logging.KeyItem, recycleItemName, logging.KeyObject, recycleItem.Object.Key())
+			continue
+		}
+		fmt.Print(diff.Unified)
+	}
+}
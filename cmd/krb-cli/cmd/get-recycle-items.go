@@ -20,15 +20,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"time"
 
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/wcrum/kube-recycle-bin/internal/api"
-	krbclient "github.com/wcrum/kube-recycle-bin/internal/client"
-	"github.com/wcrum/kube-recycle-bin/internal/completion"
-	"github.com/wcrum/kube-recycle-bin/pkg/kube"
-	"github.com/wcrum/kube-recycle-bin/pkg/tlog"
+	"github.com/wcrum/kube-recycle-bin/pkg/logging"
+	"github.com/wcrum/kube-recycle-bin/pkg/util"
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/duration"
@@ -62,11 +61,14 @@ krb-cli get ri --object-resource deployments
 
 # Get RecycleItems recycled from dev namespace
 krb-cli get ri --object-namespace dev
+
+# Get RecycleItems with who deleted the objects and how
+krb-cli get ri -o wide
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		runGetRecycleItems(args)
 	},
-	ValidArgsFunction: completion.RecycleItem,
+	ValidArgsFunction: completer.RecycleItem,
 }
 
 func init() {
@@ -74,23 +76,24 @@ func init() {
 
 	getRecycleItemCmd.Flags().StringVarP(&getRecycleItemFlags.ObjectResource, "object-resource", "", "", "List recycled resource objects filtered by the specified object resource")
 	getRecycleItemCmd.Flags().StringVarP(&getRecycleItemFlags.ObjectNamespace, "object-namespace", "", "", "List recycled resource objects filtered by the specified object namespace")
-	getRecycleItemCmd.Flags().StringVarP(&getRecycleItemFlags.OutputFormat, "output", "o", "", "Output format. One of: json|yaml")
+	getRecycleItemCmd.Flags().StringVarP(&getRecycleItemFlags.OutputFormat, "output", "o", "", "Output format. One of: json|yaml|wide")
 
-	getRecycleItemCmd.RegisterFlagCompletionFunc("object-resource", completion.RecycleItemGroupResource)
-	getRecycleItemCmd.RegisterFlagCompletionFunc("object-namespace", completion.RecycleItemNamespace)
+	getRecycleItemCmd.RegisterFlagCompletionFunc("object-resource", completer.RecycleItemGroupResource)
+	getRecycleItemCmd.RegisterFlagCompletionFunc("object-namespace", completer.RecycleItemNamespace)
 	getRecycleItemCmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		return []string{"json", "yaml"}, cobra.ShellCompDirectiveNoFileComp
+		return []string{"json", "yaml", "wide"}, cobra.ShellCompDirectiveNoFileComp
 	})
 }
 
 func runGetRecycleItems(args []string) {
+	clients := mustClients()
 	var result api.RecycleItemList
 
 	if len(args) > 0 {
 		for _, name := range args {
-			obj, err := krbclient.RecycleItem().Get(context.Background(), name, client.GetOptions{})
+			obj, err := clients.RecycleItem().Get(context.Background(), name, client.GetOptions{})
 			if err != nil {
-				tlog.Errorf("✗ failed to get RecycleItem [%s]: %v, skipping.", name, err)
+				logging.Logger().Error(err, "failed to get RecycleItem, skipping", logging.KeyItem, name)
 				continue
 			}
 			result.Items = append(result.Items, *obj)
@@ -101,25 +104,25 @@ func runGetRecycleItems(args []string) {
 			labelSet["krb.wcrum.dev/object-namespace"] = getRecycleItemFlags.ObjectNamespace
 		}
 		if getRecycleItemFlags.ObjectResource != "" {
-			if gvr, err := kube.GetPreferredGroupVersionResourceFor(getRecycleItemFlags.ObjectResource); err != nil {
-				tlog.Panicf("✗ failed to get preferred group version resource: %v", err)
+			if gvr, err := clients.Discovery.GetPreferredGroupVersionResourceFor(getRecycleItemFlags.ObjectResource); err != nil {
+				fatal(err, "failed to get preferred group version resource")
 			} else {
 				labelSet["krb.wcrum.dev/object-gr"] = gvr.GroupResource().String()
 			}
 		}
 
-		list, err := krbclient.RecycleItem().List(context.Background(), client.ListOptions{
+		list, err := clients.RecycleItem().List(context.Background(), client.ListOptions{
 			LabelSelector: labels.SelectorFromSet(labelSet),
 		})
 		if err != nil {
-			tlog.Panicf("✗ failed to list RecycleItem: %v", err)
+			fatal(err, "failed to list RecycleItem")
 			return
 		}
 		result = *list
 	}
 
 	if len(result.Items) == 0 {
-		tlog.Println("No recycle items found.")
+		fmt.Println("No recycle items found.")
 		return
 	}
 
@@ -127,29 +130,38 @@ func runGetRecycleItems(args []string) {
 	case "yaml":
 		output, err := yaml.Marshal(result)
 		if err != nil {
-			tlog.Panicf("failed to marshal recycle items to yaml: %v", err)
+			fatal(err, "failed to marshal recycle items to yaml")
 		}
-		tlog.Print(string(output))
+		fmt.Print(string(output))
 	case "json":
 		y, err := yaml.Marshal(result)
 		if err != nil {
-			tlog.Panicf("failed to marshal recycle items to json: %v", err)
+			fatal(err, "failed to marshal recycle items to json")
 		}
 		j, err := yaml.YAMLToJSON(y)
 		if err != nil {
-			tlog.Panicf("failed to convert recycle items to json: %v", err)
+			fatal(err, "failed to convert recycle items to json")
 		}
 		var output bytes.Buffer
 		if err := json.Indent(&output, j, "", "  "); err != nil {
-			tlog.Panicf("failed to indent recycle items json: %v", err)
+			fatal(err, "failed to indent recycle items json")
 		}
-		tlog.Println(output.String())
+		fmt.Println(output.String())
 	default:
+		wide := getRecycleItemFlags.OutputFormat == "wide"
 		t := table.NewWriter()
 		t.SetOutputMirror(os.Stdout)
-		t.AppendHeader(table.Row{"Name", "Object Key", "Object APIVersion", "Object Kind", "Age"})
+		header := table.Row{"Name", "Object Key", "Object APIVersion", "Object Kind", "Phase", "Age"}
+		if wide {
+			header = append(header, "Deleted By", "Service Account", "Propagation", "Grace Period", "Request UID")
+		}
+		t.AppendHeader(header)
 		for _, obj := range result.Items {
-			t.AppendRow(table.Row{obj.Name, obj.Object.Key(), obj.Object.GroupVersion().String(), obj.Object.Kind, duration.HumanDuration(time.Since(obj.CreationTimestamp.Time))}, table.RowConfig{
+			row := table.Row{obj.Name, obj.Object.Key(), obj.Object.GroupVersion().String(), obj.Object.Kind, obj.Phase(), duration.HumanDuration(time.Since(obj.CreationTimestamp.Time))}
+			if wide {
+				row = append(row, deletionColumns(obj.Deletion)...)
+			}
+			t.AppendRow(row, table.RowConfig{
 				AutoMerge: true,
 			})
 		}
@@ -157,3 +169,22 @@ func runGetRecycleItems(args []string) {
 		t.Render()
 	}
 }
+
+// deletionColumns returns the wide output columns describing who deleted a recycled object and how.
+func deletionColumns(deletion *api.RecycleDeletion) table.Row {
+	if deletion == nil {
+		return table.Row{"<unknown>", "<none>", "<none>", "<none>", "<none>"}
+	}
+	row := table.Row{util.If(deletion.DeletedBy.Username != "", deletion.DeletedBy.Username, "<unknown>"), util.If(deletion.DeletedBy.ServiceAccount != "", deletion.DeletedBy.ServiceAccount, "<none>")}
+	if deletion.PropagationPolicy != nil {
+		row = append(row, string(*deletion.PropagationPolicy))
+	} else {
+		row = append(row, "<none>")
+	}
+	if deletion.GracePeriodSeconds != nil {
+		row = append(row, fmt.Sprintf("%ds", *deletion.GracePeriodSeconds))
+	} else {
+		row = append(row, "<none>")
+	}
+	return append(row, util.If(deletion.RequestUID != "", string(deletion.RequestUID), "<none>"))
+}
@@ -20,18 +20,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/wcrum/kube-recycle-bin/internal/api"
-	krbclient "github.com/wcrum/kube-recycle-bin/internal/client"
-	"github.com/wcrum/kube-recycle-bin/internal/completion"
-	"github.com/wcrum/kube-recycle-bin/pkg/kube"
-	"github.com/wcrum/kube-recycle-bin/pkg/tlog"
+	"github.com/wcrum/kube-recycle-bin/pkg/logging"
 	"github.com/spf13/cobra"
-	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/duration"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
@@ -67,7 +66,7 @@ krb-cli get recyclepolicies --target-namespace default
 	Run: func(cmd *cobra.Command, args []string) {
 		runGetRecyclePolicies(args)
 	},
-	ValidArgsFunction: completion.RecyclePolicy,
+	ValidArgsFunction: completer.RecyclePolicy,
 }
 
 func init() {
@@ -77,46 +76,53 @@ func init() {
 	getRecyclePoliciesCmd.Flags().StringVarP(&getRecyclePoliciesFlags.TargetNamespace, "target-namespace", "", "", "List recycle policies filtered by the specified target namespace")
 	getRecyclePoliciesCmd.Flags().StringVarP(&getRecyclePoliciesFlags.OutputFormat, "output", "o", "", "Output format. One of: json|yaml")
 
-	getRecyclePoliciesCmd.RegisterFlagCompletionFunc("target-resource", completion.RecyclePolicyGroupResource)
-	getRecyclePoliciesCmd.RegisterFlagCompletionFunc("target-namespace", completion.RecyclePolicyNamespace)
+	getRecyclePoliciesCmd.RegisterFlagCompletionFunc("target-resource", completer.RecyclePolicyGroupResource)
+	getRecyclePoliciesCmd.RegisterFlagCompletionFunc("target-namespace", completer.RecyclePolicyNamespace)
 	getRecyclePoliciesCmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return []string{"json", "yaml"}, cobra.ShellCompDirectiveDefault
 	})
 }
 
 func runGetRecyclePolicies(args []string) {
+	clients := mustClients()
 	var result api.RecyclePolicyList
 	if len(args) > 0 {
 		for _, name := range args {
-			obj, err := krbclient.RecyclePolicy().Get(context.Background(), name, client.GetOptions{})
+			obj, err := clients.RecyclePolicy().Get(context.Background(), name, client.GetOptions{})
 			if err != nil {
-				tlog.Errorf("✗ failed to get RecyclePolicy [%s]: %v, ignored.", name, err)
+				logging.Logger().Error(err, "failed to get RecyclePolicy, ignored", logging.KeyPolicy, name)
 				continue
 			}
 			result.Items = append(result.Items, *obj)
 		}
 	} else {
-		labelSet := labels.Set{}
+		var targetGR *schema.GroupResource
 		if getRecyclePoliciesFlags.TargetResource != "" {
-			if gvr, err := kube.GetPreferredGroupVersionResourceFor(getRecyclePoliciesFlags.TargetResource); err != nil {
-				tlog.Panicf("✗ failed to get preferred group version resource: %v", err)
+			if gvr, err := clients.Discovery.GetPreferredGroupVersionResourceFor(getRecyclePoliciesFlags.TargetResource); err != nil {
+				fatal(err, "failed to get preferred group version resource")
 			} else {
-				labelSet["krb.wcrum.dev/target-gr"] = gvr.GroupResource().String()
+				gr := gvr.GroupResource()
+				targetGR = &gr
 			}
 		}
-		list, err := krbclient.RecyclePolicy().List(context.Background(), client.ListOptions{
-			LabelSelector: labels.SelectorFromSet(labelSet),
-			Namespace:     getRecyclePoliciesFlags.TargetNamespace,
+		list, err := clients.RecyclePolicy().List(context.Background(), client.ListOptions{
+			Namespace: getRecyclePoliciesFlags.TargetNamespace,
 		})
 		if err != nil {
-			tlog.Panicf("✗ failed to list RecyclePolicy: %v", err)
+			fatal(err, "failed to list RecyclePolicy")
 			return
 		}
-		result = *list
+		// filter on the target rather than on the krb.wcrum.dev/target-gr-* labels,
+		// policies created before a policy could target several resources are labelled differently.
+		for _, obj := range list.Items {
+			if targetGR == nil || slices.Contains(obj.Target.GroupResources(), *targetGR) {
+				result.Items = append(result.Items, obj)
+			}
+		}
 	}
 
 	if len(result.Items) == 0 {
-		tlog.Println("No recycle items found.")
+		fmt.Println("No recycle items found.")
 		return
 	}
 
@@ -124,30 +130,34 @@ func runGetRecyclePolicies(args []string) {
 	case "yaml":
 		output, err := yaml.Marshal(result)
 		if err != nil {
-			tlog.Panicf("failed to marshal recycle policies to yaml: %v", err)
+			fatal(err, "failed to marshal recycle policies to yaml")
 		}
-		tlog.Print(string(output))
+		fmt.Print(string(output))
 	case "json":
 		y, err := yaml.Marshal(result)
 		if err != nil {
-			tlog.Panicf("failed to marshal recycle policies to json: %v", err)
+			fatal(err, "failed to marshal recycle policies to json")
 		}
 		j, err := yaml.YAMLToJSON(y)
 		if err != nil {
-			tlog.Panicf("failed to convert recycle policies to json: %v", err)
+			fatal(err, "failed to convert recycle policies to json")
 		}
 		var output bytes.Buffer
 		if err := json.Indent(&output, j, "", "  "); err != nil {
-			tlog.Panicf("failed to indent recycle policies json: %v", err)
+			fatal(err, "failed to indent recycle policies json")
 		}
-		tlog.Println(output.String())
+		fmt.Println(output.String())
 	default:
 		t := table.NewWriter()
 		t.SetOutputMirror(os.Stdout)
-		t.AppendHeader(table.Row{"Name", "Target GR", "Target Namespaces", "Age"})
+		t.AppendHeader(table.Row{"Name", "Target GR", "Target Namespaces", "Webhook", "Items", "Last Recycle", "Age"})
 
 		for _, obj := range result.Items {
-			t.AppendRow(table.Row{obj.Name, obj.Target.GroupResource().String(), strings.Join(obj.Target.Namespaces, ","), duration.HumanDuration(time.Since(obj.CreationTimestamp.Time))}, table.RowConfig{
+			lastRecycle := "<none>"
+			if obj.Status.LastRecycleTime != nil {
+				lastRecycle = duration.HumanDuration(time.Since(obj.Status.LastRecycleTime.Time))
+			}
+			t.AppendRow(table.Row{obj.Name, formatGroupResources(obj.Target.GroupResources()), strings.Join(obj.Target.Namespaces, ","), obj.ConditionStatus(api.RecyclePolicyConditionWebhookConfigured), obj.Status.ItemCount, lastRecycle, duration.HumanDuration(time.Since(obj.CreationTimestamp.Time))}, table.RowConfig{
 				AutoMerge: true,
 			})
 		}
@@ -156,3 +166,11 @@ func runGetRecyclePolicies(args []string) {
 	}
 
 }
+
+func formatGroupResources(grs []schema.GroupResource) string {
+	result := make([]string, 0, len(grs))
+	for _, gr := range grs {
+		result = append(result, gr.String())
+	}
+	return strings.Join(result, ",")
+}
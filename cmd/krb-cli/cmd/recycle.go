@@ -18,18 +18,25 @@ package cmd
 
 import (
 	"context"
+	"time"
 
 	"github.com/wcrum/kube-recycle-bin/internal/api"
-	krbclient "github.com/wcrum/kube-recycle-bin/internal/client"
-	"github.com/wcrum/kube-recycle-bin/internal/completion"
-	"github.com/wcrum/kube-recycle-bin/pkg/kube"
-	"github.com/wcrum/kube-recycle-bin/pkg/tlog"
+	"github.com/wcrum/kube-recycle-bin/pkg/logging"
 	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type RecycleFlags struct {
-	TargetNamespaces []string
+	Name              string
+	TargetNamespaces  []string
+	NamespaceSelector string
+	ExcludeNamespaces []string
+	Selector          string
+	TTL               time.Duration
+	MaxItems          int32
+	OnRecycleFailure  string
 }
 
 var recycleFlags RecycleFlags
@@ -38,47 +45,117 @@ var recycleFlags RecycleFlags
 var recycleCmd = &cobra.Command{
 	Use:   "recycle",
 	Short: "Recycle specified resources",
-	Long:  `Recycle specified resources. This command creates a RecyclePolicy for the specified resource type.`,
+	Long:  `Recycle specified resources. This command creates one RecyclePolicy targeting all the specified resource types.`,
 	Example: `# Recycle Deployment in dev and prod namespace
 krb-cli recycle deployments -n dev,prod
 
+# Recycle the resources of an app with a single policy
+krb-cli recycle deployments services configmaps secrets ingresses -n my-app --name protect-my-app
+
 # Recycle service in all namespaces
 krb-cli recycle services
+
+# Recycle secrets in namespaces labelled env=prod, except prod-sandbox
+krb-cli recycle secrets --namespace-selector env=prod --exclude-namespaces prod-sandbox
+
+# Recycle deployments labelled team=payments in all namespaces
+krb-cli recycle deployments --selector team=payments
+
+# Recycle secrets in prod and refuse to delete them if they cannot be recycled
+krb-cli recycle secrets -n prod --on-recycle-failure Deny
+
+# Recycle configmaps and keep recycled objects for 7 days, at most 100 of them
+krb-cli recycle configmaps --ttl 168h --max-items 100
 `,
 	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		runRecycle(args)
 	},
-	ValidArgsFunction: completion.KubeGroupResources,
+	ValidArgsFunction: completer.KubeGroupResources,
 }
 
 func init() {
 	rootCmd.AddCommand(recycleCmd)
 
+	recycleCmd.Flags().StringVar(&recycleFlags.Name, "name", "", "Name of the created RecyclePolicy, generated if not set")
 	recycleCmd.Flags().StringSliceVarP(&recycleFlags.TargetNamespaces, "target-namespaces", "n", []string{}, "Create a RecyclePolicy with specific target namespaces")
+	recycleCmd.Flags().StringVar(&recycleFlags.NamespaceSelector, "namespace-selector", "", "Create a RecyclePolicy recycling only from namespaces matching the label selector, such as env=prod")
+	recycleCmd.Flags().StringSliceVar(&recycleFlags.ExcludeNamespaces, "exclude-namespaces", []string{}, "Create a RecyclePolicy never recycling from the specified namespaces, krb-system is always excluded")
+	recycleCmd.Flags().StringVarP(&recycleFlags.Selector, "selector", "l", "", "Create a RecyclePolicy recycling only objects matching the label selector, such as team=payments,tier!=cache")
+	recycleCmd.Flags().DurationVar(&recycleFlags.TTL, "ttl", 0, "Time recycled objects are kept before they are garbage-collected, kept forever if not set")
+	recycleCmd.Flags().StringVar(&recycleFlags.OnRecycleFailure, "on-recycle-failure", string(api.RecycleFailureAllow), "What happens to a delete when the object cannot be recycled. One of: Allow|Deny")
+	recycleCmd.Flags().Int32Var(&recycleFlags.MaxItems, "max-items", 0, "Maximum number of recycled objects kept for the RecyclePolicy, unlimited if not set")
+
+	recycleCmd.RegisterFlagCompletionFunc("on-recycle-failure", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{string(api.RecycleFailureAllow), string(api.RecycleFailureDeny)}, cobra.ShellCompDirectiveNoFileComp
+	})
 }
 
 func runRecycle(args []string) {
 	if len(args) == 0 {
-		tlog.Panicf("✗ please specify a resource to recycle.")
+		fatal(nil, "please specify a resource to recycle")
+	}
+
+	onRecycleFailure := api.RecycleFailurePolicy(recycleFlags.OnRecycleFailure)
+	if onRecycleFailure != api.RecycleFailureAllow && onRecycleFailure != api.RecycleFailureDeny {
+		fatal(nil, "invalid --on-recycle-failure, must be one of: Allow|Deny", "onRecycleFailure", recycleFlags.OnRecycleFailure)
+	}
+
+	var objectSelector *metav1.LabelSelector
+	if recycleFlags.Selector != "" {
+		var err error
+		objectSelector, err = metav1.ParseToLabelSelector(recycleFlags.Selector)
+		if err != nil {
+			fatal(err, "invalid label selector", "selector", recycleFlags.Selector)
+		}
+	}
+
+	var namespaceSelector *metav1.LabelSelector
+	if recycleFlags.NamespaceSelector != "" {
+		var err error
+		namespaceSelector, err = metav1.ParseToLabelSelector(recycleFlags.NamespaceSelector)
+		if err != nil {
+			fatal(err, "invalid namespace label selector", "namespaceSelector", recycleFlags.NamespaceSelector)
+		}
 	}
 
+	clients := mustClients()
+	var gvrs []schema.GroupVersionResource
 	for _, resource := range args {
-		gvr, err := kube.GetPreferredGroupVersionResourceFor(resource)
+		gvr, err := clients.Discovery.GetPreferredGroupVersionResourceFor(resource)
 		if err != nil {
-			tlog.Errorf("✗ failed to get gvr from resource name: %v, ignored.", err)
+			logging.Logger().Error(err, "failed to get gvr from resource name, ignored", "resource", resource)
 			continue
 		}
 		if gvr == nil {
-			tlog.Errorf("✗ no resources found for %s, ignored.", resource)
+			logging.Logger().Error(nil, "no resources found, ignored", "resource", resource)
 			continue
 		}
+		gvrs = append(gvrs, *gvr)
+	}
+	if len(gvrs) == 0 {
+		fatal(nil, "no resources to recycle")
+	}
 
-		recycleItem := api.NewRecyclePolicy(*gvr, recycleFlags.TargetNamespaces)
-		if err := krbclient.RecyclePolicy().Create(context.Background(), recycleItem, client.CreateOptions{}); err != nil {
-			tlog.Panicf("✗ failed to create recycle policy: %v, ignored.", err)
-			continue
+	recyclePolicy := api.NewRecyclePolicy(gvrs, recycleFlags.TargetNamespaces)
+	if recycleFlags.Name != "" {
+		recyclePolicy.Name = recycleFlags.Name
+	}
+	recyclePolicy.Target.ObjectSelector = objectSelector
+	recyclePolicy.Target.NamespaceSelector = namespaceSelector
+	recyclePolicy.Target.ExcludeNamespaces = recycleFlags.ExcludeNamespaces
+	recyclePolicy.OnRecycleFailure = onRecycleFailure
+	if recycleFlags.TTL > 0 || recycleFlags.MaxItems > 0 {
+		recyclePolicy.Retention = &api.RecycleRetention{}
+		if recycleFlags.TTL > 0 {
+			recyclePolicy.Retention.TTL = &metav1.Duration{Duration: recycleFlags.TTL}
 		}
-		tlog.Printf("✓ create recycle policy [%s] done.", recycleItem.Name)
+		if recycleFlags.MaxItems > 0 {
+			recyclePolicy.Retention.MaxItems = &recycleFlags.MaxItems
+		}
+	}
+	if err := clients.RecyclePolicy().Create(context.Background(), recyclePolicy, client.CreateOptions{}); err != nil {
+		fatal(err, "failed to create recycle policy")
 	}
+	logging.Logger().Info("created RecyclePolicy", logging.KeyPolicy, recyclePolicy.Name)
 }
@@ -18,19 +18,29 @@ package cmd
 
 import (
 	"context"
-
-	krbclient "github.com/wcrum/kube-recycle-bin/internal/client"
-	"github.com/wcrum/kube-recycle-bin/internal/completion"
-	"github.com/wcrum/kube-recycle-bin/pkg/kube"
-	"github.com/wcrum/kube-recycle-bin/pkg/tlog"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+	"github.com/wcrum/kube-recycle-bin/internal/event"
+	"github.com/wcrum/kube-recycle-bin/internal/restore"
+	"github.com/wcrum/kube-recycle-bin/pkg/logging"
 	"github.com/spf13/cobra"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
 )
 
 type RestoreFlags struct {
-	ObjectResource  string
-	ObjectNamespace string
+	ObjectResource    string
+	ObjectNamespace   string
+	ToNamespace       string
+	Name              string
+	RewriteReferences bool
+	OnConflict        string
+	DryRun            bool
 }
 
 var restoreFlags RestoreFlags
@@ -49,12 +59,25 @@ krb-cli restore --object-resource deployments foo
 
 # Restore RecycleItem deployments foo-deploy, service foo-svc and filter by object namespace dev
 krb-cli restore --object-namespace dev foo-deploy foo-svc
+
+# Restore RecycleItem foo-deploy into namespace scratch for inspection
+krb-cli restore --to-namespace scratch foo-deploy
+
+# Restore RecycleItem foo-deploy, overwriting the deployment foo if it was re-created
+krb-cli restore --on-conflict overwrite foo-deploy
+
+# Print the manifest RecycleItem foo-deploy would be restored with, validated by the api server
+krb-cli restore --dry-run foo-deploy
+
+# Restore RecycleItems foo-deploy and foo-cm as foo-restored next to the re-created foo, with
+# the labels, selectors and ConfigMap references of the deployment rewritten to foo-restored
+krb-cli restore --name foo-restored --rewrite-references foo-deploy foo-cm
 `,
 
 	Run: func(cmd *cobra.Command, args []string) {
 		runRestore(args)
 	},
-	ValidArgsFunction: completion.RecycleItem,
+	ValidArgsFunction: completer.RecycleItem,
 }
 
 func init() {
@@ -63,38 +86,88 @@ func init() {
 	restoreCmd.Flags().StringVarP(&restoreFlags.ObjectResource, "object-resource", "", "", "Restore recycled resource objects filtered by the specified object resource")
 	restoreCmd.Flags().StringVarP(&restoreFlags.ObjectNamespace, "object-namespace", "", "", "Restore recycled resource objects filtered by the specified object namespace")
 
-	restoreCmd.RegisterFlagCompletionFunc("object-resource", completion.RecycleItemGroupResource)
-	restoreCmd.RegisterFlagCompletionFunc("object-namespace", completion.RecycleItemNamespace)
+	restoreCmd.Flags().StringVarP(&restoreFlags.ToNamespace, "to-namespace", "", "", "Restore namespaced resource objects into the specified namespace instead of the one they were deleted from")
+	restoreCmd.Flags().StringVarP(&restoreFlags.Name, "name", "", "", "Restore resource objects under the specified name instead of the one they were deleted with")
+	restoreCmd.Flags().BoolVarP(&restoreFlags.RewriteReferences, "rewrite-references", "", false, "Rewrite the labels, selectors and ConfigMap/Secret references holding the former name of the restored resource objects to the name specified by --name")
+	restoreCmd.Flags().BoolVar(&restoreFlags.DryRun, "dry-run", false, "Run the restore on the api server without persisting anything, and print the manifests that would be restored")
+	restoreCmd.Flags().StringVar(&restoreFlags.OnConflict, "on-conflict", string(restore.ConflictFail), "What happens when an object of the same name exists. One of: fail|skip|overwrite|rename")
+
+	restoreCmd.RegisterFlagCompletionFunc("object-resource", completer.RecycleItemGroupResource)
+	restoreCmd.RegisterFlagCompletionFunc("object-namespace", completer.RecycleItemNamespace)
+	restoreCmd.RegisterFlagCompletionFunc("to-namespace", completer.Namespace)
+	restoreCmd.RegisterFlagCompletionFunc("on-conflict", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		var result []string
+		for _, strategy := range restore.ConflictStrategies {
+			result = append(result, string(strategy))
+		}
+		return result, cobra.ShellCompDirectiveNoFileComp
+	})
 }
 
 func runRestore(args []string) {
 	if len(args) == 0 {
-		tlog.Panicf("✗ please specify recycle items to restore.")
+		fatal(nil, "please specify recycle items to restore")
+	}
+	if restoreFlags.RewriteReferences && restoreFlags.Name == "" {
+		fatal(nil, "--rewrite-references requires --name")
+	}
+	onConflict := restore.ConflictStrategy(restoreFlags.OnConflict)
+	if err := onConflict.Validate(); err != nil {
+		fatal(err, "invalid --on-conflict")
+	}
+	opts := restore.Options{
+		Namespace:         restoreFlags.ToNamespace,
+		Name:              restoreFlags.Name,
+		RewriteReferences: restoreFlags.RewriteReferences,
+		OnConflict:        onConflict,
+		DryRun:            restoreFlags.DryRun,
 	}
 
+	clients := mustClients()
+	recorder := event.NewRecorder(clients.Kubernetes, "krb-cli")
+	// events are written in the background, give them a chance before exiting.
+	defer recorder.Flush(5 * time.Second)
+	restorer := restore.NewRestorer(clients, recorder, restore.DefaultSanitizer())
+
+	ctx := logging.IntoContext(context.Background(), logging.Logger())
+	firstOutput := true
 	for _, recycleItemName := range args {
-		recycleItem, err := krbclient.RecycleItem().Get(context.Background(), recycleItemName, client.GetOptions{})
+		recycleItem, err := clients.RecycleItem().Get(ctx, recycleItemName, client.GetOptions{})
 		if err != nil {
-			tlog.Printf("✗ failed to get RecycleItem [%s]: %v, ignored.", recycleItemName, err)
+			logging.Logger().Error(err, "failed to get RecycleItem, ignored", logging.KeyItem, recycleItemName)
 			continue
 		}
 
-		unstructuredObj, err := recycleItem.Object.Unstructured()
-		if err != nil {
-			tlog.Printf("✗ failed to get unstructured object from RecycleItem [%s]: %v, ignored.", recycleItemName, err)
-			continue
+		result, err := restorer.Restore(ctx, recycleItem, opts)
+		if errors.Is(err, restore.ErrAlreadyRestored) {
+			logging.Logger().Error(nil, "RecycleItem was already restored, ignored", logging.KeyItem, recycleItemName)
+		} else if err != nil {
+			logging.Logger().Error(err, "failed to restore RecycleItem", logging.KeyItem, recycleItemName)
+		} else if opts.DryRun {
+			printDryRun(recycleItem, result, &firstOutput)
 		}
+	}
+}
 
-		if _, err := kube.DynamicClient().Resource(recycleItem.Object.GroupVersionResource()).Namespace(recycleItem.Object.Namespace).Create(context.Background(), unstructuredObj, metav1.CreateOptions{}); err != nil {
-			tlog.Printf("✗ failed to restore recycled resource object [%s]: %v", recycleItem.Object.Key(), err)
-		} else {
-			tlog.Printf("✓ restored recycled resource object [%s: %s] done.", recycleItem.Object.GroupResource().String(), recycleItem.Object.Key())
-			// delete the recycle item after successful restore
-			if err := krbclient.RecycleItem().Delete(context.Background(), recycleItemName, client.DeleteOptions{}); err != nil {
-				tlog.Printf("✗ failed to automatically delete RecycleItem [%s] after restore: %v", recycleItemName, err)
-			} else {
-				tlog.Printf("✓ automatically deleted RecycleItem [%s] after restore.", recycleItemName)
-			}
-		}
+// printDryRun prints the manifest a dry run restored the RecycleItem with, as YAML documents.
+func printDryRun(recycleItem *api.RecycleItem, result *restore.Result, firstOutput *bool) {
+	if *firstOutput {
+		*firstOutput = false
+	} else {
+		fmt.Println("---")
+	}
+	if result.Outcome == restore.OutcomeSkipped {
+		fmt.Printf("# %s: skipped, an object of the same name exists (dry run)\n", recycleItem.Name)
+		return
+	}
+	manifest, err := yaml.Marshal(result.Manifest.Object)
+	if err != nil {
+		logging.Logger().Error(err, "failed to print restored manifest", logging.KeyItem, recycleItem.Name)
+		return
+	}
+	fmt.Printf("# %s: %s %s (dry run)\n", recycleItem.Name, strings.ToLower(string(result.Outcome)), cache.MetaObjectToName(result.Manifest))
+	if len(result.DroppedFields) > 0 {
+		fmt.Printf("# dropped fields not in %s: %s\n", result.Manifest.GetAPIVersion(), strings.Join(result.DroppedFields, ", "))
 	}
+	fmt.Print(string(manifest))
 }
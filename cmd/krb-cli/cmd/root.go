@@ -17,11 +17,33 @@ limitations under the License.
 package cmd
 
 import (
+	"flag"
 	"os"
+	"sync"
 
+	krbclient "github.com/wcrum/kube-recycle-bin/internal/client"
+	"github.com/wcrum/kube-recycle-bin/internal/completion"
+	"github.com/wcrum/kube-recycle-bin/pkg/kube"
+	"github.com/wcrum/kube-recycle-bin/pkg/logging"
 	"github.com/spf13/cobra"
 )
 
+// logOptions are set by the --log-format and --v flags of all commands.
+var logOptions = logging.Options{Format: logging.FormatConsole}
+
+// kubeClients returns the clients of the cluster of the kubeconfig, created on first use so
+// commands and completions not reading the cluster work without one.
+var kubeClients = sync.OnceValues(func() (*krbclient.Clients, error) {
+	config, err := kube.RestConfig()
+	if err != nil {
+		return nil, err
+	}
+	return krbclient.NewClients(config)
+})
+
+// completer completes the arguments and flags of the commands.
+var completer = completion.New(kubeClients)
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "krb-cli",
@@ -36,6 +58,9 @@ var rootCmd = &cobra.Command{
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		logging.Setup(logOptions)
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -47,4 +72,23 @@ func Execute() {
 	}
 }
 
-func init() {}
+func init() {
+	logFlags := flag.NewFlagSet("log", flag.ContinueOnError)
+	logOptions.AddFlags(logFlags)
+	rootCmd.PersistentFlags().AddGoFlagSet(logFlags)
+}
+
+// mustClients returns the clients of the cluster of the kubeconfig, and exits if there are none.
+func mustClients() *krbclient.Clients {
+	clients, err := kubeClients()
+	if err != nil {
+		fatal(err, "failed to create clients")
+	}
+	return clients
+}
+
+// fatal logs the error a command cannot go on with and exits.
+func fatal(err error, msg string, keysAndValues ...any) {
+	logging.Logger().Error(err, msg, keysAndValues...)
+	os.Exit(1)
+}
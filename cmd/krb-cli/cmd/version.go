@@ -17,8 +17,9 @@ limitations under the License.
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/wcrum/kube-recycle-bin/internal/completion"
-	"github.com/wcrum/kube-recycle-bin/pkg/tlog"
 	"github.com/spf13/cobra"
 )
 
@@ -30,7 +31,7 @@ var versionCmd = &cobra.Command{
 	Aliases: []string{"v"},
 	Short:   "Print the version number of krb-cli",
 	Run: func(cmd *cobra.Command, args []string) {
-		tlog.Printf("✓ Version: %s", Version)
+		fmt.Printf("Version: %s\n", Version)
 	},
 	ValidArgsFunction: completion.None,
 }
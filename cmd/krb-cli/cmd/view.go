@@ -18,10 +18,12 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
-	krbclient "github.com/wcrum/kube-recycle-bin/internal/client"
-	"github.com/wcrum/kube-recycle-bin/internal/completion"
-	"github.com/wcrum/kube-recycle-bin/pkg/tlog"
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+	"github.com/wcrum/kube-recycle-bin/pkg/logging"
+	"github.com/wcrum/kube-recycle-bin/pkg/util"
 	"github.com/spf13/cobra"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -55,7 +57,7 @@ krb-cli view foo bar --output json
 	Run: func(cmd *cobra.Command, args []string) {
 		runView(args)
 	},
-	ValidArgsFunction: completion.RecycleItem,
+	ValidArgsFunction: completer.RecycleItem,
 }
 
 func init() {
@@ -65,8 +67,8 @@ func init() {
 	viewCmd.Flags().StringVarP(&viewFlags.ObjectNamespace, "object-namespace", "", "", "View recycled resource objects filtered by the specified object namespace")
 	viewCmd.Flags().StringVarP(&viewFlags.OutputFormat, "output", "o", "yaml", "Output format. One of: json|yaml, default is yaml")
 
-	viewCmd.RegisterFlagCompletionFunc("object-resource", completion.RecycleItemGroupResource)
-	viewCmd.RegisterFlagCompletionFunc("object-namespace", completion.RecycleItemNamespace)
+	viewCmd.RegisterFlagCompletionFunc("object-resource", completer.RecycleItemGroupResource)
+	viewCmd.RegisterFlagCompletionFunc("object-namespace", completer.RecycleItemNamespace)
 	viewCmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return []string{"json", "yaml"}, cobra.ShellCompDirectiveNoFileComp
 	})
@@ -74,14 +76,15 @@ func init() {
 
 func runView(args []string) {
 	if len(args) == 0 {
-		tlog.Panicf("✗ please specify recycle items to view.")
+		fatal(nil, "please specify recycle items to view")
 	}
 
+	clients := mustClients()
 	firstOutPut := true
 	for _, recycleItemName := range args {
-		recycleItem, err := krbclient.RecycleItem().Get(context.Background(), recycleItemName, client.GetOptions{})
+		recycleItem, err := clients.RecycleItem().Get(context.Background(), recycleItemName, client.GetOptions{})
 		if err != nil {
-			tlog.Printf("✗ failed to get RecycleItem [%s]: %v, ignored.", recycleItemName, err)
+			logging.Logger().Error(err, "failed to get RecycleItem, ignored", logging.KeyItem, recycleItemName)
 			continue
 		}
 
@@ -89,24 +92,55 @@ func runView(args []string) {
 		case "json":
 			objContent, err := recycleItem.Object.IndentedJSON()
 			if err != nil {
-				tlog.Printf("✗ failed to view recycled resource object [%s: %s] from RecycleItem [%s] in JSON format: %s, error: %v", recycleItem.Object.GroupResource().String(), recycleItem.Object.Key(), recycleItem.Name, recycleItem.Name, err)
+				logging.Logger().Error(err, "failed to view recycled resource object in JSON format", logging.KeyItem, recycleItem.Name, logging.KeyObject, recycleItem.Object.Key())
 				continue
 			}
 
-			tlog.Printf("» [%s: %s]\n", recycleItem.Object.GroupResource().String(), recycleItem.Object.Key())
-			tlog.Println(objContent)
+			fmt.Printf("» [%s: %s] %s\n", recycleItem.Object.GroupResource().String(), recycleItem.Object.Key(), describeDeletion(recycleItem.Deletion))
+			fmt.Println(objContent)
 		default:
 			objContent, err := recycleItem.Object.YAML()
 			if err != nil {
-				tlog.Printf("✗ failed to view recycled resource object [%s: %s] from RecycleItem [%s] in YAML format: %s, error: %v", recycleItem.Object.GroupResource().String(), recycleItem.Object.Key(), recycleItem.Name, recycleItem.Name, err)
+				logging.Logger().Error(err, "failed to view recycled resource object in YAML format", logging.KeyItem, recycleItem.Name, logging.KeyObject, recycleItem.Object.Key())
 				continue
 			}
 			if firstOutPut {
 				firstOutPut = false
 			} else {
-				tlog.Printf("---")
+				fmt.Println("---")
 			}
-			tlog.Print(objContent)
+			fmt.Printf("# %s\n", describeDeletion(recycleItem.Deletion))
+			fmt.Print(objContent)
 		}
 	}
 }
+
+// describeDeletion describes who deleted a recycled object and how, in a single line.
+func describeDeletion(deletion *api.RecycleDeletion) string {
+	if deletion == nil {
+		return "deleted by unknown user"
+	}
+
+	details := []string{}
+	if len(deletion.DeletedBy.Groups) > 0 {
+		details = append(details, "groups: "+strings.Join(deletion.DeletedBy.Groups, ","))
+	}
+	if deletion.DeletedBy.ServiceAccount != "" {
+		details = append(details, "service account: "+deletion.DeletedBy.ServiceAccount)
+	}
+	if deletion.PropagationPolicy != nil {
+		details = append(details, "propagation policy: "+string(*deletion.PropagationPolicy))
+	}
+	if deletion.GracePeriodSeconds != nil {
+		details = append(details, fmt.Sprintf("grace period: %ds", *deletion.GracePeriodSeconds))
+	}
+	if deletion.RequestUID != "" {
+		details = append(details, "request UID: "+string(deletion.RequestUID))
+	}
+
+	result := "deleted by " + util.If(deletion.DeletedBy.Username != "", deletion.DeletedBy.Username, "unknown user")
+	if len(details) > 0 {
+		result += " (" + strings.Join(details, "; ") + ")"
+	}
+	return result
+}
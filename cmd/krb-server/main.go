@@ -19,25 +19,63 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/wcrum/kube-recycle-bin/internal/api"
 	krbclient "github.com/wcrum/kube-recycle-bin/internal/client"
+	"github.com/wcrum/kube-recycle-bin/internal/consts"
+	"github.com/wcrum/kube-recycle-bin/internal/event"
+	"github.com/wcrum/kube-recycle-bin/internal/metrics"
+	"github.com/wcrum/kube-recycle-bin/internal/restore"
 	"github.com/wcrum/kube-recycle-bin/pkg/kube"
+	"github.com/wcrum/kube-recycle-bin/pkg/logging"
+	"github.com/wcrum/kube-recycle-bin/pkg/util"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
 )
 
 type Server struct {
-	webDir string
+	webDir   string
+	clients  *krbclient.Clients
+	restorer *restore.Restorer
+	// allowOverwrite lets restores overwrite existing objects, which takes the permission to
+	// patch any object.
+	allowOverwrite bool
+	logger         logr.Logger
+}
+
+// NewServer returns a Server of the clients, serving the web UI from webDir.
+func NewServer(clients *krbclient.Clients, recorder *event.Recorder, webDir string, logger logr.Logger) *Server {
+	return &Server{
+		webDir:   webDir,
+		clients:  clients,
+		restorer: restore.NewRestorer(clients, recorder, restore.DefaultSanitizer()),
+		logger:   logger,
+	}
 }
 
 func main() {
+	var allowOverwrite bool
+	flag.BoolVar(&allowOverwrite, "allow-overwrite", util.BoolEnv(consts.AllowOverwriteEnv),
+		"If set, let restores overwrite existing objects, krb-server must be allowed to patch them. Defaults to $"+consts.AllowOverwriteEnv+".")
+	logOptions := logging.Options{Format: logging.FormatText}
+	logOptions.AddFlags(flag.CommandLine)
+	flag.Parse()
+	logger := logging.Setup(logOptions).WithName("server")
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
@@ -48,12 +86,34 @@ func main() {
 		webDir = "./web"
 	}
 
-	s := &Server{
-		webDir: webDir,
+	config, err := kube.RestConfig()
+	if err != nil {
+		logger.Error(err, "failed to load kubeconfig")
+		os.Exit(1)
+	}
+	clients, err := krbclient.NewClients(config)
+	if err != nil {
+		logger.Error(err, "failed to create clients")
+		os.Exit(1)
 	}
+	s := NewServer(clients, event.NewRecorder(clients.Kubernetes, "krb-server"), webDir, logger)
+	s.allowOverwrite = allowOverwrite
+
+	metrics.RegisterServer(prometheus.DefaultRegisterer)
 
+	logger.Info("starting server", "port", port, "webDir", webDir, "allowOverwrite", allowOverwrite)
+	if err := http.ListenAndServe(":"+port, s.Handler()); err != nil {
+		logger.Error(err, "failed to listen and serve")
+		os.Exit(1)
+	}
+}
+
+// Handler returns the handler of the api, the metrics and the web UI of the server.
+func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
 
+	mux.Handle("/metrics", promhttp.Handler())
+
 	// API endpoints
 	mux.HandleFunc("/api/v1/recycle-items", s.handleListRecycleItems)
 	mux.HandleFunc("/api/v1/recycle-items/", s.handleRecycleItem)
@@ -64,9 +124,7 @@ func main() {
 	// Serve index.html for all non-API routes (SPA fallback)
 	mux.HandleFunc("/", s.handleSPA)
 
-	log.Printf("Starting server on :%s", port)
-	log.Printf("Serving web files from: %s", webDir)
-	log.Fatal(http.ListenAndServe(":"+port, corsMiddleware(mux)))
+	return corsMiddleware(mux)
 }
 
 func corsMiddleware(next http.Handler) http.Handler {
@@ -90,7 +148,7 @@ func (s *Server) handleListRecycleItems(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	list, err := krbclient.RecycleItem().List(context.Background(), client.ListOptions{})
+	list, err := s.clients.RecycleItem().List(context.Background(), client.ListOptions{})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to list recycle items: %v", err), http.StatusInternalServerError)
 		return
@@ -107,6 +165,8 @@ func (s *Server) handleListRecycleItems(w http.ResponseWriter, r *http.Request)
 			ObjectNamespace:  item.Object.Namespace,
 			ObjectName:       item.Object.Name,
 			ObjectResource:   item.Object.Resource,
+			Phase:            string(item.Phase()),
+			LastRestoreError: item.Status.LastRestoreError,
 			Age:              time.Since(item.CreationTimestamp.Time).String(),
 			CreatedAt:        item.CreationTimestamp.Time.Format(time.RFC3339),
 		}
@@ -124,6 +184,12 @@ func (s *Server) handleRecycleItem(w http.ResponseWriter, r *http.Request) {
 	// Extract path after /api/v1/recycle-items/
 	path := r.URL.Path[len("/api/v1/recycle-items/"):]
 
+	// Check if it's a restore of several items: /api/v1/recycle-items/restore
+	if r.Method == http.MethodPost && path == "restore" {
+		s.handleRestoreItems(w, r)
+		return
+	}
+
 	// Check if it's a restore request: /api/v1/recycle-items/{name}/restore
 	if r.Method == http.MethodPost && strings.HasSuffix(path, "/restore") {
 		name := strings.TrimSuffix(path, "/restore")
@@ -135,6 +201,17 @@ func (s *Server) handleRecycleItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Check if it's a diff request: /api/v1/recycle-items/{name}/diff
+	if r.Method == http.MethodGet && strings.HasSuffix(path, "/diff") {
+		name := strings.TrimSuffix(path, "/diff")
+		if name == "" {
+			http.Error(w, "Name is required", http.StatusBadRequest)
+			return
+		}
+		s.handleDiff(w, r, name)
+		return
+	}
+
 	// For other requests, treat the path as the name
 	name := path
 	if name == "" {
@@ -156,7 +233,7 @@ func (s *Server) handleRecycleItem(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleGetRecycleItem(w http.ResponseWriter, r *http.Request, name string) {
-	item, err := krbclient.RecycleItem().Get(context.Background(), name, client.GetOptions{})
+	item, err := s.clients.RecycleItem().Get(context.Background(), name, client.GetOptions{})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to get recycle item: %v", err), http.StatusNotFound)
 		return
@@ -170,16 +247,26 @@ func (s *Server) handleGetRecycleItem(w http.ResponseWriter, r *http.Request, na
 		ObjectNamespace:  item.Object.Namespace,
 		ObjectName:       item.Object.Name,
 		ObjectResource:   item.Object.Resource,
+		Phase:            string(item.Phase()),
+		LastRestoreError: item.Status.LastRestoreError,
 		Age:              time.Since(item.CreationTimestamp.Time).String(),
 		CreatedAt:        item.CreationTimestamp.Time.Format(time.RFC3339),
 	}
+	if item.Deletion != nil {
+		response.DeletedBy = &item.Deletion.DeletedBy
+		response.RequestUID = string(item.Deletion.RequestUID)
+		response.GracePeriodSeconds = item.Deletion.GracePeriodSeconds
+		if item.Deletion.PropagationPolicy != nil {
+			response.PropagationPolicy = string(*item.Deletion.PropagationPolicy)
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
 func (s *Server) handleGetYAML(w http.ResponseWriter, r *http.Request, name string) {
-	item, err := krbclient.RecycleItem().Get(context.Background(), name, client.GetOptions{})
+	item, err := s.clients.RecycleItem().Get(context.Background(), name, client.GetOptions{})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to get recycle item: %v", err), http.StatusNotFound)
 		return
@@ -196,38 +283,189 @@ func (s *Server) handleGetYAML(w http.ResponseWriter, r *http.Request, name stri
 }
 
 func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request, name string) {
-	item, err := krbclient.RecycleItem().Get(context.Background(), name, client.GetOptions{})
+	// the request is optional, an empty body restores the object in place.
+	var req RestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, fmt.Sprintf("Failed to decode request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	result, code := s.restoreItem(name, req)
+	if code != http.StatusOK {
+		http.Error(w, result.Error, code)
+		return
+	}
+
+	response := RestoreResponse{
+		Success:       true,
+		Message:       result.Message,
+		Outcome:       result.Outcome,
+		Object:        result.Object,
+		Manifest:      result.Manifest,
+		DroppedFields: result.DroppedFields,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleDiff compares the recycled object of a RecycleItem with its live object. The namespace,
+// name and rewriteReferences query parameters compare it as it would be restored with the same
+// options.
+func (s *Server) handleDiff(w http.ResponseWriter, r *http.Request, name string) {
+	item, err := s.clients.RecycleItem().Get(context.Background(), name, client.GetOptions{})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to get recycle item: %v", err), http.StatusNotFound)
 		return
 	}
 
-	unstructuredObj, err := item.Object.Unstructured()
+	query := r.URL.Query()
+	diff, err := s.restorer.Diff(context.Background(), item, restore.Options{
+		Namespace:         query.Get("namespace"),
+		Name:              query.Get("name"),
+		RewriteReferences: query.Get("rewriteReferences") == "true",
+	})
+	if errors.Is(err, restore.ErrInvalidOptions) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, restore.ErrVersionUnavailable) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get unstructured object: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to diff recycle item: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	_, err = kube.DynamicClient().Resource(item.Object.GroupVersionResource()).Namespace(item.Object.Namespace).Create(context.Background(), unstructuredObj, metav1.CreateOptions{})
+	response := DiffResponse{
+		Name:       item.Name,
+		ObjectKey:  cache.MetaObjectToName(diff.Recycled).String(),
+		LiveExists: diff.Live != nil,
+		Diff:       diff.Unified,
+	}
+	recycled, err := yaml.Marshal(diff.Recycled.Object)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to restore resource: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to convert to YAML: %v", err), http.StatusInternalServerError)
 		return
 	}
+	response.Recycled = string(recycled)
+	if diff.Live != nil {
+		live, err := yaml.Marshal(diff.Live.Object)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to convert to YAML: %v", err), http.StatusInternalServerError)
+			return
+		}
+		response.Live = string(live)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
 
-	// Delete the recycle item after successful restore
-	if err := krbclient.RecycleItem().Delete(context.Background(), name, client.DeleteOptions{}); err != nil {
-		log.Printf("Warning: Failed to delete RecycleItem [%s] after restore: %v", name, err)
+// handleRestoreItems restores several RecycleItems with the same options, and reports the
+// outcome of each of them.
+func (s *Server) handleRestoreItems(w http.ResponseWriter, r *http.Request) {
+	var req RestoreItemsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to decode request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(req.Items) == 0 {
+		http.Error(w, "Items are required", http.StatusBadRequest)
+		return
 	}
 
-	response := RestoreResponse{
-		Success: true,
-		Message: fmt.Sprintf("Successfully restored %s", item.Object.Key()),
+	response := RestoreItemsResponse{Results: make([]RestoreItemResult, 0, len(req.Items))}
+	for _, name := range req.Items {
+		result, _ := s.restoreItem(name, req.RestoreRequest)
+		response.Results = append(response.Results, result)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// restoreItem restores the RecycleItem of the name as the request tells, and returns its
+// result with the HTTP status code of the restore.
+func (s *Server) restoreItem(name string, req RestoreRequest) (RestoreItemResult, int) {
+	result := RestoreItemResult{Name: name}
+	fail := func(code int, format string, args ...any) (RestoreItemResult, int) {
+		result.Error = fmt.Sprintf(format, args...)
+		return result, code
+	}
+
+	if restore.ConflictStrategy(req.OnConflict) == restore.ConflictOverwrite && !s.allowOverwrite {
+		return fail(http.StatusForbidden, "Restores overwriting existing objects are disabled, run krb-server with $%s=true and the permission to patch them", consts.AllowOverwriteEnv)
+	}
+
+	item, err := s.clients.RecycleItem().Get(context.Background(), name, client.GetOptions{})
+	if err != nil {
+		return fail(http.StatusNotFound, "Failed to get recycle item: %v", err)
+	}
+
+	ctx := logging.IntoContext(context.Background(), s.logger)
+	restored, err := s.restorer.Restore(ctx, item, restore.Options{
+		Namespace:         req.Namespace,
+		Name:              req.Name,
+		RewriteReferences: req.RewriteReferences,
+		OnConflict:        restore.ConflictStrategy(req.OnConflict),
+		DryRun:            req.DryRun,
+	})
+	if errors.Is(err, restore.ErrAlreadyRestored) {
+		return fail(http.StatusConflict, "Recycle item %s was already restored", name)
+	}
+	if errors.Is(err, restore.ErrInvalidOptions) {
+		return fail(http.StatusBadRequest, "%v", err)
+	}
+	// restores are only counted once the object was restored or failed to be, skips and dry runs
+	// restore nothing.
+	switch {
+	case req.DryRun:
+	case errors.Is(err, restore.ErrRestoreFailed):
+		metrics.Restores.WithLabelValues(item.Object.Group, item.Object.Resource, item.Object.Namespace, metrics.RestoreFailure).Inc()
+	case err == nil && restored.Outcome != restore.OutcomeSkipped:
+		metrics.Restores.WithLabelValues(item.Object.Group, item.Object.Resource, item.Object.Namespace, metrics.RestoreSuccess).Inc()
+	}
+	if apierrors.IsAlreadyExists(err) {
+		return fail(http.StatusConflict, "Failed to restore resource: %v", err)
+	}
+	if errors.Is(err, restore.ErrVersionUnavailable) {
+		return fail(http.StatusUnprocessableEntity, "Failed to restore resource: %v", err)
+	}
+	if err != nil {
+		s.logger.Error(err, "failed to restore RecycleItem", logging.KeyItem, name)
+		return fail(http.StatusInternalServerError, "Failed to restore resource: %v", err)
+	}
+
+	result.Outcome = string(restored.Outcome)
+	result.DroppedFields = restored.DroppedFields
+	if req.DryRun {
+		manifest, err := yaml.Marshal(restored.Manifest.Object)
+		if err != nil {
+			return fail(http.StatusInternalServerError, "Failed to convert to YAML: %v", err)
+		}
+		result.Manifest = string(manifest)
+		result.Object = cache.MetaObjectToName(restored.Manifest).String()
+		result.Message = fmt.Sprintf("Dry run of restore of %s: %s %s", item.Object.Key(), strings.ToLower(result.Outcome), result.Object)
+		return result, http.StatusOK
+	}
+	switch restored.Outcome {
+	case restore.OutcomeSkipped:
+		result.Message = fmt.Sprintf("Skipped restore of %s, an object of the same name exists", item.Object.Key())
+	case restore.OutcomeOverwritten:
+		result.Object = cache.MetaObjectToName(restored.Object).String()
+		result.Message = fmt.Sprintf("Successfully restored %s, overwriting the existing object", item.Object.Key())
+	default:
+		result.Object = cache.MetaObjectToName(restored.Object).String()
+		result.Message = fmt.Sprintf("Successfully restored %s", item.Object.Key())
+		if result.Object != item.Object.Key() {
+			result.Message += " as " + result.Object
+		}
+	}
+	return result, http.StatusOK
+}
+
 // API Response types
 type RecycleItemResponse struct {
 	Name             string `json:"name"`
@@ -237,6 +475,8 @@ type RecycleItemResponse struct {
 	ObjectNamespace  string `json:"objectNamespace"`
 	ObjectName       string `json:"objectName"`
 	ObjectResource   string `json:"objectResource"`
+	Phase            string `json:"phase"`
+	LastRestoreError string `json:"lastRestoreError,omitempty"`
 	Age              string `json:"age"`
 	CreatedAt        string `json:"createdAt"`
 }
@@ -253,13 +493,85 @@ type RecycleItemDetailResponse struct {
 	ObjectNamespace  string `json:"objectNamespace"`
 	ObjectName       string `json:"objectName"`
 	ObjectResource   string `json:"objectResource"`
-	Age              string `json:"age"`
-	CreatedAt        string `json:"createdAt"`
+	Phase            string `json:"phase"`
+	LastRestoreError string `json:"lastRestoreError,omitempty"`
+	// DeletedBy, PropagationPolicy, GracePeriodSeconds and RequestUID describe the deletion
+	// of the object, unset for items recycled before krb recorded them.
+	DeletedBy          *api.DeletedBy `json:"deletedBy,omitempty"`
+	PropagationPolicy  string         `json:"propagationPolicy,omitempty"`
+	GracePeriodSeconds *int64         `json:"gracePeriodSeconds,omitempty"`
+	RequestUID         string         `json:"requestUID,omitempty"`
+	Age                string         `json:"age"`
+	CreatedAt          string         `json:"createdAt"`
+}
+
+// RestoreRequest is the optional body of a restore, the object is restored in place by default.
+type RestoreRequest struct {
+	// Namespace restores a namespaced object into another namespace.
+	Namespace string `json:"namespace"`
+	// Name restores the object under another name.
+	Name string `json:"name"`
+	// RewriteReferences rewrites the labels, selectors and ConfigMap/Secret references holding
+	// the former name of the object to Name.
+	RewriteReferences bool `json:"rewriteReferences"`
+	// OnConflict tells what happens when an object of the same name exists, one of fail (the
+	// default), skip, overwrite or rename. Overwrites are refused unless the server allows them.
+	OnConflict string `json:"onConflict"`
+	// DryRun runs the restore without persisting anything, the response holds the manifest that
+	// would be restored.
+	DryRun bool `json:"dryRun"`
 }
 
 type RestoreResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
+	// Outcome is one of Created, Skipped, Overwritten or Renamed.
+	Outcome string `json:"outcome"`
+	// Object is the namespace/name key of the restored object, empty if the restore was skipped.
+	Object string `json:"object,omitempty"`
+	// Manifest is the YAML manifest of a dry run.
+	Manifest string `json:"manifest,omitempty"`
+	// DroppedFields are the fields of the recycled object the api server dropped, as the
+	// version it was restored in does not have them.
+	DroppedFields []string `json:"droppedFields,omitempty"`
+}
+
+// DiffResponse is the difference between a recycled object and the live object it would be
+// restored over, both sanitized as they are on restore.
+type DiffResponse struct {
+	Name string `json:"name"`
+	// ObjectKey is the namespace/name key the object would be restored as.
+	ObjectKey  string `json:"objectKey"`
+	LiveExists bool   `json:"liveExists"`
+	// Recycled and Live are the YAML manifests of the recycled and the live objects.
+	Recycled string `json:"recycled"`
+	Live     string `json:"live,omitempty"`
+	// Diff is the unified diff from the live object to the recycled one, empty if they are
+	// equal.
+	Diff string `json:"diff"`
+}
+
+// RestoreItemsRequest restores several RecycleItems with the same options.
+type RestoreItemsRequest struct {
+	Items []string `json:"items"`
+	RestoreRequest
+}
+
+// RestoreItemResult is the outcome of the restore of a RecycleItem, or its error.
+type RestoreItemResult struct {
+	Name     string `json:"name"`
+	Outcome  string `json:"outcome,omitempty"`
+	Object   string `json:"object,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Manifest string `json:"manifest,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// DroppedFields are the fields of the recycled object the api server dropped, see
+	// RestoreResponse.
+	DroppedFields []string `json:"droppedFields,omitempty"`
+}
+
+type RestoreItemsResponse struct {
+	Results []RestoreItemResult `json:"results"`
 }
 
 func (s *Server) handleRecyclePolicies(w http.ResponseWriter, r *http.Request) {
@@ -274,7 +586,7 @@ func (s *Server) handleRecyclePolicies(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleListRecyclePolicies(w http.ResponseWriter, r *http.Request) {
-	list, err := krbclient.RecyclePolicy().List(context.Background(), client.ListOptions{})
+	list, err := s.clients.RecyclePolicy().List(context.Background(), client.ListOptions{})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to list recycle policies: %v", err), http.StatusInternalServerError)
 		return
@@ -283,14 +595,7 @@ func (s *Server) handleListRecyclePolicies(w http.ResponseWriter, r *http.Reques
 	// Transform to API response format
 	policies := make([]RecyclePolicyResponse, len(list.Items))
 	for i, policy := range list.Items {
-		policies[i] = RecyclePolicyResponse{
-			Name:       policy.Name,
-			Group:      policy.Target.Group,
-			Resource:   policy.Target.Resource,
-			Namespaces: policy.Target.Namespaces,
-			Age:        time.Since(policy.CreationTimestamp.Time).String(),
-			CreatedAt:  policy.CreationTimestamp.Time.Format(time.RFC3339),
-		}
+		policies[i] = newRecyclePolicyResponse(&policy)
 	}
 
 	response := RecyclePolicyListResponse{
@@ -313,10 +618,38 @@ func (s *Server) handleCreateRecyclePolicy(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "Name is required", http.StatusBadRequest)
 		return
 	}
-	if req.Resource == "" {
-		http.Error(w, "Resource is required", http.StatusBadRequest)
+	if req.Resource == "" && len(req.Resources) == 0 {
+		http.Error(w, "Resource or resources is required", http.StatusBadRequest)
 		return
 	}
+	for _, rule := range req.Resources {
+		if rule.Resource == "" {
+			http.Error(w, "Resource is required in every resources rule", http.StatusBadRequest)
+			return
+		}
+	}
+	switch req.OnRecycleFailure {
+	case "", api.RecycleFailureAllow, api.RecycleFailureDeny:
+	default:
+		http.Error(w, "OnRecycleFailure must be one of: Allow, Deny", http.StatusBadRequest)
+		return
+	}
+	var objectSelector *metav1.LabelSelector
+	if req.ObjectSelector != "" {
+		var err error
+		if objectSelector, err = metav1.ParseToLabelSelector(req.ObjectSelector); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid object selector: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	var namespaceSelector *metav1.LabelSelector
+	if req.NamespaceSelector != "" {
+		var err error
+		if namespaceSelector, err = metav1.ParseToLabelSelector(req.NamespaceSelector); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid namespace selector: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
 
 	// Create the RecyclePolicy
 	policy := &api.RecyclePolicy{
@@ -328,32 +661,42 @@ func (s *Server) handleCreateRecyclePolicy(w http.ResponseWriter, r *http.Reques
 			Name: req.Name,
 		},
 		Target: api.RecycleTarget{
-			Group:      req.Group,
-			Resource:   req.Resource,
-			Namespaces: req.Namespaces,
+			Group:             req.Group,
+			Resource:          req.Resource,
+			Resources:         req.Resources,
+			Namespaces:        req.Namespaces,
+			ObjectSelector:    objectSelector,
+			NamespaceSelector: namespaceSelector,
+			ExcludeNamespaces: req.ExcludeNamespaces,
 		},
+		OnRecycleFailure: req.OnRecycleFailure,
 	}
+	policy.Labels = policy.Target.Labels()
 
-	if err := krbclient.RecyclePolicy().Create(context.Background(), policy, client.CreateOptions{}); err != nil {
+	// refuse targets the cluster does not serve rather than leave the policy not ready.
+	for _, gr := range policy.Target.GroupResources() {
+		if _, err := s.clients.Discovery.RESTMapper().ResourceFor(gr.WithVersion("")); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid target resource %s: %v", gr.String(), err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := s.clients.RecyclePolicy().Create(context.Background(), policy, client.CreateOptions{}); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create recycle policy: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	// Fetch the created policy to get the timestamp set by Kubernetes
-	createdPolicy, err := krbclient.RecyclePolicy().Get(context.Background(), policy.Name, client.GetOptions{})
+	createdPolicy, err := s.clients.RecyclePolicy().Get(context.Background(), policy.Name, client.GetOptions{})
 	if err != nil {
 		// If we can't fetch it, still return success but with default timestamp
+		policyResponse := newRecyclePolicyResponse(policy)
+		policyResponse.Age = "0s"
+		policyResponse.CreatedAt = time.Now().Format(time.RFC3339)
 		response := CreateRecyclePolicyResponse{
 			Success: true,
 			Message: fmt.Sprintf("Successfully created RecyclePolicy %s", policy.Name),
-			Policy: RecyclePolicyResponse{
-				Name:       policy.Name,
-				Group:      policy.Target.Group,
-				Resource:   policy.Target.Resource,
-				Namespaces: policy.Target.Namespaces,
-				Age:        "0s",
-				CreatedAt:  time.Now().Format(time.RFC3339),
-			},
+			Policy:  policyResponse,
 		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
@@ -364,14 +707,7 @@ func (s *Server) handleCreateRecyclePolicy(w http.ResponseWriter, r *http.Reques
 	response := CreateRecyclePolicyResponse{
 		Success: true,
 		Message: fmt.Sprintf("Successfully created RecyclePolicy %s", createdPolicy.Name),
-		Policy: RecyclePolicyResponse{
-			Name:       createdPolicy.Name,
-			Group:      createdPolicy.Target.Group,
-			Resource:   createdPolicy.Target.Resource,
-			Namespaces: createdPolicy.Target.Namespaces,
-			Age:        time.Since(createdPolicy.CreationTimestamp.Time).String(),
-			CreatedAt:  createdPolicy.CreationTimestamp.Time.Format(time.RFC3339),
-		},
+		Policy:  newRecyclePolicyResponse(createdPolicy),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -415,27 +751,20 @@ func (s *Server) handleSPA(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleGetRecyclePolicy(w http.ResponseWriter, r *http.Request, name string) {
-	policy, err := krbclient.RecyclePolicy().Get(context.Background(), name, client.GetOptions{})
+	policy, err := s.clients.RecyclePolicy().Get(context.Background(), name, client.GetOptions{})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to get recycle policy: %v", err), http.StatusNotFound)
 		return
 	}
 
-	response := RecyclePolicyResponse{
-		Name:       policy.Name,
-		Group:      policy.Target.Group,
-		Resource:   policy.Target.Resource,
-		Namespaces: policy.Target.Namespaces,
-		Age:        time.Since(policy.CreationTimestamp.Time).String(),
-		CreatedAt:  policy.CreationTimestamp.Time.Format(time.RFC3339),
-	}
+	response := newRecyclePolicyResponse(policy)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
 func (s *Server) handleDeleteRecyclePolicy(w http.ResponseWriter, r *http.Request, name string) {
-	if err := krbclient.RecyclePolicy().Delete(context.Background(), name, client.DeleteOptions{}); err != nil {
+	if err := s.clients.RecyclePolicy().Delete(context.Background(), name, client.DeleteOptions{}); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to delete recycle policy: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -449,14 +778,63 @@ func (s *Server) handleDeleteRecyclePolicy(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(response)
 }
 
+func newRecyclePolicyResponse(policy *api.RecyclePolicy) RecyclePolicyResponse {
+	response := RecyclePolicyResponse{
+		Name:               policy.Name,
+		Group:              policy.Target.Group,
+		Resource:           policy.Target.Resource,
+		Resources:          policy.Target.ResourceRules(),
+		Namespaces:         policy.Target.Namespaces,
+		ObjectSelector:     formatLabelSelector(policy.Target.ObjectSelector),
+		NamespaceSelector:  formatLabelSelector(policy.Target.NamespaceSelector),
+		ExcludeNamespaces:  policy.Target.ExcludeNamespaces,
+		OnRecycleFailure:   string(api.RecycleFailureAllow),
+		ObservedGeneration: policy.Status.ObservedGeneration,
+		Conditions:         policy.Status.Conditions,
+		ItemCount:          policy.Status.ItemCount,
+		Age:                time.Since(policy.CreationTimestamp.Time).String(),
+		CreatedAt:          policy.CreationTimestamp.Time.Format(time.RFC3339),
+	}
+	if policy.DeniesOnRecycleFailure() {
+		response.OnRecycleFailure = string(api.RecycleFailureDeny)
+	}
+	// keep group and resource set for clients unaware of resources when there is a single rule.
+	if response.Resource == "" && len(response.Resources) == 1 {
+		response.Group = response.Resources[0].Group
+		response.Resource = response.Resources[0].Resource
+	}
+	if policy.Status.LastRecycleTime != nil {
+		response.LastRecycleTime = policy.Status.LastRecycleTime.Time.Format(time.RFC3339)
+	}
+	return response
+}
+
+// formatLabelSelector formats a label selector in kubectl syntax, empty if no selector is set.
+func formatLabelSelector(selector *metav1.LabelSelector) string {
+	if selector == nil {
+		return ""
+	}
+	return metav1.FormatLabelSelector(selector)
+}
+
 // RecyclePolicy API Response types
 type RecyclePolicyResponse struct {
-	Name       string   `json:"name"`
-	Group      string   `json:"group"`
-	Resource   string   `json:"resource"`
-	Namespaces []string `json:"namespaces"`
-	Age        string   `json:"age"`
-	CreatedAt  string   `json:"createdAt"`
+	Name     string `json:"name"`
+	Group    string `json:"group"`
+	Resource string `json:"resource"`
+	// Resources are all group/resource rules of the policy, including group and resource.
+	Resources          []api.RecycleResourceRule `json:"resources"`
+	Namespaces         []string                  `json:"namespaces"`
+	ObjectSelector     string                    `json:"objectSelector,omitempty"`
+	NamespaceSelector  string                    `json:"namespaceSelector,omitempty"`
+	ExcludeNamespaces  []string                  `json:"excludeNamespaces,omitempty"`
+	OnRecycleFailure   string                    `json:"onRecycleFailure"`
+	ObservedGeneration int64                     `json:"observedGeneration"`
+	Conditions         []metav1.Condition        `json:"conditions,omitempty"`
+	ItemCount          int32                     `json:"itemCount"`
+	LastRecycleTime    string                    `json:"lastRecycleTime,omitempty"`
+	Age                string                    `json:"age"`
+	CreatedAt          string                    `json:"createdAt"`
 }
 
 type RecyclePolicyListResponse struct {
@@ -464,10 +842,19 @@ type RecyclePolicyListResponse struct {
 }
 
 type CreateRecyclePolicyRequest struct {
-	Name       string   `json:"name"`
-	Group      string   `json:"group"`
-	Resource   string   `json:"resource"`
-	Namespaces []string `json:"namespaces"`
+	Name     string `json:"name"`
+	Group    string `json:"group"`
+	Resource string `json:"resource"`
+	// Resources are group/resource rules targeted in addition to group and resource.
+	Resources  []api.RecycleResourceRule `json:"resources"`
+	Namespaces []string                  `json:"namespaces"`
+	// ObjectSelector is a label selector in kubectl syntax, such as "team=payments,tier!=cache".
+	ObjectSelector string `json:"objectSelector"`
+	// NamespaceSelector is a namespace label selector in kubectl syntax, such as "env=prod".
+	NamespaceSelector string   `json:"namespaceSelector"`
+	ExcludeNamespaces []string `json:"excludeNamespaces"`
+	// OnRecycleFailure is Allow or Deny, Allow if empty.
+	OnRecycleFailure api.RecycleFailurePolicy `json:"onRecycleFailure"`
 }
 
 type CreateRecyclePolicyResponse struct {
@@ -0,0 +1,356 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+	krbclient "github.com/wcrum/kube-recycle-bin/internal/client"
+	"github.com/wcrum/kube-recycle-bin/internal/client/fake"
+	"github.com/wcrum/kube-recycle-bin/internal/event"
+	"github.com/wcrum/kube-recycle-bin/internal/metrics"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// newConfigMapItem returns a RecycleItem of the deleted configmap dev/settings.
+func newConfigMapItem() *api.RecycleItem {
+	return api.NewRecycleItem(&api.RecycledObject{
+		Version:   "v1",
+		Resource:  "configmaps",
+		Kind:      "ConfigMap",
+		Namespace: "dev",
+		Name:      "settings",
+		UID:       "5d2c1b0a-9e8f-4d7c-a6b5-c4d3e2f1a0b9",
+		Raw:       []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"settings","namespace":"dev"},"data":{"level":"debug"}}`),
+	}, types.UID("3f2e1d0c-8b7a-4c6d-9e5f-a4b3c2d1e0f9"))
+}
+
+// newTestServer returns a Server of fake clients serving the objects.
+func newTestServer(objects ...runtime.Object) (*Server, *krbclient.Clients) {
+	clients := fake.NewClients(objects...)
+	return NewServer(clients, event.NewRecorder(clients.Kubernetes, "krb-test"), "", logr.Discard()), clients
+}
+
+// serve sends the request to the server and returns its response.
+func serve(s *Server, method, path, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return recorder
+}
+
+func TestListRecycleItems(t *testing.T) {
+	recycleItem := newConfigMapItem()
+	s, _ := newTestServer(recycleItem)
+
+	resp := serve(s, http.MethodGet, "/api/v1/recycle-items", "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("✗ unexpected status code %d: %s", resp.Code, resp.Body.String())
+	}
+	var list RecycleItemListResponse
+	if err := json.Unmarshal(resp.Body.Bytes(), &list); err != nil {
+		t.Fatalf("✗ failed to decode response: %v", err)
+	}
+	if len(list.Items) != 1 {
+		t.Fatalf("✗ expected 1 RecycleItem, got %d", len(list.Items))
+	}
+	if got := list.Items[0]; got.Name != recycleItem.Name || got.ObjectKey != "dev/settings" || got.Phase != string(api.RecycleItemRecycled) {
+		t.Errorf("✗ unexpected RecycleItem %+v", got)
+	}
+}
+
+func TestGetRecycleItemNotFound(t *testing.T) {
+	s, _ := newTestServer()
+
+	if resp := serve(s, http.MethodGet, "/api/v1/recycle-items/missing", ""); resp.Code != http.StatusNotFound {
+		t.Errorf("✗ expected status code %d, got %d", http.StatusNotFound, resp.Code)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	recycleItem := newConfigMapItem()
+	s, clients := newTestServer(recycleItem)
+	successes := testutil.ToFloat64(metrics.Restores.WithLabelValues("", "configmaps", "dev", metrics.RestoreSuccess))
+
+	resp := serve(s, http.MethodPost, "/api/v1/recycle-items/"+recycleItem.Name+"/restore", "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("✗ unexpected status code %d: %s", resp.Code, resp.Body.String())
+	}
+
+	if _, err := clients.Dynamic.Resource(recycleItem.Object.GroupVersionResource()).Namespace("dev").Get(context.Background(), "settings", metav1.GetOptions{}); err != nil {
+		t.Errorf("✗ failed to get restored object: %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.Restores.WithLabelValues("", "configmaps", "dev", metrics.RestoreSuccess)); got != successes+1 {
+		t.Errorf("✗ expected %v successful restores, got %v", successes+1, got)
+	}
+
+	// restoring it again is refused.
+	if resp := serve(s, http.MethodPost, "/api/v1/recycle-items/"+recycleItem.Name+"/restore", ""); resp.Code != http.StatusConflict {
+		t.Errorf("✗ expected status code %d, got %d", http.StatusConflict, resp.Code)
+	}
+}
+
+func TestRestoreAs(t *testing.T) {
+	recycleItem := newConfigMapItem()
+	s, clients := newTestServer(recycleItem)
+
+	resp := serve(s, http.MethodPost, "/api/v1/recycle-items/"+recycleItem.Name+"/restore", `{"namespace":"scratch","name":"settings-copy"}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("✗ unexpected status code %d: %s", resp.Code, resp.Body.String())
+	}
+	if _, err := clients.Dynamic.Resource(recycleItem.Object.GroupVersionResource()).Namespace("scratch").Get(context.Background(), "settings-copy", metav1.GetOptions{}); err != nil {
+		t.Errorf("✗ failed to get restored object: %v", err)
+	}
+	var response RestoreResponse
+	if err := json.Unmarshal(resp.Body.Bytes(), &response); err != nil {
+		t.Fatalf("✗ failed to decode response: %v", err)
+	}
+	if want := "Successfully restored dev/settings as scratch/settings-copy"; response.Message != want {
+		t.Errorf("✗ expected message %q, got %q", want, response.Message)
+	}
+
+	if resp := serve(s, http.MethodPost, "/api/v1/recycle-items/"+recycleItem.Name+"/restore", `{"namespace":`); resp.Code != http.StatusBadRequest {
+		t.Errorf("✗ expected status code %d for a malformed request, got %d", http.StatusBadRequest, resp.Code)
+	}
+}
+
+// newSecretItem returns a RecycleItem of the deleted secret dev/credentials.
+func newSecretItem() *api.RecycleItem {
+	return api.NewRecycleItem(&api.RecycledObject{
+		Version:   "v1",
+		Resource:  "secrets",
+		Kind:      "Secret",
+		Namespace: "dev",
+		Name:      "credentials",
+		UID:       "6e3d2c1b-0a9f-4e8d-b7c6-d5e4f3a2b1c0",
+		Raw:       []byte(`{"apiVersion":"v1","kind":"Secret","metadata":{"name":"credentials","namespace":"dev"}}`),
+	}, types.UID("4a3f2e1d-9c8b-4d7e-8f6a-b5c4d3e2f1a0"))
+}
+
+// newExistingConfigMap returns the configmap dev/settings created since the delete of the
+// recycled one.
+func newExistingConfigMap() *unstructured.Unstructured {
+	existing := &unstructured.Unstructured{}
+	existing.SetAPIVersion("v1")
+	existing.SetKind("ConfigMap")
+	existing.SetNamespace("dev")
+	existing.SetName("settings")
+	return existing
+}
+
+func TestRestoreConflict(t *testing.T) {
+	recycleItem := newConfigMapItem()
+	s, _ := newTestServer(recycleItem, newExistingConfigMap())
+
+	if resp := serve(s, http.MethodPost, "/api/v1/recycle-items/"+recycleItem.Name+"/restore", ""); resp.Code != http.StatusConflict {
+		t.Errorf("✗ expected status code %d, got %d: %s", http.StatusConflict, resp.Code, resp.Body.String())
+	}
+	if resp := serve(s, http.MethodPost, "/api/v1/recycle-items/"+recycleItem.Name+"/restore", `{"onConflict":"merge"}`); resp.Code != http.StatusBadRequest {
+		t.Errorf("✗ expected status code %d, got %d: %s", http.StatusBadRequest, resp.Code, resp.Body.String())
+	}
+
+	// overwrites are refused until the server allows them.
+	resp := serve(s, http.MethodPost, "/api/v1/recycle-items/"+recycleItem.Name+"/restore", `{"onConflict":"overwrite"}`)
+	if resp.Code != http.StatusForbidden {
+		t.Errorf("✗ expected status code %d, got %d: %s", http.StatusForbidden, resp.Code, resp.Body.String())
+	}
+	s.allowOverwrite = true
+	resp = serve(s, http.MethodPost, "/api/v1/recycle-items/"+recycleItem.Name+"/restore", `{"onConflict":"overwrite"}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("✗ unexpected status code %d: %s", resp.Code, resp.Body.String())
+	}
+	var response RestoreResponse
+	if err := json.Unmarshal(resp.Body.Bytes(), &response); err != nil {
+		t.Fatalf("✗ failed to decode response: %v", err)
+	}
+	if response.Outcome != "Overwritten" || response.Object != "dev/settings" {
+		t.Errorf("✗ unexpected response %+v", response)
+	}
+}
+
+func TestRestoreVersionUnavailable(t *testing.T) {
+	recycleItem := api.NewRecycleItem(&api.RecycledObject{
+		Group:     "example.com",
+		Version:   "v1beta1",
+		Resource:  "widgets",
+		Kind:      "Widget",
+		Namespace: "dev",
+		Name:      "blue",
+		Raw:       []byte(`{"apiVersion":"example.com/v1beta1","kind":"Widget","metadata":{"name":"blue","namespace":"dev"}}`),
+	}, types.UID("0b1c2d3e-4f5a-4b6c-8d7e-9f0a1b2c3d4e"))
+	s, _ := newTestServer(recycleItem)
+
+	resp := serve(s, http.MethodPost, "/api/v1/recycle-items/"+recycleItem.Name+"/restore", "")
+	if resp.Code != http.StatusUnprocessableEntity {
+		t.Errorf("✗ expected status code %d, got %d: %s", http.StatusUnprocessableEntity, resp.Code, resp.Body.String())
+	}
+	if !strings.Contains(resp.Body.String(), "Widget.example.com is not served") {
+		t.Errorf("✗ expected the unserved kind to be reported, got %s", resp.Body.String())
+	}
+	if resp := serve(s, http.MethodGet, "/api/v1/recycle-items/"+recycleItem.Name+"/diff", ""); resp.Code != http.StatusUnprocessableEntity {
+		t.Errorf("✗ expected status code %d, got %d: %s", http.StatusUnprocessableEntity, resp.Code, resp.Body.String())
+	}
+}
+
+func TestRestoreDryRun(t *testing.T) {
+	recycleItem := newConfigMapItem()
+	s, _ := newTestServer(recycleItem)
+	successes := testutil.ToFloat64(metrics.Restores.WithLabelValues("", "configmaps", "dev", metrics.RestoreSuccess))
+
+	resp := serve(s, http.MethodPost, "/api/v1/recycle-items/"+recycleItem.Name+"/restore", `{"dryRun":true}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("✗ unexpected status code %d: %s", resp.Code, resp.Body.String())
+	}
+	var response RestoreResponse
+	if err := json.Unmarshal(resp.Body.Bytes(), &response); err != nil {
+		t.Fatalf("✗ failed to decode response: %v", err)
+	}
+	if response.Outcome != "Created" || !strings.Contains(response.Manifest, "level: debug") {
+		t.Errorf("✗ unexpected response %+v", response)
+	}
+	if got := testutil.ToFloat64(metrics.Restores.WithLabelValues("", "configmaps", "dev", metrics.RestoreSuccess)); got != successes {
+		t.Errorf("✗ expected dry runs not to be counted, got %v restores", got-successes)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	recycleItem := newConfigMapItem()
+	existing := newExistingConfigMap()
+	unstructured.SetNestedField(existing.Object, "info", "data", "level")
+	s, _ := newTestServer(recycleItem, existing)
+
+	resp := serve(s, http.MethodGet, "/api/v1/recycle-items/"+recycleItem.Name+"/diff", "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("✗ unexpected status code %d: %s", resp.Code, resp.Body.String())
+	}
+	var response DiffResponse
+	if err := json.Unmarshal(resp.Body.Bytes(), &response); err != nil {
+		t.Fatalf("✗ failed to decode response: %v", err)
+	}
+	if !response.LiveExists || response.ObjectKey != "dev/settings" {
+		t.Errorf("✗ unexpected response %+v", response)
+	}
+	if !strings.Contains(response.Diff, "-  level: info\n+  level: debug\n") {
+		t.Errorf("✗ unexpected diff:\n%s", response.Diff)
+	}
+
+	if resp := serve(s, http.MethodGet, "/api/v1/recycle-items/missing/diff", ""); resp.Code != http.StatusNotFound {
+		t.Errorf("✗ expected status code %d, got %d", http.StatusNotFound, resp.Code)
+	}
+}
+
+func TestRestoreItems(t *testing.T) {
+	configMapItem, secretItem := newConfigMapItem(), newSecretItem()
+	s, _ := newTestServer(configMapItem, secretItem, newExistingConfigMap())
+
+	body := `{"items":["` + configMapItem.Name + `","` + secretItem.Name + `","missing"],"onConflict":"skip"}`
+	resp := serve(s, http.MethodPost, "/api/v1/recycle-items/restore", body)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("✗ unexpected status code %d: %s", resp.Code, resp.Body.String())
+	}
+	var response RestoreItemsResponse
+	if err := json.Unmarshal(resp.Body.Bytes(), &response); err != nil {
+		t.Fatalf("✗ failed to decode response: %v", err)
+	}
+
+	want := []RestoreItemResult{
+		{Name: configMapItem.Name, Outcome: "Skipped"},
+		{Name: secretItem.Name, Outcome: "Created", Object: "dev/credentials"},
+		{Name: "missing"},
+	}
+	if len(response.Results) != len(want) {
+		t.Fatalf("✗ expected %d results, got %+v", len(want), response.Results)
+	}
+	for i, got := range response.Results {
+		if got.Name != want[i].Name || got.Outcome != want[i].Outcome || got.Object != want[i].Object {
+			t.Errorf("✗ expected result %+v, got %+v", want[i], got)
+		}
+		if (got.Error == "") != (want[i].Outcome != "") {
+			t.Errorf("✗ unexpected error of %s: %q", got.Name, got.Error)
+		}
+	}
+}
+
+func TestCreateRecyclePolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{
+			name:     "created",
+			body:     `{"name":"recycle-deployments","group":"apps","resource":"deployments","namespaces":["dev"]}`,
+			wantCode: http.StatusCreated,
+		},
+		{
+			name:     "unknown resource",
+			body:     `{"name":"recycle-widgets","group":"example.com","resource":"widgets"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "invalid failure policy",
+			body:     `{"name":"recycle-deployments","group":"apps","resource":"deployments","onRecycleFailure":"Retry"}`,
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, clients := newTestServer()
+
+			resp := serve(s, http.MethodPost, "/api/v1/recycle-policies", tt.body)
+			if resp.Code != tt.wantCode {
+				t.Fatalf("✗ expected status code %d, got %d: %s", tt.wantCode, resp.Code, resp.Body.String())
+			}
+
+			list, err := clients.RecyclePolicy().List(context.Background(), client.ListOptions{})
+			if err != nil {
+				t.Fatalf("✗ failed to list RecyclePolicies: %v", err)
+			}
+			wantPolicies := 0
+			if tt.wantCode == http.StatusCreated {
+				wantPolicies = 1
+			}
+			if len(list.Items) != wantPolicies {
+				t.Errorf("✗ expected %d RecyclePolicies, got %d", wantPolicies, len(list.Items))
+			}
+		})
+	}
+}
+
+func TestDeleteRecyclePolicy(t *testing.T) {
+	s, clients := newTestServer(&api.RecyclePolicy{ObjectMeta: metav1.ObjectMeta{Name: "recycle-deployments"}})
+
+	if resp := serve(s, http.MethodDelete, "/api/v1/recycle-policies/recycle-deployments", ""); resp.Code != http.StatusOK {
+		t.Fatalf("✗ unexpected status code %d: %s", resp.Code, resp.Body.String())
+	}
+	if _, err := clients.RecyclePolicy().Get(context.Background(), "recycle-deployments", client.GetOptions{}); !k8serrors.IsNotFound(err) {
+		t.Errorf("✗ expected the RecyclePolicy to be deleted, got %v", err)
+	}
+}
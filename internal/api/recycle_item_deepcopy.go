@@ -16,7 +16,10 @@ limitations under the License.
 
 package api
 
-import "k8s.io/apimachinery/pkg/runtime"
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
 
 func (in *RecycleItem) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
@@ -40,6 +43,40 @@ func (in *RecycleItem) DeepCopyInto(out *RecycleItem) {
 	out.TypeMeta = in.TypeMeta
 	out.ObjectMeta = in.ObjectMeta
 	out.Object = in.Object
+	if in.Deletion != nil {
+		out.Deletion = new(RecycleDeletion)
+		in.Deletion.DeepCopyInto(out.Deletion)
+	}
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+func (in *RecycleDeletion) DeepCopyInto(out *RecycleDeletion) {
+	*out = *in
+	if in.DeletedBy.Groups != nil {
+		out.DeletedBy.Groups = make([]string, len(in.DeletedBy.Groups))
+		copy(out.DeletedBy.Groups, in.DeletedBy.Groups)
+	}
+	if in.PropagationPolicy != nil {
+		propagationPolicy := *in.PropagationPolicy
+		out.PropagationPolicy = &propagationPolicy
+	}
+	if in.GracePeriodSeconds != nil {
+		gracePeriodSeconds := *in.GracePeriodSeconds
+		out.GracePeriodSeconds = &gracePeriodSeconds
+	}
+}
+
+func (in *RecycleItemStatus) DeepCopyInto(out *RecycleItemStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		out.Conditions = make([]metav1.Condition, len(in.Conditions))
+		for i := range in.Conditions {
+			in.Conditions[i].DeepCopyInto(&out.Conditions[i])
+		}
+	}
+	if in.LastRestoreTime != nil {
+		out.LastRestoreTime = in.LastRestoreTime.DeepCopy()
+	}
 }
 
 func (in *RecycleItemList) DeepCopyObject() runtime.Object {
@@ -18,33 +18,140 @@ package api
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 	"unicode"
 
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/rand"
 	"sigs.k8s.io/yaml"
 )
 
+const (
+	// RecyclePolicyLabel is set on a RecycleItem to the name of the RecyclePolicy that produced it.
+	RecyclePolicyLabel = "krb.wcrum.dev/recycle-policy"
+	// ExpireAtAnnotation is set on a RecycleItem to the RFC3339 time after which it is garbage-collected.
+	ExpireAtAnnotation = "krb.wcrum.dev/expire-at"
+	// ObjectUIDLabel is set on a RecycleItem to the UID of the recycled object.
+	ObjectUIDLabel = "krb.wcrum.dev/object-uid"
+	// RequestUIDLabel is set on a RecycleItem to the UID of the admission request that recycled the object.
+	RequestUIDLabel = "krb.wcrum.dev/request-uid"
+)
+
 type RecycleItem struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata"`
 
 	Object RecycledObject `json:"object"`
+	// Deletion records how and by whom the recycled object was deleted.
+	Deletion *RecycleDeletion  `json:"deletion,omitempty"`
+	Status   RecycleItemStatus `json:"status,omitempty"`
+}
+
+// RecycleDeletion is the deletion request that recycled an object.
+type RecycleDeletion struct {
+	// RequestUID is the UID of the admission request of the deletion.
+	RequestUID types.UID `json:"requestUID,omitempty"`
+	DeletedBy  DeletedBy `json:"deletedBy"`
+	// PropagationPolicy and GracePeriodSeconds are the options of the deletion, unset if the client did not set them.
+	PropagationPolicy  *metav1.DeletionPropagation `json:"propagationPolicy,omitempty"`
+	GracePeriodSeconds *int64                      `json:"gracePeriodSeconds,omitempty"`
+}
+
+// DeletedBy is the user that deleted a recycled object.
+type DeletedBy struct {
+	Username string   `json:"username,omitempty"`
+	UID      string   `json:"uid,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+	// ServiceAccount is the "<namespace>/<name>" of the service account the user authenticated as, if any.
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+}
+
+// serviceAccountUsernamePrefix prefixes the usernames of service accounts, such as
+// "system:serviceaccount:kube-system:default".
+const serviceAccountUsernamePrefix = "system:serviceaccount:"
+
+// NewRecycleDeletion records the user and the options of a deletion. options are the raw
+// metav1.DeleteOptions of the request, ignored if they cannot be decoded.
+func NewRecycleDeletion(requestUID types.UID, userInfo authenticationv1.UserInfo, options []byte) *RecycleDeletion {
+	deletion := &RecycleDeletion{
+		RequestUID: requestUID,
+		DeletedBy: DeletedBy{
+			Username: userInfo.Username,
+			UID:      userInfo.UID,
+			Groups:   userInfo.Groups,
+		},
+	}
+	if sa, ok := strings.CutPrefix(userInfo.Username, serviceAccountUsernamePrefix); ok {
+		if namespace, name, ok := strings.Cut(sa, ":"); ok {
+			deletion.DeletedBy.ServiceAccount = namespace + "/" + name
+		}
+	}
+
+	var deleteOptions metav1.DeleteOptions
+	if len(options) > 0 && json.Unmarshal(options, &deleteOptions) == nil {
+		deletion.PropagationPolicy = deleteOptions.PropagationPolicy
+		deletion.GracePeriodSeconds = deleteOptions.GracePeriodSeconds
+	}
+	return deletion
+}
+
+// DeletedByUsername returns the user that deleted the recycled object, empty if unknown.
+func (ri *RecycleItem) DeletedByUsername() string {
+	if ri.Deletion == nil {
+		return ""
+	}
+	return ri.Deletion.DeletedBy.Username
+}
+
+// RecycleItemPhase is the lifecycle phase of a RecycleItem.
+type RecycleItemPhase string
+
+const (
+	// RecycleItemRecycled means the object was recycled and can be restored.
+	RecycleItemRecycled RecycleItemPhase = "Recycled"
+	// RecycleItemRestoring means a restore of the object is in progress.
+	RecycleItemRestoring RecycleItemPhase = "Restoring"
+	// RecycleItemRestored means the object was restored.
+	RecycleItemRestored RecycleItemPhase = "Restored"
+	// RecycleItemRestoreFailed means the last restore of the object failed, it can be retried.
+	RecycleItemRestoreFailed RecycleItemPhase = "RestoreFailed"
+	// RecycleItemExpired means the RecycleItem expired and is being garbage-collected.
+	RecycleItemExpired RecycleItemPhase = "Expired"
+)
+
+const (
+	// RecycleItemConditionRestored tells whether the recycled object was restored.
+	RecycleItemConditionRestored = "Restored"
+	// RecycleItemConditionExpired tells whether the RecycleItem expired.
+	RecycleItemConditionExpired = "Expired"
+)
+
+type RecycleItemStatus struct {
+	Phase            RecycleItemPhase   `json:"phase,omitempty"`
+	Conditions       []metav1.Condition `json:"conditions,omitempty"`
+	LastRestoreTime  *metav1.Time       `json:"lastRestoreTime,omitempty"`
+	LastRestoreError string             `json:"lastRestoreError,omitempty"`
 }
 
 type RecycledObject struct {
-	Group     string `json:"group,omitempty"`
-	Version   string `json:"version"`
-	Kind      string `json:"kind"`
-	Resource  string `json:"resource"`
-	Namespace string `json:"namespace,omitempty"`
-	Name      string `json:"name"`
-	Raw       []byte `json:"raw"`
+	Group     string    `json:"group,omitempty"`
+	Version   string    `json:"version"`
+	Kind      string    `json:"kind"`
+	Resource  string    `json:"resource"`
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name"`
+	UID       types.UID `json:"uid,omitempty"`
+	Raw       []byte    `json:"raw"`
 }
 
 type RecycleItemList struct {
@@ -165,9 +272,18 @@ func sanitizeLabelValue(value string) string {
 	return result
 }
 
-func NewRecycleItem(recycledObj *RecycledObject) *RecycleItem {
+// recycleItemNameSuffixLength is the length of the suffix that makes the names of RecycleItems unique.
+const recycleItemNameSuffixLength = 10
+
+// NewRecycleItem returns a RecycleItem holding the object recycled by the admission request of
+// requestUID. Its name is derived from the UIDs of the object and of the request, so recycling
+// the same object for the same request yields the same RecycleItem.
+func NewRecycleItem(recycledObj *RecycledObject, requestUID types.UID) *RecycleItem {
 	// Sanitize the resource name for use in RecycleItem metadata.name
 	sanitizedName := sanitizeResourceName(recycledObj.Name)
+	if maxLength := 253 - recycleItemNameSuffixLength - 1; len(sanitizedName) > maxLength {
+		sanitizedName = strings.TrimRight(sanitizedName[:maxLength], "-.")
+	}
 
 	// Sanitize label values to ensure they're valid Kubernetes label values
 	labels := map[string]string{
@@ -178,6 +294,12 @@ func NewRecycleItem(recycledObj *RecycledObject) *RecycleItem {
 	if recycledObj.Namespace != "" {
 		labels["krb.wcrum.dev/object-namespace"] = sanitizeLabelValue(recycledObj.Namespace)
 	}
+	if recycledObj.UID != "" {
+		labels[ObjectUIDLabel] = sanitizeLabelValue(string(recycledObj.UID))
+	}
+	if requestUID != "" {
+		labels[RequestUIDLabel] = sanitizeLabelValue(string(requestUID))
+	}
 
 	return &RecycleItem{
 		TypeMeta: metav1.TypeMeta{
@@ -185,13 +307,118 @@ func NewRecycleItem(recycledObj *RecycledObject) *RecycleItem {
 			Kind:       RecycleItemKind,
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:   sanitizedName + "-" + rand.String(8),
+			Name:   sanitizedName + "-" + recycleItemNameSuffix(recycledObj.UID, requestUID),
 			Labels: labels,
 		},
 		Object: *recycledObj,
 	}
 }
 
+// recycleItemNameSuffix returns the suffix of the name of the RecycleItem of an object recycled
+// by a request, random if neither UID is known.
+func recycleItemNameSuffix(objectUID, requestUID types.UID) string {
+	if objectUID == "" && requestUID == "" {
+		return rand.String(recycleItemNameSuffixLength)
+	}
+	sum := sha256.Sum256([]byte(string(objectUID) + "/" + string(requestUID)))
+	return hex.EncodeToString(sum[:])[:recycleItemNameSuffixLength]
+}
+
+// SetRecyclePolicy stamps the name of the policy that produced the RecycleItem and,
+// when the policy has a TTL, the time the RecycleItem expires.
+func (ri *RecycleItem) SetRecyclePolicy(policy *RecyclePolicy, recycledAt time.Time) {
+	if ri.Labels == nil {
+		ri.Labels = map[string]string{}
+	}
+	ri.Labels[RecyclePolicyLabel] = policy.Name
+
+	if expireAt, ok := policy.ExpireAt(recycledAt); ok {
+		if ri.Annotations == nil {
+			ri.Annotations = map[string]string{}
+		}
+		ri.Annotations[ExpireAtAnnotation] = expireAt.UTC().Format(time.RFC3339)
+	}
+}
+
+// RecyclePolicyName returns the name of the policy that produced the RecycleItem.
+func (ri *RecycleItem) RecyclePolicyName() string {
+	return ri.Labels[RecyclePolicyLabel]
+}
+
+// ExpireAt returns the time the RecycleItem expires, and false if it never expires.
+func (ri *RecycleItem) ExpireAt() (time.Time, bool, error) {
+	value, ok := ri.Annotations[ExpireAtAnnotation]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	expireAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid %s annotation %q: %w", ExpireAtAnnotation, value, err)
+	}
+	return expireAt, true, nil
+}
+
+// Phase returns the lifecycle phase of the RecycleItem, RecycleItems created
+// before the status was set are reported as recycled.
+func (ri *RecycleItem) Phase() RecycleItemPhase {
+	if ri.Status.Phase == "" {
+		return RecycleItemRecycled
+	}
+	return ri.Status.Phase
+}
+
+// MarkRecycled initializes the status of a freshly recycled RecycleItem.
+func (ri *RecycleItem) MarkRecycled() {
+	ri.Status.Phase = RecycleItemRecycled
+}
+
+// MarkRestoring records that a restore of the recycled object started.
+func (ri *RecycleItem) MarkRestoring() {
+	ri.Status.Phase = RecycleItemRestoring
+	ri.setCondition(RecycleItemConditionRestored, metav1.ConditionFalse, "Restoring", "restore of the recycled object is in progress")
+}
+
+// MarkRestored records that the recycled object was restored.
+func (ri *RecycleItem) MarkRestored() {
+	now := metav1.Now()
+	ri.Status.Phase = RecycleItemRestored
+	ri.Status.LastRestoreTime = &now
+	ri.Status.LastRestoreError = ""
+	ri.setCondition(RecycleItemConditionRestored, metav1.ConditionTrue, "Restored", "recycled object restored")
+}
+
+// MarkRestoreFailed records that restoring the recycled object failed with err.
+func (ri *RecycleItem) MarkRestoreFailed(err error) {
+	now := metav1.Now()
+	ri.Status.Phase = RecycleItemRestoreFailed
+	ri.Status.LastRestoreTime = &now
+	ri.Status.LastRestoreError = err.Error()
+	ri.setCondition(RecycleItemConditionRestored, metav1.ConditionFalse, "RestoreFailed", err.Error())
+}
+
+// MarkExpired records that the RecycleItem expired and is garbage-collected.
+func (ri *RecycleItem) MarkExpired() {
+	ri.Status.Phase = RecycleItemExpired
+	ri.setCondition(RecycleItemConditionExpired, metav1.ConditionTrue, "Expired", "retention of the RecyclePolicy expired")
+}
+
+// MarkEvicted records that the RecycleItem is garbage-collected because its RecyclePolicy keeps
+// at most maxItems items and it is among the oldest of them.
+func (ri *RecycleItem) MarkEvicted(maxItems int) {
+	ri.Status.Phase = RecycleItemExpired
+	ri.setCondition(RecycleItemConditionExpired, metav1.ConditionTrue, "Evicted", fmt.Sprintf("RecyclePolicy keeps at most %d items", maxItems))
+}
+
+func (ri *RecycleItem) setCondition(conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&ri.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: ri.Generation,
+	})
+}
+
 func (obj *RecycledObject) Key() string {
 	if obj.Namespace == "" {
 		return obj.Name
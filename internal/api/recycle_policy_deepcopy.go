@@ -16,7 +16,10 @@ limitations under the License.
 
 package api
 
-import "k8s.io/apimachinery/pkg/runtime"
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
 
 func (in *RecyclePolicy) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
@@ -39,7 +42,85 @@ func (in *RecyclePolicy) DeepCopyInto(out *RecyclePolicy) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	out.ObjectMeta = in.ObjectMeta
-	out.Target = in.Target
+	in.Target.DeepCopyInto(&out.Target)
+	if in.Retention != nil {
+		out.Retention = in.Retention.DeepCopy()
+	}
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+func (in *RecyclePolicyStatus) DeepCopy() *RecyclePolicyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RecyclePolicyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RecyclePolicyStatus) DeepCopyInto(out *RecyclePolicyStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		out.Conditions = make([]metav1.Condition, len(in.Conditions))
+		for i := range in.Conditions {
+			in.Conditions[i].DeepCopyInto(&out.Conditions[i])
+		}
+	}
+	if in.LastRecycleTime != nil {
+		out.LastRecycleTime = in.LastRecycleTime.DeepCopy()
+	}
+}
+
+func (in *RecycleTarget) DeepCopyInto(out *RecycleTarget) {
+	*out = *in
+	if in.Resources != nil {
+		out.Resources = make([]RecycleResourceRule, len(in.Resources))
+		for i := range in.Resources {
+			in.Resources[i].DeepCopyInto(&out.Resources[i])
+		}
+	}
+	if in.Namespaces != nil {
+		out.Namespaces = make([]string, len(in.Namespaces))
+		copy(out.Namespaces, in.Namespaces)
+	}
+	if in.ObjectSelector != nil {
+		out.ObjectSelector = in.ObjectSelector.DeepCopy()
+	}
+	if in.NamespaceSelector != nil {
+		out.NamespaceSelector = in.NamespaceSelector.DeepCopy()
+	}
+	if in.ExcludeNamespaces != nil {
+		out.ExcludeNamespaces = make([]string, len(in.ExcludeNamespaces))
+		copy(out.ExcludeNamespaces, in.ExcludeNamespaces)
+	}
+}
+
+func (in *RecycleResourceRule) DeepCopyInto(out *RecycleResourceRule) {
+	*out = *in
+	if in.Versions != nil {
+		out.Versions = make([]string, len(in.Versions))
+		copy(out.Versions, in.Versions)
+	}
+}
+
+func (in *RecycleRetention) DeepCopy() *RecycleRetention {
+	if in == nil {
+		return nil
+	}
+	out := new(RecycleRetention)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RecycleRetention) DeepCopyInto(out *RecycleRetention) {
+	*out = *in
+	if in.TTL != nil {
+		out.TTL = &metav1.Duration{Duration: in.TTL.Duration}
+	}
+	if in.MaxItems != nil {
+		out.MaxItems = new(int32)
+		*out.MaxItems = *in.MaxItems
+	}
 }
 
 func (in *RecyclePolicyList) DeepCopyObject() runtime.Object {
@@ -18,8 +18,14 @@ package api
 
 import (
 	"slices"
+	"strings"
+	"time"
 
+	"github.com/wcrum/kube-recycle-bin/internal/consts"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/rand"
 )
@@ -28,13 +34,73 @@ type RecyclePolicy struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata"`
 
-	Target RecycleTarget `json:"target"`
+	Target    RecycleTarget     `json:"target"`
+	Retention *RecycleRetention `json:"retention,omitempty"`
+	// OnRecycleFailure tells whether a delete is allowed when the object cannot be recycled,
+	// Allow if omitted.
+	OnRecycleFailure RecycleFailurePolicy `json:"onRecycleFailure,omitempty"`
+	Status           RecyclePolicyStatus  `json:"status,omitempty"`
 }
 
+// RecycleFailurePolicy is what happens to a delete when the object cannot be recycled.
+type RecycleFailurePolicy string
+
+const (
+	// RecycleFailureAllow lets the delete through, the object is lost.
+	RecycleFailureAllow RecycleFailurePolicy = "Allow"
+	// RecycleFailureDeny refuses the delete, the object is kept.
+	RecycleFailureDeny RecycleFailurePolicy = "Deny"
+)
+
 type RecycleTarget struct {
-	Group      string   `json:"group,omitempty"`
-	Resource   string   `json:"resource,omitempty"`
-	Namespaces []string `json:"namespaces,omitempty"`
+	// Group and Resource target a single group/resource, they are kept for
+	// policies created before Resources was introduced.
+	Group    string `json:"group,omitempty"`
+	Resource string `json:"resource,omitempty"`
+	// Resources are the group/resource rules targeted by the policy,
+	// in addition to Group and Resource.
+	Resources  []RecycleResourceRule `json:"resources,omitempty"`
+	Namespaces []string              `json:"namespaces,omitempty"`
+	// ObjectSelector restricts recycling to objects whose labels match it,
+	// all objects of the target resource are recycled if omitted.
+	ObjectSelector *metav1.LabelSelector `json:"objectSelector,omitempty"`
+	// NamespaceSelector restricts recycling to namespaces whose labels match it,
+	// in addition to Namespaces.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// ExcludeNamespaces are namespaces never recycled from, even if they are
+	// selected by Namespaces or NamespaceSelector.
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+}
+
+// RecycleResourceRule targets a group/resource, optionally restricted to some versions.
+type RecycleResourceRule struct {
+	Group    string `json:"group,omitempty"`
+	Resource string `json:"resource"`
+	// Versions restricts the rule to the given versions, all versions are targeted if omitted.
+	Versions []string `json:"versions,omitempty"`
+}
+
+// RecycleRetention controls how long the RecycleItems produced by a policy are kept.
+type RecycleRetention struct {
+	// TTL is the time a RecycleItem is kept before it is garbage-collected.
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+	// MaxItems is the maximum number of RecycleItems kept for the policy,
+	// the oldest items are garbage-collected first.
+	MaxItems *int32 `json:"maxItems,omitempty"`
+}
+
+const (
+	// RecyclePolicyConditionWebhookConfigured tells whether the webhook of the policy is configured.
+	RecyclePolicyConditionWebhookConfigured = "WebhookConfigured"
+	// RecyclePolicyConditionTargetResolvable tells whether the target resource of the policy is served by the cluster.
+	RecyclePolicyConditionTargetResolvable = "TargetResolvable"
+)
+
+type RecyclePolicyStatus struct {
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
+	ItemCount          int32              `json:"itemCount"`
+	LastRecycleTime    *metav1.Time       `json:"lastRecycleTime,omitempty"`
 }
 
 type RecyclePolicyList struct {
@@ -43,16 +109,46 @@ type RecyclePolicyList struct {
 	Items           []RecyclePolicy `json:"items"`
 }
 
-func NewRecyclePolicy(gvr schema.GroupVersionResource, targetNamespaces []string) *RecyclePolicy {
+// TargetGroupResourceLabelPrefix prefixes the label set on a RecyclePolicy for each
+// group/resource it targets, such as "krb.wcrum.dev/target-gr-deployments.apps".
+const TargetGroupResourceLabelPrefix = "krb.wcrum.dev/target-gr-"
+
+// TargetGroupResourceLabel returns the label key set on policies targeting the given group/resource.
+func TargetGroupResourceLabel(gr schema.GroupResource) string {
+	return labelKeyWithSuffix(TargetGroupResourceLabelPrefix, gr.String())
+}
+
+// labelKeyWithSuffix builds a label key from prefix and suffix, truncating the suffix
+// so the name part of the key stays within 63 characters.
+func labelKeyWithSuffix(prefix, suffix string) string {
+	suffix = sanitizeLabelValue(suffix)
+	name := prefix[strings.Index(prefix, "/")+1:]
+	if maxLen := 63 - len(name); len(suffix) > maxLen {
+		suffix = strings.TrimRight(suffix[:maxLen], "-_.")
+	}
+	return prefix + suffix
+}
+
+func NewRecyclePolicy(gvrs []schema.GroupVersionResource, targetNamespaces []string) *RecyclePolicy {
 	targetNamespaces = slices.DeleteFunc(targetNamespaces, func(ns string) bool {
 		return ns == metav1.NamespaceAll
 	})
 
-	labels := map[string]string{
-		"krb.wcrum.dev/target-gr": gvr.GroupResource().String(),
+	var rules []RecycleResourceRule
+	for _, gvr := range gvrs {
+		rules = append(rules, RecycleResourceRule{
+			Group:    gvr.Group,
+			Resource: gvr.Resource,
+		})
+	}
+	target := RecycleTarget{
+		Resources:  rules,
+		Namespaces: targetNamespaces,
 	}
-	for _, ns := range targetNamespaces {
-		labels["krb.wcrum.dev/target-namespace-"+ns] = "true"
+
+	name := "recycle-" + rand.String(8)
+	if len(gvrs) == 1 {
+		name = "recycle-" + gvrs[0].Resource + "-" + rand.String(8)
 	}
 
 	return &RecyclePolicy{
@@ -61,20 +157,173 @@ func NewRecyclePolicy(gvr schema.GroupVersionResource, targetNamespaces []string
 			Kind:       RecyclePolicyKind,
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:   "recycle-" + gvr.Resource + "-" + rand.String(8),
-			Labels: labels,
-		},
-		Target: RecycleTarget{
-			Group:      gvr.Group,
-			Resource:   gvr.Resource,
-			Namespaces: targetNamespaces,
+			Name:   name,
+			Labels: target.Labels(),
 		},
+		Target: target,
+	}
+}
+
+// ExpireAt returns the time at which a RecycleItem recycled at the given time expires,
+// and false if the policy has no TTL.
+func (rp *RecyclePolicy) ExpireAt(recycledAt time.Time) (time.Time, bool) {
+	if rp.Retention == nil || rp.Retention.TTL == nil || rp.Retention.TTL.Duration <= 0 {
+		return time.Time{}, false
+	}
+	return recycledAt.Add(rp.Retention.TTL.Duration), true
+}
+
+// MaxItems returns the maximum number of RecycleItems kept for the policy,
+// and false if the number is unlimited.
+func (rp *RecyclePolicy) MaxItems() (int, bool) {
+	if rp.Retention == nil || rp.Retention.MaxItems == nil || *rp.Retention.MaxItems <= 0 {
+		return 0, false
+	}
+	return int(*rp.Retention.MaxItems), true
+}
+
+// DeniesOnRecycleFailure tells whether the policy refuses deletes of objects it cannot recycle.
+func (rp *RecyclePolicy) DeniesOnRecycleFailure() bool {
+	return rp.OnRecycleFailure == RecycleFailureDeny
+}
+
+// SetCondition sets the condition of the given type on the policy status.
+func (rp *RecyclePolicy) SetCondition(conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&rp.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: rp.Generation,
+	})
+}
+
+// ConditionStatus returns the status of the condition of the given type,
+// or unknown if the condition was not reported yet.
+func (rp *RecyclePolicy) ConditionStatus(conditionType string) metav1.ConditionStatus {
+	if condition := meta.FindStatusCondition(rp.Status.Conditions, conditionType); condition != nil {
+		return condition.Status
+	}
+	return metav1.ConditionUnknown
+}
+
+// ExcludedNamespaces returns the namespaces never recycled from by the target, the namespace
+// krb runs in is always excluded so it never recycles its own objects.
+func (rt *RecycleTarget) ExcludedNamespaces() []string {
+	excluded := append([]string{consts.WebhookNamespace}, rt.ExcludeNamespaces...)
+	excluded = slices.DeleteFunc(excluded, func(ns string) bool {
+		return ns == metav1.NamespaceAll
+	})
+	slices.Sort(excluded)
+	return slices.Compact(excluded)
+}
+
+// EffectiveNamespaceSelector merges the namespaces, the namespace selector and the excluded
+// namespaces of the target into a single selector of namespace labels.
+func (rt *RecycleTarget) EffectiveNamespaceSelector() *metav1.LabelSelector {
+	result := &metav1.LabelSelector{}
+	if rt.NamespaceSelector != nil {
+		result = rt.NamespaceSelector.DeepCopy()
+	}
+
+	if len(rt.Namespaces) > 0 && !slices.Contains(rt.Namespaces, metav1.NamespaceAll) && !slices.Contains(rt.Namespaces, "*") {
+		result.MatchExpressions = append(result.MatchExpressions, metav1.LabelSelectorRequirement{
+			Key:      corev1.LabelMetadataName,
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   rt.Namespaces,
+		})
+	}
+
+	// always set, so an empty target never matches the namespace krb runs in.
+	result.MatchExpressions = append(result.MatchExpressions, metav1.LabelSelectorRequirement{
+		Key:      corev1.LabelMetadataName,
+		Operator: metav1.LabelSelectorOpNotIn,
+		Values:   rt.ExcludedNamespaces(),
+	})
+	return result
+}
+
+// Matches tells whether the policy recycles an object of the given resource with the given labels,
+// the same way the api server matches the webhook of the policy. namespaceLabels are the labels of
+// the namespace of the object, nil for cluster-scoped objects which are not namespaces.
+func (rp *RecyclePolicy) Matches(gvr schema.GroupVersionResource, namespaceLabels, objectLabels labels.Set) (bool, error) {
+	if !rp.Target.Targets(gvr) {
+		return false, nil
+	}
+	if namespaceLabels != nil {
+		namespaceSelector, err := metav1.LabelSelectorAsSelector(rp.Target.EffectiveNamespaceSelector())
+		if err != nil {
+			return false, err
+		}
+		if !namespaceSelector.Matches(namespaceLabels) {
+			return false, nil
+		}
+	}
+	if rp.Target.ObjectSelector != nil {
+		objectSelector, err := metav1.LabelSelectorAsSelector(rp.Target.ObjectSelector)
+		if err != nil {
+			return false, err
+		}
+		if !objectSelector.Matches(objectLabels) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Labels returns the labels set on a policy with the target, one per targeted
+// group/resource and one per target namespace.
+func (rt *RecycleTarget) Labels() map[string]string {
+	result := map[string]string{}
+	for _, gr := range rt.GroupResources() {
+		result[TargetGroupResourceLabel(gr)] = "true"
+	}
+	for _, ns := range rt.Namespaces {
+		if ns != metav1.NamespaceAll {
+			result[labelKeyWithSuffix("krb.wcrum.dev/target-namespace-", ns)] = "true"
+		}
+	}
+	return result
+}
+
+// ResourceRules returns all group/resource rules of the target, including the
+// legacy Group and Resource fields when set.
+func (rt *RecycleTarget) ResourceRules() []RecycleResourceRule {
+	rules := make([]RecycleResourceRule, 0, len(rt.Resources)+1)
+	if rt.Resource != "" {
+		rules = append(rules, RecycleResourceRule{
+			Group:    rt.Group,
+			Resource: rt.Resource,
+		})
+	}
+	return append(rules, rt.Resources...)
+}
+
+// GroupResources returns the distinct group/resources targeted by the target.
+func (rt *RecycleTarget) GroupResources() []schema.GroupResource {
+	var grs []schema.GroupResource
+	for _, rule := range rt.ResourceRules() {
+		if gr := rule.GroupResource(); !slices.Contains(grs, gr) {
+			grs = append(grs, gr)
+		}
+	}
+	return grs
+}
+
+// Targets tells whether the target covers the given group/version/resource.
+func (rt *RecycleTarget) Targets(gvr schema.GroupVersionResource) bool {
+	for _, rule := range rt.ResourceRules() {
+		if rule.GroupResource() == gvr.GroupResource() &&
+			(len(rule.Versions) == 0 || slices.Contains(rule.Versions, gvr.Version)) {
+			return true
+		}
 	}
+	return false
 }
 
-func (rt *RecycleTarget) GroupResource() schema.GroupResource {
+func (rr *RecycleResourceRule) GroupResource() schema.GroupResource {
 	return schema.GroupResource{
-		Group:    rt.Group,
-		Resource: rt.Resource,
+		Group:    rr.Group,
+		Resource: rr.Resource,
 	}
 }
@@ -0,0 +1,180 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var deployments = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
+// namespaceLabels returns the labels of the namespace of the name, as the api server sets them.
+func namespaceLabels(name string, set labels.Set) labels.Set {
+	return labels.Merge(set, labels.Set{corev1.LabelMetadataName: name})
+}
+
+func TestMatchesNamespaces(t *testing.T) {
+	tests := []struct {
+		name      string
+		target    RecycleTarget
+		namespace labels.Set
+		want      bool
+	}{
+		{
+			name:      "every namespace",
+			target:    RecycleTarget{},
+			namespace: namespaceLabels("dev", nil),
+			want:      true,
+		},
+		{
+			name:      "krb-system never",
+			target:    RecycleTarget{},
+			namespace: namespaceLabels("krb-system", nil),
+		},
+		{
+			name:      "krb-system never, even listed",
+			target:    RecycleTarget{Namespaces: []string{"krb-system", "dev"}},
+			namespace: namespaceLabels("krb-system", nil),
+		},
+		{
+			name:      "krb-system never, even selected",
+			target:    RecycleTarget{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}},
+			namespace: namespaceLabels("krb-system", labels.Set{"env": "prod"}),
+		},
+		{
+			name:      "listed namespace",
+			target:    RecycleTarget{Namespaces: []string{"dev"}},
+			namespace: namespaceLabels("dev", nil),
+			want:      true,
+		},
+		{
+			name:      "unlisted namespace",
+			target:    RecycleTarget{Namespaces: []string{"dev"}},
+			namespace: namespaceLabels("prod", nil),
+		},
+		{
+			name:      "selected namespace",
+			target:    RecycleTarget{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}},
+			namespace: namespaceLabels("payments", labels.Set{"env": "prod"}),
+			want:      true,
+		},
+		{
+			name:      "unselected namespace",
+			target:    RecycleTarget{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}},
+			namespace: namespaceLabels("payments", labels.Set{"env": "dev"}),
+		},
+		{
+			name:      "listed and selected namespace",
+			target:    RecycleTarget{Namespaces: []string{"payments"}, NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}},
+			namespace: namespaceLabels("payments", labels.Set{"env": "prod"}),
+			want:      true,
+		},
+		{
+			name:      "listed but unselected namespace",
+			target:    RecycleTarget{Namespaces: []string{"payments"}, NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}},
+			namespace: namespaceLabels("payments", labels.Set{"env": "dev"}),
+		},
+		{
+			name:      "excluded namespace, even listed",
+			target:    RecycleTarget{Namespaces: []string{"kube-system", "dev"}, ExcludeNamespaces: []string{"kube-system"}},
+			namespace: namespaceLabels("kube-system", nil),
+		},
+		{
+			name:      "excluded namespace, even selected",
+			target:    RecycleTarget{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}, ExcludeNamespaces: []string{"payments"}},
+			namespace: namespaceLabels("payments", labels.Set{"env": "prod"}),
+		},
+		{
+			name:   "cluster-scoped objects have no namespace to exclude",
+			target: RecycleTarget{Namespaces: []string{"dev"}, ExcludeNamespaces: []string{"kube-system"}},
+			want:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recyclePolicy := &RecyclePolicy{Target: tt.target}
+			recyclePolicy.Target.Resources = []RecycleResourceRule{{Group: "apps", Resource: "deployments"}}
+
+			got, err := recyclePolicy.Matches(deployments, tt.namespace, nil)
+			if err != nil {
+				t.Fatalf("✗ failed to match: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("✗ expected match %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEffectiveNamespaceSelector(t *testing.T) {
+	target := RecycleTarget{
+		Namespaces:        []string{"dev", "krb-system"},
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+		ExcludeNamespaces: []string{"kube-system", "krb-system"},
+	}
+
+	selector := target.EffectiveNamespaceSelector()
+	want := &metav1.LabelSelector{
+		MatchLabels: map[string]string{"env": "prod"},
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: corev1.LabelMetadataName, Operator: metav1.LabelSelectorOpIn, Values: []string{"dev", "krb-system"}},
+			{Key: corev1.LabelMetadataName, Operator: metav1.LabelSelectorOpNotIn, Values: []string{"krb-system", "kube-system"}},
+		},
+	}
+	if got, want := selector.String(), want.String(); got != want {
+		t.Errorf("✗ expected selector %s, got %s", want, got)
+	}
+	// the selector of the target is copied, not changed.
+	if len(target.NamespaceSelector.MatchExpressions) != 0 {
+		t.Errorf("✗ expected the namespace selector of the target untouched, got %v", target.NamespaceSelector)
+	}
+}
+
+func TestMatchesObjectSelector(t *testing.T) {
+	recyclePolicy := &RecyclePolicy{Target: RecycleTarget{
+		Resources:      []RecycleResourceRule{{Group: "apps", Resource: "deployments"}},
+		ObjectSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}},
+	}}
+
+	tests := []struct {
+		name   string
+		object labels.Set
+		want   bool
+	}{
+		{name: "labelled object", object: labels.Set{"team": "payments", "app": "api"}, want: true},
+		{name: "object of another team", object: labels.Set{"team": "search"}},
+		{name: "unlabelled object"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := recyclePolicy.Matches(deployments, namespaceLabels("dev", nil), tt.object)
+			if err != nil {
+				t.Fatalf("✗ failed to match: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("✗ expected match %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -18,9 +18,12 @@ package client
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/wcrum/kube-recycle-bin/internal/api"
+	"github.com/wcrum/kube-recycle-bin/pkg/kube"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -32,17 +35,57 @@ func init() {
 	api.AddToScheme(scheme)
 }
 
-var (
-	cli              client.Client
-	recycleItemCli   RecycleItemInterface
-	recyclePolicyCli RecyclePolicyInterface
-)
+// Scheme returns the scheme of the kube-recycle-bin api.
+func Scheme() *runtime.Scheme {
+	return scheme
+}
+
+// Clients are the clients of a cluster, including the ones of the kube-recycle-bin api.
+type Clients struct {
+	*kube.Clients
+	recycleItem   RecycleItemInterface
+	recyclePolicy RecyclePolicyInterface
+}
+
+// NewClients returns the clients of the cluster of the config.
+func NewClients(config *rest.Config) (*Clients, error) {
+	kubeClients, err := kube.NewClients(config)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	return NewClientsFor(kubeClients, cli), nil
+}
+
+// NewClientsFor returns the clients of the kube clients, serving the kube-recycle-bin api
+// with cli, whose scheme must include it.
+func NewClientsFor(kubeClients *kube.Clients, cli client.Client) *Clients {
+	return &Clients{
+		Clients:       kubeClients,
+		recycleItem:   &recycleItemClient{Client: cli},
+		recyclePolicy: &recyclePolicyClient{Client: cli},
+	}
+}
+
+// RecycleItem returns the client of RecycleItems.
+func (c *Clients) RecycleItem() RecycleItemInterface {
+	return c.recycleItem
+}
+
+// RecyclePolicy returns the client of RecyclePolicies.
+func (c *Clients) RecyclePolicy() RecyclePolicyInterface {
+	return c.recyclePolicy
+}
 
 type RecycleItemInterface interface {
 	Create(ctx context.Context, obj *api.RecycleItem, opts client.CreateOptions) error
 	Get(ctx context.Context, name string, opts client.GetOptions) (*api.RecycleItem, error)
 	List(ctx context.Context, opts client.ListOptions) (*api.RecycleItemList, error)
 	Update(ctx context.Context, obj *api.RecycleItem, opts client.UpdateOptions) error
+	UpdateStatus(ctx context.Context, obj *api.RecycleItem, opts client.SubResourceUpdateOptions) error
 	Delete(ctx context.Context, name string, opts client.DeleteOptions) error
 }
 
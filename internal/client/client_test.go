@@ -21,11 +21,29 @@ import (
 	"testing"
 
 	"github.com/wcrum/kube-recycle-bin/internal/api"
+	"github.com/wcrum/kube-recycle-bin/pkg/kube"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// clusterClients returns the clients of the cluster of the kubeconfig.
+func clusterClients(t *testing.T) *Clients {
+	t.Helper()
+
+	config, err := kube.RestConfig()
+	if err != nil {
+		t.Fatalf("✗ %v", err)
+	}
+	clients, err := NewClients(config)
+	if err != nil {
+		t.Fatalf("✗ %v", err)
+	}
+	return clients
+}
+
 func TestCreateRecycleItem(t *testing.T) {
+	clients := clusterClients(t)
+
 	// Create a new RecycleItem object
 	obj := &api.RecycleItem{
 		TypeMeta: metav1.TypeMeta{
@@ -70,24 +88,26 @@ spec:
 	}
 
 	// Create the RecycleItem object
-	if err := RecycleItem().Create(context.Background(), obj, client.CreateOptions{}); err != nil {
+	if err := clients.RecycleItem().Create(context.Background(), obj, client.CreateOptions{}); err != nil {
 		t.Fatalf("✗ failed to create RecycleItem object: %v", err)
 	}
 
 	// Retrieve the RecycleItem object
-	if got, err := RecycleItem().Get(context.Background(), obj.Name, client.GetOptions{}); err != nil {
+	if got, err := clients.RecycleItem().Get(context.Background(), obj.Name, client.GetOptions{}); err != nil {
 		t.Fatalf("✗ failed to get RecycleItem object: %v", err)
 	} else if got.Name != obj.Name {
 		t.Errorf("got name %s, want %s", got.Name, obj.Name)
 	}
 
 	// Clean up the RecycleItem object
-	if err := RecycleItem().Delete(context.Background(), obj.Name, client.DeleteOptions{}); err != nil {
+	if err := clients.RecycleItem().Delete(context.Background(), obj.Name, client.DeleteOptions{}); err != nil {
 		t.Fatalf("✗ failed to delete RecycleItem object: %v", err)
 	}
 }
 
 func TestCreateRecyclePolicy(t *testing.T) {
+	clients := clusterClients(t)
+
 	// Create a new RecyclePolicy object
 	obj := &api.RecyclePolicy{
 		TypeMeta: metav1.TypeMeta{
@@ -105,19 +125,19 @@ func TestCreateRecyclePolicy(t *testing.T) {
 	}
 
 	// Create the RecyclePolicy object
-	if err := RecyclePolicy().Create(context.Background(), obj, client.CreateOptions{}); err != nil {
+	if err := clients.RecyclePolicy().Create(context.Background(), obj, client.CreateOptions{}); err != nil {
 		t.Fatalf("✗ failed to create RecyclePolicy object: %v", err)
 	}
 
 	// Retrieve the RecyclePolicy object
-	if got, err := RecyclePolicy().Get(context.Background(), obj.Name, client.GetOptions{}); err != nil {
+	if got, err := clients.RecyclePolicy().Get(context.Background(), obj.Name, client.GetOptions{}); err != nil {
 		t.Fatalf("✗ failed to get RecyclePolicy object: %v", err)
 	} else if got.Name != obj.Name {
 		t.Errorf("✗ got name %s, want %s", got.Name, obj.Name)
 	}
 
 	// Clean up the RecyclePolicy object
-	if err := RecyclePolicy().Delete(context.Background(), obj.Name, client.DeleteOptions{}); err != nil {
+	if err := clients.RecyclePolicy().Delete(context.Background(), obj.Name, client.DeleteOptions{}); err != nil {
 		t.Fatalf("✗ failed to delete RecyclePolicy object: %v", err)
 	}
 }
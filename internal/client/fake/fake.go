@@ -0,0 +1,205 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake provides Clients backed by fakes, for unit tests.
+package fake
+
+import (
+	"context"
+
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+	krbclient "github.com/wcrum/kube-recycle-bin/internal/client"
+	"github.com/wcrum/kube-recycle-bin/pkg/kube"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	fakekubernetes "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// NewClients returns Clients backed by fakes serving the objects.
+func NewClients(objects ...runtime.Object) *krbclient.Clients {
+	return NewClientsWithInterceptor(interceptor.Funcs{}, objects...)
+}
+
+// NewClientsWithInterceptor returns Clients backed by fakes serving the objects, whose calls to
+// the kube-recycle-bin api go through the interceptor funcs.
+//
+// RecycleItems and RecyclePolicies are served by the kube-recycle-bin client, unstructured objects
+// by the dynamic client and other objects by the kubernetes client. Discovery serves namespaces,
+// configmaps, secrets, services, pods, deployments and the kube-recycle-bin api. Dry runs of the
+// dynamic client persist nothing.
+func NewClientsWithInterceptor(funcs interceptor.Funcs, objects ...runtime.Object) *krbclient.Clients {
+	var krbObjects []client.Object
+	var dynamicObjects, kubernetesObjects []runtime.Object
+	for _, obj := range objects {
+		switch obj := obj.(type) {
+		case *api.RecycleItem, *api.RecyclePolicy:
+			krbObjects = append(krbObjects, obj.(client.Object))
+		case *unstructured.Unstructured:
+			dynamicObjects = append(dynamicObjects, obj)
+		default:
+			kubernetesObjects = append(kubernetesObjects, obj)
+		}
+	}
+
+	kubernetesClient := fakekubernetes.NewClientset(kubernetesObjects...)
+	kubernetesClient.Resources = resources()
+
+	cli := fakeclient.NewClientBuilder().
+		WithScheme(krbclient.Scheme()).
+		WithObjects(krbObjects...).
+		WithStatusSubresource(&api.RecycleItem{}).
+		WithInterceptorFuncs(funcs).
+		Build()
+
+	dynamicClient := fakedynamic.NewSimpleDynamicClient(scheme.Scheme, dynamicObjects...)
+	dynamicClient.PrependReactor("patch", "*", applyReactor(dynamicClient.Tracker()))
+
+	return krbclient.NewClientsFor(&kube.Clients{
+		Kubernetes: kubernetesClient,
+		Dynamic:    dryRunDynamicClient{dynamicClient},
+		Discovery:  kube.NewCachedDiscovery(kubernetesClient.Discovery(), kube.DefaultDiscoveryTTL),
+	}, cli)
+}
+
+// applyReactor serves the server-side apply patches of the dynamic client, which its tracker
+// cannot merge into unstructured objects, by replacing the object with the applied one as a
+// forced apply of a whole object does.
+func applyReactor(tracker clienttesting.ObjectTracker) clienttesting.ReactionFunc {
+	return func(action clienttesting.Action) (bool, runtime.Object, error) {
+		patch, ok := action.(clienttesting.PatchAction)
+		if !ok || patch.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(patch.GetPatch()); err != nil {
+			return true, nil, err
+		}
+		obj.SetNamespace(patch.GetNamespace())
+		obj.SetName(patch.GetName())
+
+		err := tracker.Update(patch.GetResource(), obj, patch.GetNamespace())
+		if apierrors.IsNotFound(err) {
+			err = tracker.Create(patch.GetResource(), obj, patch.GetNamespace())
+		}
+		if err != nil {
+			return true, nil, err
+		}
+		applied, err := tracker.Get(patch.GetResource(), patch.GetNamespace(), patch.GetName())
+		return true, applied, err
+	}
+}
+
+// dryRunDynamicClient is a dynamic client whose dry run creates and applies persist nothing,
+// the fake dynamic client ignores the options of its calls.
+type dryRunDynamicClient struct {
+	dynamic.Interface
+}
+
+func (c dryRunDynamicClient) Resource(resource schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return dryRunNamespaceableResource{
+		NamespaceableResourceInterface: c.Interface.Resource(resource),
+		resource:                       resource,
+	}
+}
+
+// dryRunNamespaceableResource is a resource of a dryRunDynamicClient.
+type dryRunNamespaceableResource struct {
+	dynamic.NamespaceableResourceInterface
+	resource schema.GroupVersionResource
+}
+
+func (r dryRunNamespaceableResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return dryRunResource{ResourceInterface: r.NamespaceableResourceInterface.Namespace(namespace), resource: r.resource}
+}
+
+func (r dryRunNamespaceableResource) Create(ctx context.Context, obj *unstructured.Unstructured, opts metav1.CreateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return dryRunResource{ResourceInterface: r.NamespaceableResourceInterface, resource: r.resource}.Create(ctx, obj, opts, subresources...)
+}
+
+func (r dryRunNamespaceableResource) Apply(ctx context.Context, name string, obj *unstructured.Unstructured, opts metav1.ApplyOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return dryRunResource{ResourceInterface: r.NamespaceableResourceInterface, resource: r.resource}.Apply(ctx, name, obj, opts, subresources...)
+}
+
+// dryRunResource is a resource of a dryRunDynamicClient in a namespace. Dry runs return the
+// object as the api server would persist it, failing creates of existing objects.
+type dryRunResource struct {
+	dynamic.ResourceInterface
+	resource schema.GroupVersionResource
+}
+
+func (r dryRunResource) Create(ctx context.Context, obj *unstructured.Unstructured, opts metav1.CreateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if len(opts.DryRun) == 0 {
+		return r.ResourceInterface.Create(ctx, obj, opts, subresources...)
+	}
+	_, err := r.ResourceInterface.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err == nil {
+		return nil, apierrors.NewAlreadyExists(r.resource.GroupResource(), obj.GetName())
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	return obj.DeepCopy(), nil
+}
+
+func (r dryRunResource) Apply(ctx context.Context, name string, obj *unstructured.Unstructured, opts metav1.ApplyOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if len(opts.DryRun) == 0 {
+		return r.ResourceInterface.Apply(ctx, name, obj, opts, subresources...)
+	}
+	// a forced apply of a whole object replaces it, see applyReactor.
+	applied := obj.DeepCopy()
+	applied.SetName(name)
+	return applied, nil
+}
+
+// resources are the api resources served by the fake discovery.
+func resources() []*metav1.APIResourceList {
+	return []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "namespaces", SingularName: "namespace", ShortNames: []string{"ns"}, Kind: "Namespace", Namespaced: false},
+				{Name: "configmaps", SingularName: "configmap", ShortNames: []string{"cm"}, Kind: "ConfigMap", Namespaced: true},
+				{Name: "secrets", SingularName: "secret", Kind: "Secret", Namespaced: true},
+				{Name: "services", SingularName: "service", ShortNames: []string{"svc"}, Kind: "Service", Namespaced: true},
+				{Name: "pods", SingularName: "pod", ShortNames: []string{"po"}, Kind: "Pod", Namespaced: true},
+			},
+		},
+		{
+			GroupVersion: "apps/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "deployments", SingularName: "deployment", ShortNames: []string{"deploy"}, Kind: "Deployment", Namespaced: true},
+			},
+		},
+		{
+			GroupVersion: api.GroupVersion.String(),
+			APIResources: []metav1.APIResource{
+				{Name: "recycleitems", SingularName: "recycleitem", ShortNames: []string{"ri"}, Kind: api.RecycleItemKind, Namespaced: false},
+				{Name: "recyclepolicies", SingularName: "recyclepolicy", ShortNames: []string{"rp"}, Kind: api.RecyclePolicyKind, Namespaced: false},
+			},
+		},
+	}
+}
@@ -20,8 +20,6 @@ import (
 	"context"
 
 	"github.com/wcrum/kube-recycle-bin/internal/api"
-	"github.com/wcrum/kube-recycle-bin/pkg/kube"
-	"github.com/wcrum/kube-recycle-bin/pkg/tlog"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -31,23 +29,6 @@ type recycleItemClient struct {
 	client.Client
 }
 
-func RecycleItem() RecycleItemInterface {
-	if recycleItemCli == nil {
-		if cli == nil {
-			var err error
-			cli, err = client.New(kube.RestConfig(), client.Options{Scheme: scheme})
-			if err != nil {
-				tlog.Fatalf("✗ failed to create client: %v", err)
-			}
-		}
-
-		recycleItemCli = &recycleItemClient{
-			Client: cli,
-		}
-	}
-	return recycleItemCli
-}
-
 func (c *recycleItemClient) Create(ctx context.Context, obj *api.RecycleItem, opts client.CreateOptions) error {
 	return c.Client.Create(ctx, obj, &opts)
 }
@@ -77,6 +58,13 @@ func (c *recycleItemClient) Update(ctx context.Context, obj *api.RecycleItem, op
 	return nil
 }
 
+func (c *recycleItemClient) UpdateStatus(ctx context.Context, obj *api.RecycleItem, opts client.SubResourceUpdateOptions) error {
+	if err := c.Client.Status().Update(ctx, obj, &opts); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (c *recycleItemClient) Delete(ctx context.Context, name string, opts client.DeleteOptions) error {
 	if err := c.Client.Delete(ctx, &api.RecycleItem{
 		ObjectMeta: metav1.ObjectMeta{
@@ -20,8 +20,6 @@ import (
 	"context"
 
 	"github.com/wcrum/kube-recycle-bin/internal/api"
-	"github.com/wcrum/kube-recycle-bin/pkg/kube"
-	"github.com/wcrum/kube-recycle-bin/pkg/tlog"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -31,23 +29,6 @@ type recyclePolicyClient struct {
 	client.Client
 }
 
-func RecyclePolicy() RecyclePolicyInterface {
-	if recyclePolicyCli == nil {
-		if cli == nil {
-			var err error
-			cli, err = client.New(kube.RestConfig(), client.Options{Scheme: scheme})
-			if err != nil {
-				tlog.Fatalf("✗ failed to create client: %v", err)
-			}
-		}
-
-		recyclePolicyCli = &recyclePolicyClient{
-			Client: cli,
-		}
-	}
-	return recyclePolicyCli
-}
-
 func (c *recyclePolicyClient) Create(ctx context.Context, obj *api.RecyclePolicy, opts client.CreateOptions) error {
 	return c.Client.Create(ctx, obj, &opts)
 }
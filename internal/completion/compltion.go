@@ -21,22 +21,39 @@ import (
 	"slices"
 
 	krbclient "github.com/wcrum/kube-recycle-bin/internal/client"
-	"github.com/wcrum/kube-recycle-bin/pkg/kube"
-	"github.com/wcrum/kube-recycle-bin/pkg/tlog"
+	"github.com/wcrum/kube-recycle-bin/pkg/logging"
 	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	client "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// Completer provides the shell completion functions reading the cluster.
+type Completer struct {
+	clients func() (*krbclient.Clients, error)
+}
+
+// New returns a Completer reading the cluster with the clients returned by the function, only
+// called when completing.
+func New(clients func() (*krbclient.Clients, error)) *Completer {
+	return &Completer{clients: clients}
+}
+
 // None is a shell completion function that does nothing.
 func None(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	return nil, cobra.ShellCompDirectiveNoFileComp
 }
 
-func RecycleItemGroupResource(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	list, err := krbclient.RecycleItem().List(context.Background(), client.ListOptions{})
+func (c *Completer) RecycleItemGroupResource(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	clients, err := c.clients()
+	if err != nil {
+		logging.Logger().Error(err, "failed to create clients")
+		return nil, cobra.ShellCompDirectiveError
+	}
+	list, err := clients.RecycleItem().List(context.Background(), client.ListOptions{})
 	if err != nil {
-		tlog.Printf("✗ failed to list recycle items: %v", err)
+		logging.Logger().Error(err, "failed to list recycle items")
 		return nil, cobra.ShellCompDirectiveError
 	}
 
@@ -48,10 +65,15 @@ func RecycleItemGroupResource(cmd *cobra.Command, args []string, toComplete stri
 	return result, cobra.ShellCompDirectiveNoFileComp
 }
 
-func RecycleItemNamespace(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	list, err := krbclient.RecycleItem().List(context.Background(), client.ListOptions{})
+func (c *Completer) RecycleItemNamespace(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	clients, err := c.clients()
 	if err != nil {
-		tlog.Printf("✗ failed to list recycle items: %v", err)
+		logging.Logger().Error(err, "failed to create clients")
+		return nil, cobra.ShellCompDirectiveError
+	}
+	list, err := clients.RecycleItem().List(context.Background(), client.ListOptions{})
+	if err != nil {
+		logging.Logger().Error(err, "failed to list recycle items")
 		return nil, cobra.ShellCompDirectiveError
 	}
 
@@ -63,15 +85,42 @@ func RecycleItemNamespace(cmd *cobra.Command, args []string, toComplete string)
 	return result, cobra.ShellCompDirectiveNoFileComp
 }
 
+// Namespace is a shell completion function that lists all namespaces of the cluster.
+func (c *Completer) Namespace(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	clients, err := c.clients()
+	if err != nil {
+		logging.Logger().Error(err, "failed to create clients")
+		return nil, cobra.ShellCompDirectiveError
+	}
+	list, err := clients.Kubernetes.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		logging.Logger().Error(err, "failed to list namespaces")
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var result []string
+	for _, namespace := range list.Items {
+		result = append(result, namespace.Name)
+	}
+
+	return result, cobra.ShellCompDirectiveNoFileComp
+}
+
 // KubeGroupResources is a shell completion function that lists all group resources.
-func KubeGroupResources(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+func (c *Completer) KubeGroupResources(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	if len(args) > 0 {
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
 
-	resources, err := kube.GetAllGroupResources()
+	clients, err := c.clients()
 	if err != nil {
-		tlog.Printf("✗ failed to get all group resources: %v", err)
+		logging.Logger().Error(err, "failed to create clients")
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	resources, err := clients.Discovery.GetAllGroupResources()
+	if err != nil {
+		logging.Logger().Error(err, "failed to get all group resources")
 		return nil, cobra.ShellCompDirectiveError
 	}
 
@@ -87,7 +136,13 @@ func KubeGroupResources(cmd *cobra.Command, args []string, toComplete string) ([
 }
 
 // RecycleItem is a shell completion function that lists all recycle items.
-func RecycleItem(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+func (c *Completer) RecycleItem(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	clients, err := c.clients()
+	if err != nil {
+		logging.Logger().Error(err, "failed to create clients")
+		return nil, cobra.ShellCompDirectiveError
+	}
+
 	labelSet := labels.Set{}
 	objectNamespace, _ := cmd.Flags().GetString("object-namespace")
 	if objectNamespace != "" {
@@ -95,18 +150,18 @@ func RecycleItem(cmd *cobra.Command, args []string, toComplete string) ([]string
 	}
 	objectResource, _ := cmd.Flags().GetString("object-resource")
 	if objectResource != "" {
-		if gvr, err := kube.GetPreferredGroupVersionResourceFor(objectResource); err != nil {
-			tlog.Printf("✗ failed to get preferred group version resource: %v", err)
+		if gvr, err := clients.Discovery.GetPreferredGroupVersionResourceFor(objectResource); err != nil {
+			logging.Logger().Error(err, "failed to get preferred group version resource")
 		} else {
 			labelSet["krb.wcrum.dev/object-gr"] = gvr.GroupResource().String()
 		}
 	}
 
-	list, err := krbclient.RecycleItem().List(context.Background(), client.ListOptions{
+	list, err := clients.RecycleItem().List(context.Background(), client.ListOptions{
 		LabelSelector: labels.SelectorFromSet(labelSet),
 	})
 	if err != nil {
-		tlog.Printf("✗ failed to list recycle items: %v", err)
+		logging.Logger().Error(err, "failed to list recycle items")
 		return nil, cobra.ShellCompDirectiveError
 	}
 
@@ -121,25 +176,39 @@ func RecycleItem(cmd *cobra.Command, args []string, toComplete string) ([]string
 	return result, cobra.ShellCompDirectiveNoFileComp
 }
 
-func RecyclePolicyGroupResource(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	list, err := krbclient.RecyclePolicy().List(context.Background(), client.ListOptions{})
+func (c *Completer) RecyclePolicyGroupResource(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	clients, err := c.clients()
 	if err != nil {
-		tlog.Printf("✗ failed to list recycle items: %v", err)
+		logging.Logger().Error(err, "failed to create clients")
+		return nil, cobra.ShellCompDirectiveError
+	}
+	list, err := clients.RecyclePolicy().List(context.Background(), client.ListOptions{})
+	if err != nil {
+		logging.Logger().Error(err, "failed to list recycle items")
 		return nil, cobra.ShellCompDirectiveError
 	}
 
 	var result []string
 	for _, item := range list.Items {
-		result = append(result, item.Target.GroupResource().String())
+		for _, gr := range item.Target.GroupResources() {
+			if !slices.Contains(result, gr.String()) {
+				result = append(result, gr.String())
+			}
+		}
 	}
 
 	return result, cobra.ShellCompDirectiveNoFileComp
 }
 
-func RecyclePolicyNamespace(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	ri, err := krbclient.RecyclePolicy().List(context.Background(), client.ListOptions{})
+func (c *Completer) RecyclePolicyNamespace(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	clients, err := c.clients()
+	if err != nil {
+		logging.Logger().Error(err, "failed to create clients")
+		return nil, cobra.ShellCompDirectiveError
+	}
+	ri, err := clients.RecyclePolicy().List(context.Background(), client.ListOptions{})
 	if err != nil {
-		tlog.Printf("✗ failed to list recycle items: %v", err)
+		logging.Logger().Error(err, "failed to list recycle items")
 		return nil, cobra.ShellCompDirectiveError
 	}
 
@@ -157,26 +226,28 @@ func RecyclePolicyNamespace(cmd *cobra.Command, args []string, toComplete string
 }
 
 // RecyclePolicy is a shell completion function that lists all recycle policies.
-func RecyclePolicy(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	labelSet := labels.Set{}
-	targetNamespace, _ := cmd.Flags().GetString("target-namespace")
-	if targetNamespace != "" {
-		labelSet["krb.wcrum.dev/target-namespace"] = targetNamespace
+func (c *Completer) RecyclePolicy(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	clients, err := c.clients()
+	if err != nil {
+		logging.Logger().Error(err, "failed to create clients")
+		return nil, cobra.ShellCompDirectiveError
 	}
+
+	targetNamespace, _ := cmd.Flags().GetString("target-namespace")
+	var targetGR *schema.GroupResource
 	targetResource, _ := cmd.Flags().GetString("target-resource")
 	if targetResource != "" {
-		if gvr, err := kube.GetPreferredGroupVersionResourceFor(targetResource); err != nil {
-			tlog.Printf("✗ failed to get preferred group version resource: %v", err)
+		if gvr, err := clients.Discovery.GetPreferredGroupVersionResourceFor(targetResource); err != nil {
+			logging.Logger().Error(err, "failed to get preferred group version resource")
 		} else {
-			labelSet["krb.wcrum.dev/target-gr"] = gvr.GroupResource().String()
+			gr := gvr.GroupResource()
+			targetGR = &gr
 		}
 	}
 
-	list, err := krbclient.RecyclePolicy().List(context.Background(), client.ListOptions{
-		LabelSelector: labels.SelectorFromSet(labelSet),
-	})
+	list, err := clients.RecyclePolicy().List(context.Background(), client.ListOptions{})
 	if err != nil {
-		tlog.Printf("✗ failed to list recycle policies: %v", err)
+		logging.Logger().Error(err, "failed to list recycle policies")
 		return nil, cobra.ShellCompDirectiveError
 	}
 
@@ -185,6 +256,12 @@ func RecyclePolicy(cmd *cobra.Command, args []string, toComplete string) ([]stri
 		if slices.Contains(args, obj.Name) {
 			continue
 		}
+		if targetNamespace != "" && !slices.Contains(obj.Target.Namespaces, targetNamespace) {
+			continue
+		}
+		if targetGR != nil && !slices.Contains(obj.Target.GroupResources(), *targetGR) {
+			continue
+		}
 		result = append(result, obj.Name)
 	}
 
@@ -17,11 +17,18 @@ limitations under the License.
 package consts
 
 const (
-	WebhookNamespace          = "krb-system"
-	WebhookName               = "krb-webhook"
-	WebhookTLSCertSecretName  = "krb-webhook-tls"
-	WebhookServicePath        = "/validate"
-	WebhookServiceTLSCertFile = "tls.crt"
-	WebhookServiceTLSKeyFile  = "tls.key"
-	WebhookDNSName            = "krb-webhook.krb-system.svc"
+	WebhookNamespace         = "krb-system"
+	WebhookName              = "krb-webhook"
+	WebhookTLSCertSecretName = "krb-webhook-tls"
+	WebhookServicePath       = "/validate"
+	WebhookDNSName           = "krb-webhook.krb-system.svc"
+	// WebhookCertificateName is the cert-manager Certificate issuing the webhook TLS secret in cert-manager mode.
+	WebhookCertificateName = "krb-webhook"
+)
+
+const (
+	// CertManagerEnv enables cert-manager mode of krb-webhook and krb-controller when set to true.
+	CertManagerEnv = "KRB_CERT_MANAGER"
+	// CertManagerInjectCAFromAnnotation asks the cert-manager CA injector to embed the CA of a Certificate.
+	CertManagerInjectCAFromAnnotation = "cert-manager.io/inject-ca-from"
 )
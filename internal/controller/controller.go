@@ -19,23 +19,29 @@ package controller
 import (
 	"crypto/tls"
 	"flag"
+	"os"
 	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	// _ "k8s.io/client-go/plugin/pkg/client/auth"
 
-	"github.com/go-logr/logr"
 	"github.com/wcrum/kube-recycle-bin/internal/api"
-	"github.com/wcrum/kube-recycle-bin/pkg/tlog"
+	"github.com/wcrum/kube-recycle-bin/internal/consts"
+	"github.com/wcrum/kube-recycle-bin/internal/metrics"
+	"github.com/wcrum/kube-recycle-bin/pkg/kube"
+	"github.com/wcrum/kube-recycle-bin/pkg/logging"
 	"github.com/wcrum/kube-recycle-bin/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
-	"sigs.k8s.io/controller-runtime/pkg/log"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
@@ -45,8 +51,6 @@ var (
 )
 
 func init() {
-	log.SetLogger(logr.New(log.NullLogSink{}))
-
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(api.AddToScheme(scheme))
 }
@@ -57,6 +61,8 @@ func Run() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var certManagerMode bool
+	var webhookURL string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
@@ -66,6 +72,16 @@ func Run() {
 		"If set the metrics endpoint is served securely")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.BoolVar(&certManagerMode, "cert-manager", util.BoolEnv(consts.CertManagerEnv),
+		"If set, let cert-manager inject the webhook CA bundle instead of embedding it. Defaults to $"+consts.CertManagerEnv+".")
+	flag.StringVar(&webhookURL, "webhook-url", "",
+		"The base URL the api server calls krb-webhook at, such as https://host:port for a webhook running outside of the cluster. Defaults to the krb-webhook Service.")
+	logOptions := logging.Options{Format: logging.FormatText}
+	logOptions.AddFlags(flag.CommandLine)
+	flag.Parse()
+
+	// controller-runtime logs through the same logger, with the fields of each reconcile.
+	logger := logging.Setup(logOptions).WithName("controller")
 
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
@@ -74,7 +90,7 @@ func Run() {
 	// - https://github.com/advisories/GHSA-qppj-fm5r-hxr3
 	// - https://github.com/advisories/GHSA-4374-p667-p6c8
 	disableHTTP2 := func(c *tls.Config) {
-		tlog.Infof("⚒︎ disabling http/2")
+		logger.Info("disabling http/2")
 		c.NextProtos = []string{"http/1.1"}
 	}
 
@@ -87,7 +103,13 @@ func Run() {
 		TLSOpts: tlsOpts,
 	})
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	config, err := kube.RestConfig()
+	if err != nil {
+		logger.Error(err, "failed to load kubeconfig")
+		os.Exit(1)
+	}
+
+	mgr, err := ctrl.NewManager(config, ctrl.Options{
 		Scheme: scheme,
 		Metrics: metricsserver.Options{
 			BindAddress:   metricsAddr,
@@ -113,29 +135,53 @@ func Run() {
 		// Use a cache that syncs every 8 hours
 		Cache: cache.Options{
 			SyncPeriod: util.Ptr(time.Hour * 8),
+			// only the webhook TLS secret is watched, don't cache every secret of the cluster.
+			ByObject: map[client.Object]cache.ByObject{
+				&corev1.Secret{}: {
+					Namespaces: map[string]cache.Config{consts.WebhookNamespace: {}},
+					Field:      fields.OneTermEqualSelector("metadata.name", consts.WebhookTLSCertSecretName),
+				},
+			},
 		},
 	})
 	if err != nil {
-		tlog.Fatalf("✗ failed to start manager: %v", err)
+		logger.Error(err, "failed to start manager")
+		os.Exit(1)
 	}
 
+	// stored RecycleItems are reported next to the controller-runtime metrics.
+	ctrlmetrics.Registry.MustRegister(metrics.NewRecycleItemsCollector(mgr.GetClient()))
+
 	if err = (&RecyclePolicyReconciler{
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		CertManager: certManagerMode,
+		WebhookURL:  webhookURL,
+	}).SetupWithManager(mgr); err != nil {
+		logger.Error(err, "failed to setup RecyclePolicy controller")
+		os.Exit(1)
+	}
+	if err = (&RecycleItemReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		tlog.Fatalf("✗ failed to setup RecyclePolicy controller: %v", err)
+		logger.Error(err, "failed to setup RecycleItem controller")
+		os.Exit(1)
 	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
-		tlog.Fatalf("✗ failed to setup health check: %v", err)
+		logger.Error(err, "failed to setup health check")
+		os.Exit(1)
 	}
 	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
-		tlog.Fatalf("✗ failed to setup ready check: %v", err)
+		logger.Error(err, "failed to setup ready check")
+		os.Exit(1)
 	}
 
-	tlog.Info("» starting manager")
+	logger.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
-		tlog.Fatalf("✗ failed to start manager: %v", err)
+		logger.Error(err, "failed to start manager")
+		os.Exit(1)
 	}
 }
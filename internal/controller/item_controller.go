@@ -0,0 +1,165 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+	"github.com/wcrum/kube-recycle-bin/pkg/logging"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RecycleItemReconciler garbage-collects api.RecycleItem objects according to
+// the retention of the RecyclePolicy that produced them.
+type RecycleItemReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// now returns the current time, time.Now if nil.
+	now func() time.Time
+}
+
+func (r *RecycleItemReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := logging.FromContext(ctx).WithValues(logging.KeyItem, req.Name)
+	recycleItem := &api.RecycleItem{}
+	if err := r.Get(ctx, req.NamespacedName, recycleItem); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !recycleItem.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	if recycleItem.Status.Phase == "" {
+		recycleItem.MarkRecycled()
+		if err := r.Status().Update(ctx, recycleItem); err != nil {
+			logger.Error(err, "failed to initialize status of RecycleItem")
+			return ctrl.Result{}, err
+		}
+	}
+
+	evicted, err := r.enforceMaxItems(ctx, recycleItem)
+	if err != nil {
+		logger.Error(err, "failed to enforce max items of RecyclePolicy", logging.KeyPolicy, recycleItem.RecyclePolicyName())
+		return ctrl.Result{}, err
+	}
+	if evicted {
+		return ctrl.Result{}, nil
+	}
+
+	expireAt, ok, err := recycleItem.ExpireAt()
+	if err != nil {
+		// a malformed annotation will not fix itself, don't requeue.
+		logger.Error(err, "RecycleItem has no valid expiry")
+		return ctrl.Result{}, nil
+	}
+	if !ok {
+		return ctrl.Result{}, nil
+	}
+
+	now := time.Now
+	if r.now != nil {
+		now = r.now
+	}
+	if remaining := expireAt.Sub(now()); remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	logger.Info("RecycleItem expired, garbage-collecting", "expireAt", expireAt.Format(time.RFC3339))
+	if recycleItem.Phase() != api.RecycleItemExpired {
+		recycleItem.MarkExpired()
+		if err := r.Status().Update(ctx, recycleItem); err != nil {
+			logger.Error(err, "failed to mark RecycleItem expired")
+			return ctrl.Result{}, err
+		}
+	}
+	if err := r.Delete(ctx, recycleItem); err != nil && !k8serrors.IsNotFound(err) {
+		logger.Error(err, "failed to delete expired RecycleItem")
+		return ctrl.Result{}, err
+	}
+	logger.Info("expired RecycleItem deleted")
+	return ctrl.Result{}, nil
+}
+
+// enforceMaxItems deletes the oldest RecycleItems produced by the policy of the
+// given item when the policy keeps more items than its retention allows, and returns
+// whether the given item was one of them. Like expired items, evicted items are marked
+// before they are deleted.
+func (r *RecycleItemReconciler) enforceMaxItems(ctx context.Context, recycleItem *api.RecycleItem) (bool, error) {
+	policyName := recycleItem.RecyclePolicyName()
+	if policyName == "" {
+		return false, nil
+	}
+
+	recyclePolicy := &api.RecyclePolicy{}
+	if err := r.Get(ctx, types.NamespacedName{Name: policyName}, recyclePolicy); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	maxItems, ok := recyclePolicy.MaxItems()
+	if !ok {
+		return false, nil
+	}
+
+	recycleItems := &api.RecycleItemList{}
+	if err := r.List(ctx, recycleItems, client.MatchingLabels{api.RecyclePolicyLabel: policyName}); err != nil {
+		return false, err
+	}
+	if len(recycleItems.Items) <= maxItems {
+		return false, nil
+	}
+
+	sort.Slice(recycleItems.Items, func(i, j int) bool {
+		a, b := recycleItems.Items[i], recycleItems.Items[j]
+		if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.CreationTimestamp.Before(&b.CreationTimestamp)
+		}
+		return a.Name < b.Name
+	})
+
+	evicted := false
+	for i := range recycleItems.Items[:len(recycleItems.Items)-maxItems] {
+		item := &recycleItems.Items[i]
+		if item.Phase() != api.RecycleItemExpired {
+			item.MarkEvicted(maxItems)
+			if err := r.Status().Update(ctx, item); err != nil {
+				if k8serrors.IsNotFound(err) {
+					continue
+				}
+				return false, err
+			}
+		}
+		if err := r.Delete(ctx, item); err != nil && !k8serrors.IsNotFound(err) {
+			return false, err
+		}
+		logging.FromContext(ctx).Info("RecycleItem deleted, RecyclePolicy keeps at most maxItems items", logging.KeyItem, item.Name, logging.KeyPolicy, policyName, "maxItems", maxItems)
+		evicted = evicted || item.Name == recycleItem.Name
+	}
+	return evicted, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RecycleItemReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&api.RecycleItem{}).
+		Complete(r)
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+	"github.com/wcrum/kube-recycle-bin/pkg/util"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// newRecycleItem returns a RecycleItem of the policy created at the given time.
+func newRecycleItem(name string, policy *api.RecyclePolicy, createdAt time.Time) *api.RecycleItem {
+	recycleItem := api.NewRecycleItem(&api.RecycledObject{
+		Version:   "v1",
+		Resource:  "configmaps",
+		Kind:      "ConfigMap",
+		Namespace: "dev",
+		Name:      name,
+		Raw:       []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"` + name + `","namespace":"dev"}}`),
+	}, types.UID(name))
+	recycleItem.CreationTimestamp = metav1.NewTime(createdAt)
+	recycleItem.SetRecyclePolicy(policy, createdAt)
+	recycleItem.MarkRecycled()
+	return recycleItem
+}
+
+func TestReconcileMaxItems(t *testing.T) {
+	now := time.Now()
+	policy := &api.RecyclePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "keep-one"},
+		Retention: &api.RecycleRetention{
+			TTL:      &metav1.Duration{Duration: time.Hour},
+			MaxItems: util.Ptr(int32(1)),
+		},
+	}
+	// the oldest item is also expired, it must only be garbage-collected once.
+	oldest := newRecycleItem("oldest", policy, now.Add(-2*time.Hour))
+	newest := newRecycleItem("newest", policy, now)
+
+	var phases []api.RecycleItemPhase
+	cli := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(policy, oldest, newest).
+		WithStatusSubresource(&api.RecycleItem{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceUpdate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+				if obj.GetName() == oldest.Name {
+					phases = append(phases, obj.(*api.RecycleItem).Phase())
+				}
+				return c.SubResource(subResourceName).Update(ctx, obj, opts...)
+			},
+		}).
+		Build()
+	reconciler := &RecycleItemReconciler{Client: cli, Scheme: scheme}
+
+	result, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: oldest.Name}})
+	if err != nil {
+		t.Fatalf("✗ failed to reconcile: %v", err)
+	}
+	if result != (ctrl.Result{}) {
+		t.Errorf("✗ expected no requeue, got %+v", result)
+	}
+	if err := cli.Get(context.Background(), types.NamespacedName{Name: oldest.Name}, &api.RecycleItem{}); !k8serrors.IsNotFound(err) {
+		t.Errorf("✗ expected the oldest RecycleItem to be deleted, got %v", err)
+	}
+	if len(phases) != 1 || phases[0] != api.RecycleItemExpired {
+		t.Errorf("✗ expected the oldest RecycleItem to be marked %s once before its delete, got %v", api.RecycleItemExpired, phases)
+	}
+	if err := cli.Get(context.Background(), types.NamespacedName{Name: newest.Name}, &api.RecycleItem{}); err != nil {
+		t.Errorf("✗ expected the newest RecycleItem to be kept, got %v", err)
+	}
+}
+
+func TestReconcileTTL(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	policy := &api.RecyclePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "keep-an-hour"},
+		Retention:  &api.RecycleRetention{TTL: &metav1.Duration{Duration: time.Hour}},
+	}
+
+	tests := []struct {
+		name        string
+		recycledAt  time.Time
+		wantResult  ctrl.Result
+		wantDeleted bool
+	}{
+		{name: "before TTL", recycledAt: now.Add(-40 * time.Minute), wantResult: ctrl.Result{RequeueAfter: 20 * time.Minute}},
+		{name: "past TTL", recycledAt: now.Add(-2 * time.Hour), wantDeleted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recycleItem := newRecycleItem("item", policy, tt.recycledAt)
+			var phases []api.RecycleItemPhase
+			cli := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(policy, recycleItem).
+				WithStatusSubresource(&api.RecycleItem{}).
+				WithInterceptorFuncs(interceptor.Funcs{
+					SubResourceUpdate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+						phases = append(phases, obj.(*api.RecycleItem).Phase())
+						return c.SubResource(subResourceName).Update(ctx, obj, opts...)
+					},
+				}).
+				Build()
+			reconciler := &RecycleItemReconciler{Client: cli, Scheme: scheme, now: func() time.Time { return now }}
+
+			result, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: recycleItem.Name}})
+			if err != nil {
+				t.Fatalf("✗ failed to reconcile: %v", err)
+			}
+			if result != tt.wantResult {
+				t.Errorf("✗ expected result %+v, got %+v", tt.wantResult, result)
+			}
+			err = cli.Get(context.Background(), types.NamespacedName{Name: recycleItem.Name}, &api.RecycleItem{})
+			if deleted := k8serrors.IsNotFound(err); deleted != tt.wantDeleted {
+				t.Errorf("✗ expected deleted %v, got %v", tt.wantDeleted, err)
+			}
+			if tt.wantDeleted && (len(phases) != 1 || phases[0] != api.RecycleItemExpired) {
+				t.Errorf("✗ expected the RecycleItem to be marked %s once before its delete, got %v", api.RecycleItemExpired, phases)
+			}
+			if !tt.wantDeleted && len(phases) != 0 {
+				t.Errorf("✗ expected the status of the RecycleItem untouched, got %v", phases)
+			}
+		})
+	}
+}
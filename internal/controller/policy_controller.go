@@ -18,82 +18,207 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/wcrum/kube-recycle-bin/internal/api"
 	"github.com/wcrum/kube-recycle-bin/internal/consts"
 	"github.com/wcrum/kube-recycle-bin/internal/webhook"
-	"github.com/wcrum/kube-recycle-bin/pkg/tlog"
+	"github.com/wcrum/kube-recycle-bin/pkg/logging"
 	"github.com/wcrum/kube-recycle-bin/pkg/util"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 // RecyclePolicyReconciler reconciles a api.RecyclePolicy object
 type RecyclePolicyReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// CertManager tells whether cert-manager injects the CA bundle into the webhook configuration.
+	CertManager bool
+	// WebhookURL is the base URL the api server calls the webhook at, such as for a webhook
+	// running outside of the cluster. The krb-webhook Service is called if it is empty.
+	WebhookURL string
 }
 
 func (r *RecyclePolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	tlog.Infof("» reconciling RecyclePolicy [%s]...", req.Name)
+	logger := logging.FromContext(ctx).WithValues(logging.KeyPolicy, req.Name)
+	ctx = logging.IntoContext(ctx, logger)
+	logger.V(1).Info("reconciling RecyclePolicy")
 	recyclePolicy := &api.RecyclePolicy{}
 	if err := r.Get(ctx, req.NamespacedName, recyclePolicy); err != nil {
 		if k8serrors.IsNotFound(err) {
-			tlog.Infof("» watched RecyclePolicy [%s] deleted, reclaiming webhook...", req.Name)
-			if err := r.tryReclaimWebhook(ctx, req.Name); err != nil {
-				tlog.Errorf("✗ failed to reclaim webhook: %v", err)
-				return ctrl.Result{RequeueAfter: time.Second * 10}, err
+			logger.Info("RecyclePolicy deleted, removing its webhook")
+			if err := r.reconcileWebhookConfiguration(ctx); err != nil {
+				logger.Error(err, "failed to remove webhook of RecyclePolicy")
+				return ctrl.Result{}, err
 			}
-			tlog.Infof("✓ webhook reclaimed for RecyclePolicy [%s] done.", req.Name)
+			logger.Info("webhook of RecyclePolicy removed")
 			return ctrl.Result{}, nil
 		}
 
-		tlog.Errorf("✗ failed to get RecyclePolicy [%s]: %v", req.Name, err)
+		logger.Error(err, "failed to get RecyclePolicy")
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	if err := r.tryBuildWebhook(ctx, recyclePolicy); err != nil {
-		tlog.Errorf("✗ failed to build webhook for RecyclePolicy [%s]: %v", req.Name, err)
-		return ctrl.Result{}, err
+	original := recyclePolicy.DeepCopy()
+
+	if err := r.resolveTarget(recyclePolicy); err != nil {
+		logger.Error(err, "target of RecyclePolicy is not resolvable")
+		recyclePolicy.SetCondition(api.RecyclePolicyConditionTargetResolvable, metav1.ConditionFalse, "ResourceNotFound", err.Error())
+	} else {
+		recyclePolicy.SetCondition(api.RecyclePolicyConditionTargetResolvable, metav1.ConditionTrue, "ResourceFound", "target resource is served by the cluster")
+	}
+
+	buildErr := r.reconcileWebhookConfiguration(ctx)
+	if buildErr != nil {
+		logger.Error(buildErr, "failed to build webhook for RecyclePolicy")
+		recyclePolicy.SetCondition(api.RecyclePolicyConditionWebhookConfigured, metav1.ConditionFalse, "BuildFailed", buildErr.Error())
+	} else {
+		logger.V(1).Info("webhook built for RecyclePolicy")
+		recyclePolicy.SetCondition(api.RecyclePolicyConditionWebhookConfigured, metav1.ConditionTrue, "Configured", "webhook configured")
+	}
+
+	recyclePolicy.Status.ObservedGeneration = recyclePolicy.Generation
+
+	// the item count is patched apart by reconcileItemCount, the patch leaves it untouched.
+	if !equality.Semantic.DeepEqual(original.Status, recyclePolicy.Status) {
+		if err := r.Status().Patch(ctx, recyclePolicy, client.MergeFrom(original)); err != nil {
+			logger.Error(err, "failed to update status of RecyclePolicy")
+			return ctrl.Result{}, err
+		}
 	}
 
-	tlog.Infof("✓ webhook built for RecyclePolicy [%s] done.", req.Name)
+	return ctrl.Result{}, buildErr
+}
+
+// resolveTarget checks that every target resource of the policy is served by the cluster.
+func (r *RecyclePolicyReconciler) resolveTarget(recyclePolicy *api.RecyclePolicy) error {
+	grs := recyclePolicy.Target.GroupResources()
+	if len(grs) == 0 {
+		return fmt.Errorf("recycle policy targets no resource")
+	}
+	var errs []error
+	for _, gr := range grs {
+		if _, err := r.RESTMapper().ResourceFor(gr.WithVersion("")); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reconcileItemCount reports the number of RecycleItems produced by a RecyclePolicy in its
+// status. It runs apart from Reconcile, the recycles of a policy don't touch its webhook.
+func (r *RecyclePolicyReconciler) reconcileItemCount(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := logging.FromContext(ctx).WithValues(logging.KeyPolicy, req.Name)
+	recyclePolicy := &api.RecyclePolicy{}
+	if err := r.Get(ctx, req.NamespacedName, recyclePolicy); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	original := recyclePolicy.DeepCopy()
+	if err := r.countRecycleItems(ctx, recyclePolicy); err != nil {
+		logger.Error(err, "failed to count RecycleItems of RecyclePolicy")
+		return ctrl.Result{}, err
+	}
+	if equality.Semantic.DeepEqual(original.Status, recyclePolicy.Status) {
+		return ctrl.Result{}, nil
+	}
+	if err := r.Status().Patch(ctx, recyclePolicy, client.MergeFrom(original)); err != nil {
+		logger.Error(err, "failed to update item count of RecyclePolicy")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	logger.V(1).Info("item count of RecyclePolicy updated", "itemCount", recyclePolicy.Status.ItemCount)
 	return ctrl.Result{}, nil
 }
 
-func (r *RecyclePolicyReconciler) tryReclaimWebhook(ctx context.Context, recyclePolicyName string) error {
-	return r.Client.Delete(ctx, &admissionregistrationv1.ValidatingWebhookConfiguration{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: webhookName(recyclePolicyName),
-		},
-	})
+// countRecycleItems reports the number of RecycleItems produced by the policy and
+// the time of the latest recycle in the policy status.
+func (r *RecyclePolicyReconciler) countRecycleItems(ctx context.Context, recyclePolicy *api.RecyclePolicy) error {
+	recycleItems := &api.RecycleItemList{}
+	if err := r.List(ctx, recycleItems, client.MatchingLabels{api.RecyclePolicyLabel: recyclePolicy.Name}); err != nil {
+		return err
+	}
+
+	recyclePolicy.Status.ItemCount = int32(len(recycleItems.Items))
+	recyclePolicy.Status.LastRecycleTime = nil
+	for _, item := range recycleItems.Items {
+		if recyclePolicy.Status.LastRecycleTime == nil || recyclePolicy.Status.LastRecycleTime.Before(&item.CreationTimestamp) {
+			recyclePolicy.Status.LastRecycleTime = item.CreationTimestamp.DeepCopy()
+		}
+	}
+	return nil
 }
 
-func (r *RecyclePolicyReconciler) tryBuildWebhook(ctx context.Context, recyclePolicy *api.RecyclePolicy) error {
+// reconcileWebhookConfiguration renders all RecyclePolicies into the single ValidatingWebhookConfiguration
+// managed by krb-controller, one webhook per policy, and removes the per-policy configurations of
+// earlier versions.
+func (r *RecyclePolicyReconciler) reconcileWebhookConfiguration(ctx context.Context) error {
+	logger := logging.FromContext(ctx).WithValues("webhookConfiguration", consts.WebhookName)
 	recyclePolicies := &api.RecyclePolicyList{}
 	if err := r.List(ctx, recyclePolicies); err != nil {
-		tlog.Errorf("✗ failed to list recycle policies: %v", err)
+		logger.Error(err, "failed to list RecyclePolicies")
 		return err
 	}
+	recyclePolicies.Items = slices.DeleteFunc(recyclePolicies.Items, func(recyclePolicy api.RecyclePolicy) bool {
+		return !recyclePolicy.DeletionTimestamp.IsZero()
+	})
 
-	webhook := constructWebhookFromPolicy(recyclePolicy)
+	if err := r.deleteLegacyWebhookConfigurations(ctx); err != nil {
+		return err
+	}
+
+	if len(recyclePolicies.Items) == 0 {
+		err := r.Client.Delete(ctx, &admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: consts.WebhookName,
+			},
+		})
+		if err == nil {
+			logger.Info("no RecyclePolicy left, webhook configuration deleted")
+		}
+		return client.IgnoreNotFound(err)
+	}
+
+	var caBundle []byte
+	if !r.CertManager {
+		var err error
+		if caBundle, err = r.caBundle(ctx); err != nil {
+			return err
+		}
+	}
+
+	webhook := constructWebhookConfiguration(recyclePolicies.Items, r.webhookClientConfig(caBundle))
+	if r.CertManager {
+		webhook.Annotations = map[string]string{
+			consts.CertManagerInjectCAFromAnnotation: consts.WebhookNamespace + "/" + consts.WebhookCertificateName,
+		}
+	}
 	currentWebhook := &admissionregistrationv1.ValidatingWebhookConfiguration{}
 	if err := r.Get(ctx, types.NamespacedName{Name: webhook.Name}, currentWebhook); err != nil {
 		if k8serrors.IsNotFound(err) {
-			tlog.Infof("» creating webhook for RecyclePolicy [%s]...", recyclePolicy.Name)
 			if err := r.Client.Create(ctx, webhook); err != nil {
-				tlog.Errorf("✗ failed to create webhook for RecyclePolicy [%s]: %v", recyclePolicy.Name, err)
+				logger.Error(err, "failed to create webhook configuration")
 				return err
 			}
-			tlog.Infof("✓ webhook for RecyclePolicy [%s] created.", recyclePolicy.Name)
+			logger.Info("webhook configuration created", "webhooks", len(webhook.Webhooks))
 			return nil
 		}
 		return err
@@ -102,87 +227,227 @@ func (r *RecyclePolicyReconciler) tryBuildWebhook(ctx context.Context, recyclePo
 	// update the webhook
 	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		webhook.SetResourceVersion(currentWebhook.ResourceVersion)
+		if r.CertManager {
+			keepInjectedCABundles(webhook, currentWebhook)
+		}
+		// the configuration is rendered from every policy on each reconcile, only changes are written.
+		if equality.Semantic.DeepEqual(webhook.Webhooks, currentWebhook.Webhooks) && equality.Semantic.DeepEqual(webhook.Annotations, currentWebhook.Annotations) {
+			logger.V(1).Info("webhook configuration up to date")
+			return nil
+		}
 
 		if err := r.Client.Update(ctx, webhook); err != nil {
 			// update failed, try to get the latest version, and retry or return error
-			if err := r.Client.Get(ctx, types.NamespacedName{Name: webhook.Name}, currentWebhook); err != nil {
-				return err
+			if getErr := r.Client.Get(ctx, types.NamespacedName{Name: webhook.Name}, currentWebhook); getErr != nil {
+				return getErr
 			}
+			return err
 		}
-		tlog.Infof("✓ webhook for RecyclePolicy [%s] updated.", recyclePolicy.Name)
+		logger.Info("webhook configuration updated", "webhooks", len(webhook.Webhooks))
 		return nil
 	})
 }
 
-func constructWebhookFromPolicy(recyclePolicy *api.RecyclePolicy) *admissionregistrationv1.ValidatingWebhookConfiguration {
+// deleteLegacyWebhookConfigurations deletes the krb-webhook-<policy> configurations built
+// one per policy, they would call the webhook a second time for every delete.
+func (r *RecyclePolicyReconciler) deleteLegacyWebhookConfigurations(ctx context.Context) error {
+	legacyWebhooks := &admissionregistrationv1.ValidatingWebhookConfigurationList{}
+	if err := r.List(ctx, legacyWebhooks, client.HasLabels{api.RecyclePolicyLabel}); err != nil {
+		return err
+	}
+	for _, legacyWebhook := range legacyWebhooks.Items {
+		if err := r.Client.Delete(ctx, &legacyWebhook); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		logging.FromContext(ctx).Info("legacy webhook configuration deleted", "webhookConfiguration", legacyWebhook.Name)
+	}
+	return nil
+}
+
+const (
+	// allowOnFailureTimeoutSeconds is the webhook timeout of policies letting deletes through on recycle failure.
+	allowOnFailureTimeoutSeconds int32 = 5
+	// denyOnFailureTimeoutSeconds is the webhook timeout of policies refusing deletes on recycle failure.
+	denyOnFailureTimeoutSeconds int32 = 15
+	// webhookServicePort is the port of the krb-webhook Service, the default the api server sets.
+	webhookServicePort int32 = 443
+)
+
+// webhookClientConfig returns the client config of the webhook of a policy, the api server
+// verifies the webhook with caBundle.
+func (r *RecyclePolicyReconciler) webhookClientConfig(caBundle []byte) func(policyName string) admissionregistrationv1.WebhookClientConfig {
+	return func(policyName string) admissionregistrationv1.WebhookClientConfig {
+		path := consts.WebhookServicePath + "/" + policyName
+		if r.WebhookURL != "" {
+			return admissionregistrationv1.WebhookClientConfig{
+				CABundle: caBundle,
+				URL:      util.Ptr(strings.TrimSuffix(r.WebhookURL, "/") + path),
+			}
+		}
+		return admissionregistrationv1.WebhookClientConfig{
+			CABundle: caBundle,
+			Service: &admissionregistrationv1.ServiceReference{
+				Name:      consts.WebhookName,
+				Namespace: consts.WebhookNamespace,
+				Path:      util.Ptr(path),
+				Port:      util.Ptr(webhookServicePort),
+			},
+		}
+	}
+}
+
+// constructWebhookConfiguration builds the ValidatingWebhookConfiguration serving the given policies,
+// webhooks are sorted by policy name so unchanged policies render an unchanged configuration.
+func constructWebhookConfiguration(recyclePolicies []api.RecyclePolicy, clientConfig func(policyName string) admissionregistrationv1.WebhookClientConfig) *admissionregistrationv1.ValidatingWebhookConfiguration {
+	slices.SortFunc(recyclePolicies, func(a, b api.RecyclePolicy) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
 	result := &admissionregistrationv1.ValidatingWebhookConfiguration{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: webhookName(recyclePolicy.Name),
-			Labels: map[string]string{
-				"krb.wcrum.dev/recycle-policy": recyclePolicy.Name,
-			},
-		},
-		Webhooks: []admissionregistrationv1.ValidatingWebhook{
-			{
-				AdmissionReviewVersions: []string{"v1"},
-				ClientConfig: admissionregistrationv1.WebhookClientConfig{
-					CABundle: getCertBytes(),
-					Service: &admissionregistrationv1.ServiceReference{
-						Name:      consts.WebhookName,
-						Namespace: consts.WebhookNamespace,
-						Path:      util.Ptr(consts.WebhookServicePath),
-					},
-				},
-				FailurePolicy:  util.Ptr(admissionregistrationv1.Fail),
-				MatchPolicy:    util.Ptr(admissionregistrationv1.Exact),
-				Name:           consts.WebhookDNSName,
-				SideEffects:    util.Ptr(admissionregistrationv1.SideEffectClassNone),
-				TimeoutSeconds: util.Ptr(int32(5)),
-			},
+			Name: consts.WebhookName,
 		},
 	}
+	for i := range recyclePolicies {
+		result.Webhooks = append(result.Webhooks, constructWebhookFromPolicy(&recyclePolicies[i], clientConfig(recyclePolicies[i].Name)))
+	}
+	return result
+}
 
-	result.Webhooks[0].Rules = append(result.Webhooks[0].Rules, admissionregistrationv1.RuleWithOperations{
-		Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Delete},
-		Rule: admissionregistrationv1.Rule{
-			APIGroups:   []string{recyclePolicy.Target.Group},
-			APIVersions: []string{"*"},
-			Resources:   []string{recyclePolicy.Target.Resource},
-		},
-	})
+// constructWebhookFromPolicy builds the webhook of a policy. The fields the api server defaults
+// are set to their defaults, so an unchanged policy renders the webhook the api server stores.
+func constructWebhookFromPolicy(recyclePolicy *api.RecyclePolicy, clientConfig admissionregistrationv1.WebhookClientConfig) admissionregistrationv1.ValidatingWebhook {
+	result := admissionregistrationv1.ValidatingWebhook{
+		AdmissionReviewVersions: []string{"v1"},
+		ClientConfig:            clientConfig,
+		FailurePolicy:           util.Ptr(admissionregistrationv1.Ignore),
+		MatchPolicy:             util.Ptr(admissionregistrationv1.Exact),
+		Name:                    webhookName(recyclePolicy.Name),
+		SideEffects:             util.Ptr(admissionregistrationv1.SideEffectClassNoneOnDryRun),
+		TimeoutSeconds:          util.Ptr(allowOnFailureTimeoutSeconds),
+	}
 
-	var namespaceSelector metav1.LabelSelectorRequirement
-	if len(recyclePolicy.Target.Namespaces) == 0 || slices.Contains(recyclePolicy.Target.Namespaces, metav1.NamespaceAll) || slices.Contains(recyclePolicy.Target.Namespaces, "*") {
-		namespaceSelector = metav1.LabelSelectorRequirement{
-			Key:      "kubernetes.io/metadata.name",
-			Operator: metav1.LabelSelectorOpExists, // match all namespaces
-		}
-	} else {
-		namespaceSelector = metav1.LabelSelectorRequirement{
-			Key:      "kubernetes.io/metadata.name",
-			Operator: metav1.LabelSelectorOpIn,
-			Values:   recyclePolicy.Target.Namespaces,
+	for _, rule := range recyclePolicy.Target.ResourceRules() {
+		versions := rule.Versions
+		if len(versions) == 0 {
+			versions = []string{"*"}
 		}
+		result.Rules = append(result.Rules, admissionregistrationv1.RuleWithOperations{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Delete},
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{rule.Group},
+				APIVersions: versions,
+				Resources:   []string{rule.Resource},
+				Scope:       util.Ptr(admissionregistrationv1.AllScopes),
+			},
+		})
 	}
 
-	result.Webhooks[0].NamespaceSelector = &metav1.LabelSelector{
-		MatchExpressions: []metav1.LabelSelectorRequirement{namespaceSelector},
+	// a policy refusing deletes it cannot recycle also refuses them when the webhook is unreachable,
+	// and gives the webhook more time to recycle.
+	if recyclePolicy.DeniesOnRecycleFailure() {
+		result.FailurePolicy = util.Ptr(admissionregistrationv1.Fail)
+		result.TimeoutSeconds = util.Ptr(denyOnFailureTimeoutSeconds)
+	}
+
+	result.NamespaceSelector = recyclePolicy.Target.EffectiveNamespaceSelector()
+	// For DELETE requests the api server matches the object selector against the deleted object.
+	// The empty selector matching every object is the default the api server sets.
+	result.ObjectSelector = &metav1.LabelSelector{}
+	if recyclePolicy.Target.ObjectSelector != nil {
+		result.ObjectSelector = recyclePolicy.Target.ObjectSelector.DeepCopy()
 	}
 	return result
 }
 
+// webhookName returns the name of the webhook of a policy, unique in the configuration.
 func webhookName(policyName string) string {
-	return consts.WebhookName + "-" + policyName
+	return policyName + "." + consts.WebhookDNSName
+}
+
+// keepInjectedCABundles copies the CA bundles cert-manager injected into the current configuration
+// to the webhooks of the updated one, so updates don't break the webhooks until the next injection.
+func keepInjectedCABundles(webhook, currentWebhook *admissionregistrationv1.ValidatingWebhookConfiguration) {
+	var caBundle []byte
+	for _, current := range currentWebhook.Webhooks {
+		if len(current.ClientConfig.CABundle) > 0 {
+			caBundle = current.ClientConfig.CABundle
+			break
+		}
+	}
+	for i := range webhook.Webhooks {
+		webhook.Webhooks[i].ClientConfig.CABundle = caBundle
+	}
 }
 
-func getCertBytes() []byte {
-	cert, _ := webhook.FetchWebhookCertAndKey()
-	return cert
+// caBundle returns the CA bundle of the webhook TLS secret, maintained by krb-webhook.
+func (r *RecyclePolicyReconciler) caBundle(ctx context.Context) ([]byte, error) {
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: consts.WebhookNamespace, Name: consts.WebhookTLSCertSecretName}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get webhook TLS secret, is krb-webhook running: %w", err)
+	}
+	caBundle := webhook.CABundleFromSecret(secret)
+	if len(caBundle) == 0 {
+		return nil, fmt.Errorf("webhook TLS secret [%s/%s] holds no CA bundle", consts.WebhookNamespace, consts.WebhookTLSCertSecretName)
+	}
+	return caBundle, nil
 }
 
-// SetupWithManager sets up the controller with the Manager.
+// recyclePoliciesOfSecret maps the webhook TLS secret to all RecyclePolicies, so the
+// webhook configuration embeds the CA bundle again after the certificates are renewed.
+func (r *RecyclePolicyReconciler) recyclePoliciesOfSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	if obj.GetNamespace() != consts.WebhookNamespace || obj.GetName() != consts.WebhookTLSCertSecretName {
+		return nil
+	}
+	recyclePolicies := &api.RecyclePolicyList{}
+	if err := r.List(ctx, recyclePolicies); err != nil {
+		logging.FromContext(ctx).Error(err, "failed to list RecyclePolicies")
+		return nil
+	}
+	var result []reconcile.Request
+	for _, recyclePolicy := range recyclePolicies.Items {
+		result = append(result, reconcile.Request{NamespacedName: types.NamespacedName{Name: recyclePolicy.Name}})
+	}
+	return result
+}
+
+// itemCountDelay coalesces the RecycleItems created and deleted for a RecyclePolicy into one
+// count of its items, such as when a namespace of many objects is deleted.
+const itemCountDelay = 2 * time.Second
+
+// enqueueRecyclePolicyOfItem enqueues the RecyclePolicy that produced a created or deleted
+// RecycleItem after itemCountDelay, so the item count in the policy status follows recycles and
+// garbage collection. The requests of a policy waiting for the delay are merged.
+var enqueueRecyclePolicyOfItem = handler.Funcs{
+	CreateFunc: func(_ context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+		addRecyclePolicyOfItem(q, e.Object)
+	},
+	DeleteFunc: func(_ context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+		addRecyclePolicyOfItem(q, e.Object)
+	},
+}
+
+// addRecyclePolicyOfItem adds the RecyclePolicy that produced a RecycleItem to the queue after
+// itemCountDelay.
+func addRecyclePolicyOfItem(q workqueue.TypedRateLimitingInterface[reconcile.Request], obj client.Object) {
+	if policyName := obj.GetLabels()[api.RecyclePolicyLabel]; policyName != "" {
+		q.AddAfter(reconcile.Request{NamespacedName: types.NamespacedName{Name: policyName}}, itemCountDelay)
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager: the webhook configuration and the
+// conditions of policies follow policy changes and the webhook TLS secret, while their item
+// counts follow RecycleItems in a controller of their own.
 func (r *RecyclePolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := ctrl.NewControllerManagedBy(mgr).
+		For(&api.RecyclePolicy{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.recyclePoliciesOfSecret)).
+		Complete(r); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&api.RecyclePolicy{}).
-		Complete(r)
+		Named("recyclepolicy-items").
+		For(&api.RecyclePolicy{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&api.RecycleItem{}, enqueueRecyclePolicyOfItem).
+		Complete(reconcile.Func(r.reconcileItemCount))
 }
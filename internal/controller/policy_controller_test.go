@@ -0,0 +1,320 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+	"github.com/wcrum/kube-recycle-bin/internal/consts"
+	"github.com/wcrum/kube-recycle-bin/internal/webhook"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// newPolicyReconciler returns a RecyclePolicyReconciler of a fake cluster serving deployments
+// and the objects, and the number of updates of the webhook configuration it made.
+func newPolicyReconciler(objects ...client.Object) (*RecyclePolicyReconciler, client.Client, *int) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), meta.RESTScopeNamespace)
+
+	updates := 0
+	cli := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithRESTMapper(mapper).
+		WithObjects(objects...).
+		WithStatusSubresource(&api.RecyclePolicy{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+				if _, ok := obj.(*admissionregistrationv1.ValidatingWebhookConfiguration); ok {
+					updates++
+				}
+				return c.Update(ctx, obj, opts...)
+			},
+		}).
+		Build()
+	return &RecyclePolicyReconciler{Client: cli, Scheme: scheme}, cli, &updates
+}
+
+// newWebhookSecret returns the webhook TLS secret holding a CA bundle.
+func newWebhookSecret() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: consts.WebhookNamespace, Name: consts.WebhookTLSCertSecretName},
+		Data:       map[string][]byte{webhook.CACertKey: []byte("ca")},
+	}
+}
+
+// newPolicy returns a RecyclePolicy recycling the group/resource.
+func newPolicy(name, group, resource string) *api.RecyclePolicy {
+	return &api.RecyclePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Generation: 2},
+		Target: api.RecycleTarget{
+			Resources: []api.RecycleResourceRule{{Group: group, Resource: resource}},
+		},
+	}
+}
+
+// reconcilePolicy reconciles the RecyclePolicy of the name and returns it as stored.
+func reconcilePolicy(t *testing.T, reconciler *RecyclePolicyReconciler, cli client.Client, name string) (*api.RecyclePolicy, error) {
+	t.Helper()
+
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: name}})
+	recyclePolicy := &api.RecyclePolicy{}
+	if err := cli.Get(context.Background(), types.NamespacedName{Name: name}, recyclePolicy); err != nil {
+		t.Fatalf("✗ failed to get RecyclePolicy: %v", err)
+	}
+	return recyclePolicy, err
+}
+
+func TestReconcileRecyclePolicy(t *testing.T) {
+	reconciler, cli, updates := newPolicyReconciler(newPolicy("deployments", "apps", "deployments"), newWebhookSecret())
+
+	recyclePolicy, err := reconcilePolicy(t, reconciler, cli, "deployments")
+	if err != nil {
+		t.Fatalf("✗ failed to reconcile: %v", err)
+	}
+	for _, conditionType := range []string{api.RecyclePolicyConditionTargetResolvable, api.RecyclePolicyConditionWebhookConfigured} {
+		if got := recyclePolicy.ConditionStatus(conditionType); got != metav1.ConditionTrue {
+			t.Errorf("✗ expected condition %s %s, got %s", conditionType, metav1.ConditionTrue, got)
+		}
+	}
+	if recyclePolicy.Status.ObservedGeneration != 2 {
+		t.Errorf("✗ expected observed generation 2, got %d", recyclePolicy.Status.ObservedGeneration)
+	}
+	webhookConfiguration := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+	if err := cli.Get(context.Background(), types.NamespacedName{Name: consts.WebhookName}, webhookConfiguration); err != nil {
+		t.Fatalf("✗ failed to get webhook configuration: %v", err)
+	}
+	if len(webhookConfiguration.Webhooks) != 1 || string(webhookConfiguration.Webhooks[0].ClientConfig.CABundle) != "ca" {
+		t.Errorf("✗ expected a webhook trusting the CA bundle, got %+v", webhookConfiguration.Webhooks)
+	}
+
+	// an unchanged policy renders an unchanged configuration, which is not written again.
+	if _, err := reconcilePolicy(t, reconciler, cli, "deployments"); err != nil {
+		t.Fatalf("✗ failed to reconcile: %v", err)
+	}
+	if *updates != 0 {
+		t.Errorf("✗ expected no update of the unchanged webhook configuration, got %d", *updates)
+	}
+}
+
+func TestReconcileRecyclePolicyUnresolvableTarget(t *testing.T) {
+	reconciler, cli, _ := newPolicyReconciler(newPolicy("widgets", "example.com", "widgets"), newWebhookSecret())
+
+	recyclePolicy, err := reconcilePolicy(t, reconciler, cli, "widgets")
+	if err != nil {
+		t.Fatalf("✗ failed to reconcile: %v", err)
+	}
+	condition := meta.FindStatusCondition(recyclePolicy.Status.Conditions, api.RecyclePolicyConditionTargetResolvable)
+	if condition == nil || condition.Status != metav1.ConditionFalse || condition.Reason != "ResourceNotFound" {
+		t.Errorf("✗ expected the target not to be resolvable, got %+v", condition)
+	}
+	// the webhook is configured anyway, it recycles the resource once it is served.
+	if got := recyclePolicy.ConditionStatus(api.RecyclePolicyConditionWebhookConfigured); got != metav1.ConditionTrue {
+		t.Errorf("✗ expected condition %s %s, got %s", api.RecyclePolicyConditionWebhookConfigured, metav1.ConditionTrue, got)
+	}
+}
+
+func TestReconcileRecyclePolicyWithoutCABundle(t *testing.T) {
+	reconciler, cli, _ := newPolicyReconciler(newPolicy("deployments", "apps", "deployments"))
+
+	recyclePolicy, err := reconcilePolicy(t, reconciler, cli, "deployments")
+	if err == nil {
+		t.Error("✗ expected the reconcile to fail without the webhook TLS secret")
+	}
+	condition := meta.FindStatusCondition(recyclePolicy.Status.Conditions, api.RecyclePolicyConditionWebhookConfigured)
+	if condition == nil || condition.Status != metav1.ConditionFalse || condition.Reason != "BuildFailed" {
+		t.Errorf("✗ expected the webhook not to be configured, got %+v", condition)
+	}
+}
+
+func TestReconcileItemCount(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	recyclePolicy := newPolicy("configmaps", "", "configmaps")
+	other := newPolicy("other", "", "configmaps")
+	reconciler, cli, _ := newPolicyReconciler(recyclePolicy, other,
+		newRecycleItem("older", recyclePolicy, now.Add(-time.Hour)),
+		newRecycleItem("newer", recyclePolicy, now),
+		newRecycleItem("unrelated", other, now.Add(time.Hour)),
+	)
+
+	if _, err := reconciler.reconcileItemCount(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: recyclePolicy.Name}}); err != nil {
+		t.Fatalf("✗ failed to reconcile: %v", err)
+	}
+	stored := &api.RecyclePolicy{}
+	if err := cli.Get(context.Background(), types.NamespacedName{Name: recyclePolicy.Name}, stored); err != nil {
+		t.Fatalf("✗ failed to get RecyclePolicy: %v", err)
+	}
+	if stored.Status.ItemCount != 2 {
+		t.Errorf("✗ expected 2 items, got %d", stored.Status.ItemCount)
+	}
+	if stored.Status.LastRecycleTime == nil || !stored.Status.LastRecycleTime.Time.Equal(now) {
+		t.Errorf("✗ expected the last recycle at %s, got %v", now, stored.Status.LastRecycleTime)
+	}
+	// the item count leaves the conditions to Reconcile.
+	if len(stored.Status.Conditions) != 0 {
+		t.Errorf("✗ expected no conditions, got %+v", stored.Status.Conditions)
+	}
+}
+
+func TestConstructWebhookFromPolicyObjectSelector(t *testing.T) {
+	tests := []struct {
+		name           string
+		objectSelector *metav1.LabelSelector
+		want           *metav1.LabelSelector
+	}{
+		{name: "every object", want: &metav1.LabelSelector{}},
+		{
+			name:           "labelled objects",
+			objectSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}},
+			want:           &metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recyclePolicy := newPolicy("pods", "", "pods")
+			recyclePolicy.Target.ObjectSelector = tt.objectSelector
+
+			webhook := constructWebhookFromPolicy(recyclePolicy, admissionregistrationv1.WebhookClientConfig{})
+			if got := webhook.ObjectSelector.String(); got != tt.want.String() {
+				t.Errorf("✗ expected object selector %s, got %s", tt.want, got)
+			}
+			if tt.objectSelector != nil && webhook.ObjectSelector == tt.objectSelector {
+				t.Error("✗ expected the object selector of the policy to be copied")
+			}
+		})
+	}
+}
+
+func TestConstructWebhookFromPolicyRules(t *testing.T) {
+	recyclePolicy := newPolicy("app", "apps", "deployments")
+	recyclePolicy.Target.Resources = append(recyclePolicy.Target.Resources,
+		api.RecycleResourceRule{Resource: "services"},
+		api.RecycleResourceRule{Group: "networking.k8s.io", Resource: "ingresses", Versions: []string{"v1"}},
+	)
+
+	webhook := constructWebhookFromPolicy(recyclePolicy, admissionregistrationv1.WebhookClientConfig{})
+	want := []admissionregistrationv1.Rule{
+		{APIGroups: []string{"apps"}, APIVersions: []string{"*"}, Resources: []string{"deployments"}},
+		{APIGroups: []string{""}, APIVersions: []string{"*"}, Resources: []string{"services"}},
+		{APIGroups: []string{"networking.k8s.io"}, APIVersions: []string{"v1"}, Resources: []string{"ingresses"}},
+	}
+	if len(webhook.Rules) != len(want) {
+		t.Fatalf("✗ expected %d rules, got %+v", len(want), webhook.Rules)
+	}
+	for i, rule := range webhook.Rules {
+		if len(rule.Operations) != 1 || rule.Operations[0] != admissionregistrationv1.Delete {
+			t.Errorf("✗ expected rule %d to match deletes only, got %v", i, rule.Operations)
+		}
+		want[i].Scope = rule.Scope
+		if !equality.Semantic.DeepEqual(rule.Rule, want[i]) {
+			t.Errorf("✗ expected rule %d %+v, got %+v", i, want[i], rule.Rule)
+		}
+	}
+}
+
+// webhookSelects tells whether the api server calls the webhook for the delete of an object
+// of the resource, with the labels of its namespace and its own labels. namespaceLabels are nil
+// for cluster-scoped objects which are not namespaces.
+func webhookSelects(t *testing.T, webhook admissionregistrationv1.ValidatingWebhook, gvr schema.GroupVersionResource, namespaceLabels, objectLabels labels.Set) bool {
+	t.Helper()
+
+	matchesAny := func(values []string, value string) bool {
+		return slices.Contains(values, "*") || slices.Contains(values, value)
+	}
+	ruleMatched := slices.ContainsFunc(webhook.Rules, func(rule admissionregistrationv1.RuleWithOperations) bool {
+		return slices.Contains(rule.Operations, admissionregistrationv1.Delete) &&
+			matchesAny(rule.APIGroups, gvr.Group) && matchesAny(rule.APIVersions, gvr.Version) && matchesAny(rule.Resources, gvr.Resource)
+	})
+	if !ruleMatched {
+		return false
+	}
+	selects := func(selector *metav1.LabelSelector, set labels.Set) bool {
+		s, err := metav1.LabelSelectorAsSelector(selector)
+		if err != nil {
+			t.Fatalf("✗ invalid selector %s: %v", selector, err)
+		}
+		return s.Matches(set)
+	}
+	if namespaceLabels != nil && !selects(webhook.NamespaceSelector, namespaceLabels) {
+		return false
+	}
+	return selects(webhook.ObjectSelector, objectLabels)
+}
+
+func TestWebhookSelectsAsPolicyMatches(t *testing.T) {
+	app := newPolicy("app", "apps", "deployments")
+	app.Target.Resources = append(app.Target.Resources, api.RecycleResourceRule{Group: "networking.k8s.io", Resource: "ingresses", Versions: []string{"v1"}})
+	app.Target.Namespaces = []string{"payments", "krb-system"}
+	prod := newPolicy("prod", "", "configmaps")
+	prod.Target.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}
+	prod.Target.ExcludeNamespaces = []string{"kube-system"}
+	prod.Target.ObjectSelector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "team", Operator: metav1.LabelSelectorOpExists}}}
+	clusterScoped := newPolicy("namespaces", "", "namespaces")
+
+	namespace := func(name string, set labels.Set) labels.Set {
+		return labels.Merge(set, labels.Set{corev1.LabelMetadataName: name})
+	}
+	gvrs := []schema.GroupVersionResource{
+		appsv1.SchemeGroupVersion.WithResource("deployments"),
+		{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"},
+		{Group: "networking.k8s.io", Version: "v1beta1", Resource: "ingresses"},
+		corev1.SchemeGroupVersion.WithResource("configmaps"),
+		corev1.SchemeGroupVersion.WithResource("namespaces"),
+	}
+	namespaces := []labels.Set{
+		nil,
+		namespace("payments", labels.Set{"env": "prod"}),
+		namespace("dev", labels.Set{"env": "dev"}),
+		namespace("krb-system", labels.Set{"env": "prod"}),
+		namespace("kube-system", labels.Set{"env": "prod"}),
+	}
+	objects := []labels.Set{nil, {"team": "payments"}}
+
+	for _, recyclePolicy := range []*api.RecyclePolicy{app, prod, clusterScoped} {
+		webhook := constructWebhookFromPolicy(recyclePolicy, admissionregistrationv1.WebhookClientConfig{})
+		for _, gvr := range gvrs {
+			for _, namespaceLabels := range namespaces {
+				for _, objectLabels := range objects {
+					want := webhookSelects(t, webhook, gvr, namespaceLabels, objectLabels)
+					got, err := recyclePolicy.Matches(gvr, namespaceLabels, objectLabels)
+					if err != nil {
+						t.Fatalf("✗ failed to match: %v", err)
+					}
+					if got != want {
+						t.Errorf("✗ %s: expected Matches %v like its webhook for %s in namespace %v of labels %v, got %v", recyclePolicy.Name, want, gvr, namespaceLabels, objectLabels, got)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package event records the Kubernetes Events of recycles and restores.
+package event
+
+import (
+	"sync"
+	"time"
+
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Reasons of the Events recorded by krb.
+const (
+	ReasonRecycled        = "Recycled"
+	ReasonRecycleRejected = "RecycleRejected"
+	ReasonRestored        = "Restored"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	clientgoscheme.AddToScheme(scheme)
+	api.AddToScheme(scheme)
+}
+
+// Recorder records Events through a broadcaster that aggregates similar Events and
+// rate limits them per object, and can wait for them to be written before exiting.
+type Recorder struct {
+	recorder    record.EventRecorder
+	broadcaster record.EventBroadcaster
+	// pending counts the Events recorded but not written yet.
+	pending sync.WaitGroup
+}
+
+// NewRecorder returns a Recorder writing the Events of the given component with the client.
+func NewRecorder(client kubernetes.Interface, component string) *Recorder {
+	r := &Recorder{
+		// the defaults of the correlator: similar Events are aggregated, and each object gets
+		// a burst of 25 Events then one every 5 minutes.
+		broadcaster: record.NewBroadcasterWithCorrelatorOptions(record.CorrelatorOptions{}),
+	}
+	r.broadcaster.StartRecordingToSink(&pendingSink{
+		EventSink: &typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")},
+		pending:   &r.pending,
+	})
+	r.recorder = r.broadcaster.NewRecorder(scheme, corev1.EventSource{Component: component})
+	return r
+}
+
+// Recycled records a Recycled Event referencing the RecycleItem in the namespace of the recycled object.
+func (r *Recorder) Recycled(recycleItem *api.RecycleItem) {
+	r.pending.Add(1)
+	r.recorder.Eventf(&corev1.ObjectReference{
+		APIVersion:      api.GroupVersion.String(),
+		Kind:            api.RecycleItemKind,
+		Name:            recycleItem.Name,
+		UID:             recycleItem.UID,
+		ResourceVersion: recycleItem.ResourceVersion,
+		// RecycleItems are cluster-scoped, the Event goes to the namespace owners of the recycled object.
+		Namespace: recycleItem.Object.Namespace,
+	}, corev1.EventTypeNormal, ReasonRecycled, "%s %s deleted by %s was recycled into RecycleItem %s",
+		recycleItem.Object.GroupResource().String(), recycleItem.Object.Key(), deletedBy(recycleItem), recycleItem.Name)
+}
+
+// RecycleRejected records a Warning Event on the RecyclePolicy of the RecycleItem the api
+// server rejected, kept in the dead-letter spool. RecycleItems of no policy record nothing.
+func (r *Recorder) RecycleRejected(recycleItem *api.RecycleItem, err error) {
+	policyName := recycleItem.RecyclePolicyName()
+	if policyName == "" {
+		return
+	}
+	r.pending.Add(1)
+	r.recorder.Eventf(&corev1.ObjectReference{
+		APIVersion: api.GroupVersion.String(),
+		Kind:       api.RecyclePolicyKind,
+		Name:       policyName,
+	}, corev1.EventTypeWarning, ReasonRecycleRejected, "%s %s deleted by %s was not recycled, RecycleItem %s was rejected by the api server and dead-lettered: %v",
+		recycleItem.Object.GroupResource().String(), recycleItem.Object.Key(), deletedBy(recycleItem), recycleItem.Name, err)
+}
+
+// Restored records a Restored Event on the object restored from the RecycleItem.
+func (r *Recorder) Restored(recycleItem *api.RecycleItem, restored runtime.Object) {
+	r.pending.Add(1)
+	r.recorder.Eventf(restored, corev1.EventTypeNormal, ReasonRestored, "Restored from RecycleItem %s", recycleItem.Name)
+}
+
+// Flush waits at most timeout for the recorded Events to be written, and stops the Recorder.
+func (r *Recorder) Flush(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		r.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+	r.broadcaster.Shutdown()
+}
+
+func deletedBy(recycleItem *api.RecycleItem) string {
+	if username := recycleItem.DeletedByUsername(); username != "" {
+		return username
+	}
+	return "unknown"
+}
+
+// pendingSink marks Events written once the sink succeeds with them. The broadcaster stops
+// calling the sink for an Event after its first success, failed and spam-filtered Events are
+// left pending and only delay Flush until its timeout.
+type pendingSink struct {
+	record.EventSink
+	pending *sync.WaitGroup
+}
+
+func (s *pendingSink) Create(event *corev1.Event) (*corev1.Event, error) {
+	return s.done(s.EventSink.Create(event))
+}
+
+func (s *pendingSink) Update(event *corev1.Event) (*corev1.Event, error) {
+	return s.done(s.EventSink.Update(event))
+}
+
+func (s *pendingSink) Patch(oldEvent *corev1.Event, data []byte) (*corev1.Event, error) {
+	return s.done(s.EventSink.Patch(oldEvent, data))
+}
+
+func (s *pendingSink) done(event *corev1.Event, err error) (*corev1.Event, error) {
+	if err == nil {
+		s.pending.Done()
+	}
+	return event, err
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package event
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newRecycleItem() *api.RecycleItem {
+	return &api.RecycleItem{
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx-x7k2p9qz", UID: "a3c1e2d4-0000-4000-8000-000000000001"},
+		Object: api.RecycledObject{
+			Group:     "apps",
+			Version:   "v1",
+			Resource:  "deployments",
+			Kind:      "Deployment",
+			Namespace: "dev",
+			Name:      "nginx",
+		},
+	}
+}
+
+// listEvents returns the Events of the namespace.
+func listEvents(t *testing.T, client *fake.Clientset, namespace string) []corev1.Event {
+	t.Helper()
+	events, err := client.CoreV1().Events(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list events: %v", err)
+	}
+	return events.Items
+}
+
+func TestRecorderRecycled(t *testing.T) {
+	client := fake.NewClientset()
+	recorder := NewRecorder(client, "krb-webhook")
+
+	recorder.Recycled(newRecycleItem())
+	recorder.Flush(5 * time.Second)
+
+	events := listEvents(t, client, "dev")
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event in the namespace of the recycled object, got %d", len(events))
+	}
+	event := events[0]
+	if event.Reason != ReasonRecycled || event.Type != corev1.EventTypeNormal {
+		t.Errorf("unexpected event %s/%s", event.Type, event.Reason)
+	}
+	if event.InvolvedObject.Kind != api.RecycleItemKind || event.InvolvedObject.Name != "nginx-x7k2p9qz" {
+		t.Errorf("event does not reference the RecycleItem: %v", event.InvolvedObject)
+	}
+	if event.Source.Component != "krb-webhook" {
+		t.Errorf("unexpected source %q", event.Source.Component)
+	}
+}
+
+func TestRecorderRestored(t *testing.T) {
+	client := fake.NewClientset()
+	recorder := NewRecorder(client, "krb-cli")
+
+	restored := &unstructured.Unstructured{}
+	restored.SetAPIVersion("apps/v1")
+	restored.SetKind("Deployment")
+	restored.SetNamespace("dev")
+	restored.SetName("nginx")
+	recorder.Restored(newRecycleItem(), restored)
+	recorder.Flush(5 * time.Second)
+
+	events := listEvents(t, client, "dev")
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event on the restored object, got %d", len(events))
+	}
+	if event := events[0]; event.Reason != ReasonRestored || event.InvolvedObject.Kind != "Deployment" || event.InvolvedObject.Name != "nginx" {
+		t.Errorf("unexpected event %s on %v", event.Reason, event.InvolvedObject)
+	}
+}
+
+func TestRecorderRecycleRejected(t *testing.T) {
+	client := fake.NewClientset()
+	recorder := NewRecorder(client, "krb-webhook")
+
+	recycleItem := newRecycleItem()
+	recycleItem.Labels = map[string]string{api.RecyclePolicyLabel: "recycle-deployments"}
+	recorder.RecycleRejected(recycleItem, errors.New("RecycleItem is invalid"))
+	// RecycleItems of no policy have nothing to record on.
+	recorder.RecycleRejected(newRecycleItem(), errors.New("RecycleItem is invalid"))
+	recorder.Flush(5 * time.Second)
+
+	// Events of cluster-scoped objects go to the default namespace.
+	events := listEvents(t, client, metav1.NamespaceDefault)
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event on the RecyclePolicy, got %d", len(events))
+	}
+	event := events[0]
+	if event.Reason != ReasonRecycleRejected || event.Type != corev1.EventTypeWarning {
+		t.Errorf("unexpected event %s/%s", event.Type, event.Reason)
+	}
+	if event.InvolvedObject.Kind != api.RecyclePolicyKind || event.InvolvedObject.Name != "recycle-deployments" {
+		t.Errorf("event does not reference the RecyclePolicy: %v", event.InvolvedObject)
+	}
+	if !strings.Contains(event.Message, "RecycleItem is invalid") {
+		t.Errorf("expected the event to hold the rejection, got %q", event.Message)
+	}
+}
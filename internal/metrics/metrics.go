@@ -0,0 +1,103 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics holds the Prometheus metrics exposed by krb-webhook, krb-controller and krb-server.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "krb"
+
+// Metrics of krb-webhook, labelled with the group, resource and namespace of the deleted object.
+var (
+	RecycleAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "recycle_attempts_total",
+		Help:      "Number of deleted objects the webhook tried to recycle.",
+	}, []string{"group", "resource", "namespace"})
+	RecycleSuccesses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "recycle_successes_total",
+		Help:      "Number of deleted objects recycled into a RecycleItem.",
+	}, []string{"group", "resource", "namespace"})
+	RecycleFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "recycle_failures_total",
+		Help:      "Number of deleted objects that failed to be recycled.",
+	}, []string{"group", "resource", "namespace"})
+	RecycleOversized = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "recycle_oversized_skips_total",
+		Help:      "Number of deleted objects not recycled because they exceed the size limit.",
+	}, []string{"group", "resource", "namespace"})
+	RecycleRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "recycle_retries_total",
+		Help:      "Number of failed attempts to create a spooled RecycleItem that are retried.",
+	}, []string{"group", "resource", "namespace"})
+	RecycleDeadLetters = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "recycle_dead_letters_total",
+		Help:      "Number of spooled RecycleItems rejected by the api server and moved to the dead-letter spool.",
+	}, []string{"group", "resource", "namespace"})
+	SpoolDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "spool_depth",
+		Help:      "Number of spooled RecycleItems waiting to be created.",
+	})
+	AdmissionDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "admission_duration_seconds",
+		Help:      "Time taken by the webhook to answer admission reviews.",
+		Buckets:   prometheus.DefBuckets,
+	})
+)
+
+// Metrics of krb-server.
+var (
+	Restores = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "server",
+		Name:      "restores_total",
+		Help:      "Number of restores of recycled objects, by result (success or failure).",
+	}, []string{"group", "resource", "namespace", "result"})
+)
+
+// Restore results.
+const (
+	RestoreSuccess = "success"
+	RestoreFailure = "failure"
+)
+
+// RegisterWebhook registers the metrics of krb-webhook.
+func RegisterWebhook(registerer prometheus.Registerer) {
+	registerer.MustRegister(RecycleAttempts, RecycleSuccesses, RecycleFailures, RecycleOversized, RecycleRetries, RecycleDeadLetters, SpoolDepth, AdmissionDuration)
+}
+
+// RegisterServer registers the metrics of krb-server.
+func RegisterServer(registerer prometheus.Registerer) {
+	registerer.MustRegister(Restores)
+}
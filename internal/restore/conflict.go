@@ -0,0 +1,94 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restore
+
+import (
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// FieldManager is the field manager of the objects krb overwrites with server-side apply.
+const FieldManager = "krb"
+
+// ConflictStrategy tells how to restore an object when an object of the same name exists.
+type ConflictStrategy string
+
+const (
+	// ConflictFail fails the restore, the existing object is left untouched.
+	ConflictFail ConflictStrategy = "fail"
+	// ConflictSkip skips the restore, the existing object is left untouched.
+	ConflictSkip ConflictStrategy = "skip"
+	// ConflictOverwrite overwrites the existing object with the recycled one with server-side
+	// apply, taking the ownership of the conflicting fields.
+	ConflictOverwrite ConflictStrategy = "overwrite"
+	// ConflictRename restores the object under a new name derived from its name.
+	ConflictRename ConflictStrategy = "rename"
+)
+
+// ConflictStrategies are all conflict strategies.
+var ConflictStrategies = []ConflictStrategy{ConflictFail, ConflictSkip, ConflictOverwrite, ConflictRename}
+
+// Validate returns an error if the strategy is unknown, the empty strategy is ConflictFail.
+func (s ConflictStrategy) Validate() error {
+	if s != "" && !slices.Contains(ConflictStrategies, s) {
+		return fmt.Errorf("%w: unknown conflict strategy %q, expected one of %v", ErrInvalidOptions, s, ConflictStrategies)
+	}
+	return nil
+}
+
+// Outcome tells how a recycled object was restored.
+type Outcome string
+
+const (
+	// OutcomeCreated tells the object was created.
+	OutcomeCreated Outcome = "Created"
+	// OutcomeSkipped tells the object existed and was left untouched.
+	OutcomeSkipped Outcome = "Skipped"
+	// OutcomeOverwritten tells the object existed and was overwritten.
+	OutcomeOverwritten Outcome = "Overwritten"
+	// OutcomeRenamed tells the object existed and was created under a new name.
+	OutcomeRenamed Outcome = "Renamed"
+)
+
+// Result is the result of a restore.
+type Result struct {
+	Outcome Outcome
+	// Object is the restored object, nil if the restore was skipped.
+	Object *unstructured.Unstructured
+	// Manifest is the sanitized object sent to the api server.
+	Manifest *unstructured.Unstructured
+	// DroppedFields are the paths of the fields of an object restored in another version than
+	// the one it was recycled at, which the api server dropped as the version does not have them.
+	DroppedFields []string
+}
+
+// renameAttempts bounds the names tried to rename a conflicting object.
+const renameAttempts = 5
+
+// renamed returns a new name for an object conflicting under the name, with a random suffix
+// that keeps it a valid DNS label.
+func renamed(name string) string {
+	const suffixLength = len("-restored-") + 5
+	if len(name) > validation.DNS1123LabelMaxLength-suffixLength {
+		name = name[:validation.DNS1123LabelMaxLength-suffixLength]
+	}
+	return name + "-restored-" + utilrand.String(5)
+}
@@ -0,0 +1,299 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restore
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	appsv1beta1 "k8s.io/api/apps/v1beta1"
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	coordinationv1beta1 "k8s.io/api/coordination/v1beta1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	rbacv1beta1 "k8s.io/api/rbac/v1beta1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	schedulingv1beta1 "k8s.io/api/scheduling/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+// ConversionError is returned when the recycled object of a built-in kind is stored in a version
+// the cluster no longer serves, and cannot be converted to any of the versions it serves. It is
+// an ErrVersionUnavailable.
+type ConversionError struct {
+	// Stored is the version and kind the recycled object is stored in.
+	Stored schema.GroupVersionKind
+	// Served are the group versions the cluster serves the kind in.
+	Served []string
+}
+
+func (e *ConversionError) Error() string {
+	return fmt.Sprintf("%s: cannot convert %s %s to the versions the cluster serves (%s)", ErrVersionUnavailable, e.Stored.GroupVersion(), e.Stored.Kind, strings.Join(e.Served, ", "))
+}
+
+func (e *ConversionError) Unwrap() error {
+	return ErrVersionUnavailable
+}
+
+// conversions converts the objects of the built-in kinds stored in a version the cluster no
+// longer serves to the version that replaced it. The api server converts the versions of a kind
+// through its internal version, which client-go does not have: the conversions registered here
+// convert between the external versions, and the stored objects are first defaulted as the api
+// server defaulted them on their way to the internal version.
+var conversions = runtime.NewScheme()
+
+// fieldConversions are the versions of the built-in kinds replaced by versions holding the same
+// fields, but for the ones convert reports dropped.
+var fieldConversions = []struct{ from, to runtime.Object }{
+	{&extensionsv1beta1.DaemonSet{}, &appsv1.DaemonSet{}},
+	{&extensionsv1beta1.Deployment{}, &appsv1.Deployment{}},
+	{&extensionsv1beta1.ReplicaSet{}, &appsv1.ReplicaSet{}},
+	{&extensionsv1beta1.NetworkPolicy{}, &networkingv1.NetworkPolicy{}},
+	{&appsv1beta1.ControllerRevision{}, &appsv1.ControllerRevision{}},
+	{&appsv1beta1.Deployment{}, &appsv1.Deployment{}},
+	{&appsv1beta1.StatefulSet{}, &appsv1.StatefulSet{}},
+	{&appsv1beta2.ControllerRevision{}, &appsv1.ControllerRevision{}},
+	{&appsv1beta2.DaemonSet{}, &appsv1.DaemonSet{}},
+	{&appsv1beta2.Deployment{}, &appsv1.Deployment{}},
+	{&appsv1beta2.ReplicaSet{}, &appsv1.ReplicaSet{}},
+	{&appsv1beta2.StatefulSet{}, &appsv1.StatefulSet{}},
+	{&autoscalingv2beta2.HorizontalPodAutoscaler{}, &autoscalingv2.HorizontalPodAutoscaler{}},
+	{&batchv1beta1.CronJob{}, &batchv1.CronJob{}},
+	{&coordinationv1beta1.Lease{}, &coordinationv1.Lease{}},
+	{&networkingv1beta1.IngressClass{}, &networkingv1.IngressClass{}},
+	{&rbacv1beta1.ClusterRole{}, &rbacv1.ClusterRole{}},
+	{&rbacv1beta1.ClusterRoleBinding{}, &rbacv1.ClusterRoleBinding{}},
+	{&rbacv1beta1.Role{}, &rbacv1.Role{}},
+	{&rbacv1beta1.RoleBinding{}, &rbacv1.RoleBinding{}},
+	{&schedulingv1beta1.PriorityClass{}, &schedulingv1.PriorityClass{}},
+}
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(conversions))
+	for _, c := range fieldConversions {
+		utilruntime.Must(conversions.AddConversionFunc(c.from, c.to, convertFields))
+		utilruntime.Must(conversions.AddConversionFunc(c.to, c.from, convertFields))
+	}
+	for _, from := range []runtime.Object{&extensionsv1beta1.Ingress{}, &networkingv1beta1.Ingress{}} {
+		utilruntime.Must(conversions.AddConversionFunc(from, &networkingv1.Ingress{}, convertIngressToV1))
+		utilruntime.Must(conversions.AddConversionFunc(&networkingv1.Ingress{}, from, convertIngressFromV1))
+	}
+
+	// the selectors of the workloads of these versions default to the labels of their template.
+	conversions.AddTypeDefaultingFunc(&extensionsv1beta1.DaemonSet{}, func(obj any) {
+		daemonSet := obj.(*extensionsv1beta1.DaemonSet)
+		defaultSelector(&daemonSet.Spec.Selector, daemonSet.Spec.Template.Labels)
+	})
+	conversions.AddTypeDefaultingFunc(&extensionsv1beta1.Deployment{}, func(obj any) {
+		deployment := obj.(*extensionsv1beta1.Deployment)
+		defaultSelector(&deployment.Spec.Selector, deployment.Spec.Template.Labels)
+	})
+	conversions.AddTypeDefaultingFunc(&extensionsv1beta1.ReplicaSet{}, func(obj any) {
+		replicaSet := obj.(*extensionsv1beta1.ReplicaSet)
+		defaultSelector(&replicaSet.Spec.Selector, replicaSet.Spec.Template.Labels)
+	})
+	conversions.AddTypeDefaultingFunc(&appsv1beta1.Deployment{}, func(obj any) {
+		deployment := obj.(*appsv1beta1.Deployment)
+		defaultSelector(&deployment.Spec.Selector, deployment.Spec.Template.Labels)
+	})
+	conversions.AddTypeDefaultingFunc(&appsv1beta1.StatefulSet{}, func(obj any) {
+		statefulSet := obj.(*appsv1beta1.StatefulSet)
+		defaultSelector(&statefulSet.Spec.Selector, statefulSet.Spec.Template.Labels)
+	})
+	// the paths of the ingresses of these versions default to the ImplementationSpecific type.
+	conversions.AddTypeDefaultingFunc(&extensionsv1beta1.Ingress{}, func(obj any) {
+		for _, rule := range obj.(*extensionsv1beta1.Ingress).Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for i := range rule.HTTP.Paths {
+				if rule.HTTP.Paths[i].PathType == nil {
+					pathType := extensionsv1beta1.PathTypeImplementationSpecific
+					rule.HTTP.Paths[i].PathType = &pathType
+				}
+			}
+		}
+	})
+	conversions.AddTypeDefaultingFunc(&networkingv1beta1.Ingress{}, func(obj any) {
+		for _, rule := range obj.(*networkingv1beta1.Ingress).Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for i := range rule.HTTP.Paths {
+				if rule.HTTP.Paths[i].PathType == nil {
+					pathType := networkingv1beta1.PathTypeImplementationSpecific
+					rule.HTTP.Paths[i].PathType = &pathType
+				}
+			}
+		}
+	})
+}
+
+// defaultSelector sets the selector to match the labels if it is not set.
+func defaultSelector(selector **metav1.LabelSelector, labels map[string]string) {
+	if *selector == nil && len(labels) > 0 {
+		*selector = &metav1.LabelSelector{MatchLabels: maps.Clone(labels)}
+	}
+}
+
+// convert converts the object to the version and kind, after defaulting it as the api server
+// defaults its version, and returns the fields of the object the version does not have, found
+// by converting it back.
+func convert(obj *unstructured.Unstructured, gvk schema.GroupVersionKind) (*unstructured.Unstructured, []string, error) {
+	stored, err := conversions.New(obj.GroupVersionKind())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, stored); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode recycled object: %w", err)
+	}
+	conversions.Default(stored)
+
+	target, err := conversions.New(gvk)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := conversions.Convert(stored, target, nil); err != nil {
+		return nil, nil, err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	converted := &unstructured.Unstructured{Object: content}
+	converted.SetGroupVersionKind(gvk)
+
+	roundTrip, err := conversions.New(obj.GroupVersionKind())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := conversions.Convert(target, roundTrip, nil); err != nil {
+		return nil, nil, err
+	}
+	roundTripContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(roundTrip)
+	if err != nil {
+		return nil, nil, err
+	}
+	roundTripObj := &unstructured.Unstructured{Object: roundTripContent}
+	roundTripObj.SetGroupVersionKind(obj.GroupVersionKind())
+	return converted, droppedFields(obj.Object, roundTripObj.Object, ""), nil
+}
+
+// convertFields converts between versions holding the same fields, the fields missing from out
+// are dropped.
+func convertFields(in, out any, _ conversion.Scope) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(in)
+	if err != nil {
+		return err
+	}
+	// the type of out is set by convert.
+	delete(content, "apiVersion")
+	delete(content, "kind")
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(content, out)
+}
+
+// convertIngressToV1 converts a v1beta1 ingress to networking.k8s.io/v1, where the backend of
+// the spec became its defaultBackend and the service of the backends an object.
+func convertIngressToV1(in, out any, _ conversion.Scope) error {
+	return convertIngress(in, out, "backend", "defaultBackend", func(backend map[string]any) {
+		serviceName, _, _ := unstructured.NestedString(backend, "serviceName")
+		servicePort := backend["servicePort"]
+		delete(backend, "serviceName")
+		delete(backend, "servicePort")
+		if serviceName == "" {
+			return
+		}
+		service := map[string]any{"name": serviceName}
+		switch port := servicePort.(type) {
+		case string:
+			service["port"] = map[string]any{"name": port}
+		case int64:
+			service["port"] = map[string]any{"number": port}
+		}
+		backend["service"] = service
+	})
+}
+
+// convertIngressFromV1 converts a networking.k8s.io/v1 ingress to v1beta1, see
+// convertIngressToV1.
+func convertIngressFromV1(in, out any, _ conversion.Scope) error {
+	return convertIngress(in, out, "defaultBackend", "backend", func(backend map[string]any) {
+		service, ok, _ := unstructured.NestedMap(backend, "service")
+		delete(backend, "service")
+		if !ok {
+			return
+		}
+		if name, ok := service["name"].(string); ok {
+			backend["serviceName"] = name
+		}
+		if name, ok, _ := unstructured.NestedString(service, "port", "name"); ok && name != "" {
+			backend["servicePort"] = name
+		} else if number, ok, _ := unstructured.NestedInt64(service, "port", "number"); ok {
+			backend["servicePort"] = number
+		}
+	})
+}
+
+// convertIngress converts between the versions of ingresses, moving the backend of the spec
+// from the fromBackend field to the toBackend one and converting every backend.
+func convertIngress(in, out any, fromBackend, toBackend string, convertBackend func(backend map[string]any)) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(in)
+	if err != nil {
+		return err
+	}
+	delete(content, "apiVersion")
+	delete(content, "kind")
+
+	if spec, ok := content["spec"].(map[string]any); ok {
+		if backend, ok := spec[fromBackend].(map[string]any); ok {
+			convertBackend(backend)
+			spec[toBackend] = backend
+			delete(spec, fromBackend)
+		}
+		rules, _ := spec["rules"].([]any)
+		for _, rule := range rules {
+			paths, _ := asMap(asMap(rule)["http"])["paths"].([]any)
+			for _, path := range paths {
+				if backend, ok := asMap(path)["backend"].(map[string]any); ok {
+					convertBackend(backend)
+				}
+			}
+		}
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(content, out)
+}
+
+// asMap returns the value as a map, nil if it is not one.
+func asMap(value any) map[string]any {
+	m, _ := value.(map[string]any)
+	return m
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+	"github.com/wcrum/kube-recycle-bin/pkg/util"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/yaml"
+)
+
+// Diff is the difference between a recycled object and the live object it would be restored
+// over.
+type Diff struct {
+	// Recycled is the sanitized recycled object, as it would be restored.
+	Recycled *unstructured.Unstructured
+	// Live is the sanitized live object of the same namespace and name, nil if there is none.
+	Live *unstructured.Unstructured
+	// Unified is the unified diff from the live object to the recycled one in YAML, empty if
+	// they are equal.
+	Unified string
+}
+
+// Diff compares the recycled object of the RecycleItem, restored as the opts tell, with the live
+// object it would be restored over. Both are sanitized, so only what a restore would change is
+// compared.
+func (r *Restorer) Diff(ctx context.Context, recycleItem *api.RecycleItem, opts Options) (*Diff, error) {
+	namespace, name, err := opts.target(&recycleItem.Object)
+	if err != nil {
+		return nil, err
+	}
+	result := &Diff{}
+	var gvr schema.GroupVersionResource
+	result.Recycled, gvr, _, err = r.build(ctx, recycleItem, namespace, name, opts.RewriteReferences)
+	if err != nil {
+		return nil, err
+	}
+	recycledYAML, err := yaml.Marshal(result.Recycled.Object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal recycled object: %w", err)
+	}
+
+	liveName, liveYAML := "/dev/null", []byte{}
+	live, err := r.clients.Dynamic.Resource(gvr).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to get live object: %w", err)
+	default:
+		if err := r.sanitizer.Sanitize(live); err != nil {
+			return nil, err
+		}
+		result.Live = live
+		liveName = "live/" + gvr.Resource + "/" + cache.NewObjectName(namespace, name).String()
+		if liveYAML, err = yaml.Marshal(live.Object); err != nil {
+			return nil, fmt.Errorf("failed to marshal live object: %w", err)
+		}
+	}
+
+	result.Unified = util.UnifiedDiff(liveName, "recycled/"+recycleItem.Name, string(liveYAML), string(recycledYAML))
+	return result, nil
+}
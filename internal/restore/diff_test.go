@@ -0,0 +1,61 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restore
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	recycleItem := newConfigMapItem()
+	restorer, _ := newRestorer(recycleItem, newExistingConfigMap())
+
+	diff, err := restorer.Diff(context.Background(), recycleItem, Options{})
+	if err != nil {
+		t.Fatalf("✗ failed to diff: %v", err)
+	}
+	if diff.Live == nil {
+		t.Fatal("✗ expected the live object")
+	}
+	for _, want := range []string{"--- live/configmaps/dev/settings\n", "+++ recycled/" + recycleItem.Name + "\n", "-  level: info\n", "+  level: debug\n"} {
+		if !strings.Contains(diff.Unified, want) {
+			t.Errorf("✗ expected the diff to contain %q, got:\n%s", want, diff.Unified)
+		}
+	}
+	// both objects are sanitized, server-set fields of the live object are not reported.
+	if strings.Contains(diff.Unified, "uid:") {
+		t.Errorf("✗ expected the uid to be sanitized, got:\n%s", diff.Unified)
+	}
+}
+
+func TestDiffWithoutLiveObject(t *testing.T) {
+	recycleItem := newConfigMapItem()
+	restorer, _ := newRestorer(recycleItem)
+
+	diff, err := restorer.Diff(context.Background(), recycleItem, Options{Namespace: "scratch"})
+	if err != nil {
+		t.Fatalf("✗ failed to diff: %v", err)
+	}
+	if diff.Live != nil {
+		t.Errorf("✗ expected no live object, got %v", diff.Live)
+	}
+	if !strings.HasPrefix(diff.Unified, "--- /dev/null\n") || !strings.Contains(diff.Unified, "+  namespace: scratch\n") {
+		t.Errorf("✗ expected the recycled object to be added in namespace scratch, got:\n%s", diff.Unified)
+	}
+}
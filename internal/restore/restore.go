@@ -0,0 +1,278 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package restore restores recycled objects from their RecycleItems.
+package restore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/go-logr/logr"
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+	krbclient "github.com/wcrum/kube-recycle-bin/internal/client"
+	"github.com/wcrum/kube-recycle-bin/internal/event"
+	"github.com/wcrum/kube-recycle-bin/pkg/logging"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	// ErrAlreadyRestored is returned when restoring a RecycleItem that was already restored.
+	ErrAlreadyRestored = errors.New("RecycleItem was already restored")
+	// ErrInvalidOptions is returned when the Options cannot apply to the recycled object.
+	ErrInvalidOptions = errors.New("invalid restore options")
+	// ErrRestoreFailed is returned when the recycled object could not be created again.
+	ErrRestoreFailed = errors.New("failed to restore recycled object")
+	// ErrVersionUnavailable is returned when the cluster no longer serves the kind of the
+	// recycled object, or rejects it in the version it serves instead.
+	ErrVersionUnavailable = errors.New("version of recycled object is not available")
+)
+
+// Options tell where to restore a recycled object. The zero Options restore it in place.
+type Options struct {
+	// Namespace restores a namespaced object into this namespace instead of the one it was
+	// deleted from.
+	Namespace string
+	// Name restores the object under this name instead of the one it was deleted with.
+	Name string
+	// RewriteReferences rewrites the labels, selectors and ConfigMap/Secret references of the
+	// object holding its former name to its new name, see Name and ConflictRename.
+	RewriteReferences bool
+	// OnConflict tells how to restore the object when an object of the same name exists,
+	// ConflictFail if empty.
+	OnConflict ConflictStrategy
+	// DryRun runs the restore on the api server without persisting anything, the status of the
+	// RecycleItem is left untouched.
+	DryRun bool
+}
+
+// target returns the namespace and name the recycled object is restored as.
+func (o Options) target(obj *api.RecycledObject) (namespace, name string, err error) {
+	if err := o.OnConflict.Validate(); err != nil {
+		return "", "", err
+	}
+	namespace, name = obj.Namespace, obj.Name
+	if o.Namespace != "" {
+		if obj.Namespace == "" {
+			return "", "", fmt.Errorf("%w: %s %s is cluster-scoped, it cannot be restored into namespace %s", ErrInvalidOptions, obj.Kind, obj.Name, o.Namespace)
+		}
+		namespace = o.Namespace
+	}
+	if o.Name != "" {
+		name = o.Name
+	}
+	return namespace, name, nil
+}
+
+// Restorer restores the objects of RecycleItems and records their Restored Events.
+type Restorer struct {
+	clients   *krbclient.Clients
+	recorder  *event.Recorder
+	sanitizer Sanitizer
+}
+
+// NewRestorer returns a Restorer restoring objects with the clients, sanitized by the
+// sanitizer before they are created, see DefaultSanitizer.
+func NewRestorer(clients *krbclient.Clients, recorder *event.Recorder, sanitizer Sanitizer) *Restorer {
+	return &Restorer{
+		clients:   clients,
+		recorder:  recorder,
+		sanitizer: sanitizer,
+	}
+}
+
+// Restore creates the object of the RecycleItem again as the opts tell and returns the result.
+// The status of a RecycleItem restored in place is updated before and after the restore, the
+// object is left untouched if the RecycleItem cannot be marked restoring. Copies under another
+// namespace or name and dry runs leave the status untouched, the recycled object can still be
+// restored in place after them. Failures are returned to the caller to report.
+func (r *Restorer) Restore(ctx context.Context, recycleItem *api.RecycleItem, opts Options) (*Result, error) {
+	namespace, name, err := opts.target(&recycleItem.Object)
+	if err != nil {
+		return nil, err
+	}
+	inPlace := namespace == recycleItem.Object.Namespace && name == recycleItem.Object.Name
+	if inPlace && recycleItem.Phase() == api.RecycleItemRestored {
+		return nil, ErrAlreadyRestored
+	}
+	logger := logging.FromContext(ctx).WithValues(logging.KeyItem, recycleItem.Name, logging.KeyGVR, recycleItem.Object.GroupVersionResource().String(), logging.KeyObject, recycleItem.Object.Key())
+	ctx = logging.IntoContext(ctx, logger)
+
+	if opts.DryRun || !inPlace {
+		result, err := r.restore(ctx, recycleItem, namespace, name, opts)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrRestoreFailed, err)
+		}
+		if opts.DryRun {
+			logger.V(1).Info("dry run of restore of recycled object", "outcome", result.Outcome, "restoredAs", cache.MetaObjectToName(result.Manifest).String())
+		} else {
+			r.restored(logger, recycleItem, result)
+		}
+		return result, nil
+	}
+
+	var previous api.RecycleItemStatus
+	recycleItem.Status.DeepCopyInto(&previous)
+	recycleItem.MarkRestoring()
+	if err := r.clients.RecycleItem().UpdateStatus(ctx, recycleItem, client.SubResourceUpdateOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to update status of RecycleItem: %w", err)
+	}
+
+	result, restoreErr := r.restore(ctx, recycleItem, namespace, name, opts)
+	switch {
+	case restoreErr != nil:
+		recycleItem.MarkRestoreFailed(restoreErr)
+	case result.Outcome == OutcomeSkipped:
+		// the existing object was left untouched, the recycled one is still to be restored.
+		recycleItem.Status = previous
+	default:
+		recycleItem.MarkRestored()
+	}
+	if restoreErr == nil {
+		r.restored(logger, recycleItem, result)
+	}
+	if err := r.clients.RecycleItem().UpdateStatus(ctx, recycleItem, client.SubResourceUpdateOptions{}); err != nil {
+		logger.Error(err, "failed to update status of RecycleItem after restore")
+	}
+
+	if restoreErr != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRestoreFailed, restoreErr)
+	}
+	return result, nil
+}
+
+// restored logs the result of a restore, and records the Restored Event of the object.
+func (r *Restorer) restored(logger logr.Logger, recycleItem *api.RecycleItem, result *Result) {
+	if result.Outcome == OutcomeSkipped {
+		logger.Info("skipped restore of recycled object, an object of the same name exists")
+		return
+	}
+	logger.Info("restored recycled object", "outcome", result.Outcome, "restoredAs", cache.MetaObjectToName(result.Object).String())
+	r.recorder.Restored(recycleItem, result.Object)
+}
+
+// restore creates the recycled object of the RecycleItem in the namespace under the name, and
+// resolves conflicts with an existing object as the opts tell.
+func (r *Restorer) restore(ctx context.Context, recycleItem *api.RecycleItem, namespace, name string, opts Options) (*Result, error) {
+	var dryRun []string
+	if opts.DryRun {
+		dryRun = []string{metav1.DryRunAll}
+	}
+	obj, gvr, converted, err := r.build(ctx, recycleItem, namespace, name, opts.RewriteReferences)
+	if err != nil {
+		return nil, err
+	}
+	resource := r.clients.Dynamic.Resource(gvr).Namespace(namespace)
+	dropped, err := r.validate(ctx, recycleItem, resource, gvr, obj, converted, opts.OnConflict == ConflictOverwrite)
+	if err != nil {
+		return nil, err
+	}
+	created, err := resource.Create(ctx, obj, metav1.CreateOptions{DryRun: dryRun})
+	if err == nil {
+		return &Result{Outcome: OutcomeCreated, Object: created, Manifest: obj, DroppedFields: dropped}, nil
+	}
+	if !apierrors.IsAlreadyExists(err) {
+		return nil, err
+	}
+
+	switch opts.OnConflict {
+	case ConflictSkip:
+		return &Result{Outcome: OutcomeSkipped, Manifest: obj, DroppedFields: dropped}, nil
+	case ConflictOverwrite:
+		applied, err := resource.Apply(ctx, name, obj, metav1.ApplyOptions{FieldManager: FieldManager, Force: true, DryRun: dryRun})
+		if err != nil {
+			return nil, fmt.Errorf("failed to overwrite existing object: %w", err)
+		}
+		return &Result{Outcome: OutcomeOverwritten, Object: applied, Manifest: obj, DroppedFields: dropped}, nil
+	case ConflictRename:
+		// each renamed copy is validated on its own, the dropped fields reported are the ones
+		// of the copy created.
+		for range renameAttempts {
+			renamedObj, _, converted, err := r.build(ctx, recycleItem, namespace, renamed(name), opts.RewriteReferences)
+			if err != nil {
+				return nil, err
+			}
+			renamedDropped, err := r.validate(ctx, recycleItem, resource, gvr, renamedObj, converted, false)
+			if err != nil {
+				return nil, err
+			}
+			created, err := resource.Create(ctx, renamedObj, metav1.CreateOptions{DryRun: dryRun})
+			if apierrors.IsAlreadyExists(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			return &Result{Outcome: OutcomeRenamed, Object: created, Manifest: renamedObj, DroppedFields: renamedDropped}, nil
+		}
+		return nil, fmt.Errorf("failed to find a free name for %s after %d attempts", name, renameAttempts)
+	default:
+		return nil, fmt.Errorf("%w, restore it with another conflict strategy than %s", err, ConflictFail)
+	}
+}
+
+// validate validates the object of the RecycleItem built for the resource of gvr when it was
+// moved to another version, and returns the fields the api server drops from it with the ones
+// it lost to its conversion, so they are reported instead of being lost silently. Objects
+// restored in their version are not validated, see validateMoved for overwrite.
+func (r *Restorer) validate(ctx context.Context, recycleItem *api.RecycleItem, resource dynamic.ResourceInterface, gvr schema.GroupVersionResource, obj *unstructured.Unstructured, converted []string, overwrite bool) ([]string, error) {
+	if gvr.GroupVersion() == recycleItem.Object.GroupVersionResource().GroupVersion() {
+		return nil, nil
+	}
+	dropped, err := validateMoved(ctx, resource, obj, overwrite)
+	if err != nil {
+		return nil, err
+	}
+	dropped = append(dropped, converted...)
+	slices.Sort(dropped)
+	dropped = slices.Compact(dropped)
+	if len(dropped) > 0 {
+		logging.FromContext(ctx).Info("fields of recycled object are not in the version it is restored in, they are dropped", "version", gvr.GroupVersion().String(), "fields", dropped)
+	}
+	return dropped, nil
+}
+
+// build returns the sanitized recycled object of the RecycleItem in the namespace under the
+// name, with its references to its former name rewritten if rewrite is set, the resource it is
+// restored through and the fields it lost to a conversion. The object is moved to a version the
+// cluster serves, see served.
+func (r *Restorer) build(ctx context.Context, recycleItem *api.RecycleItem, namespace, name string, rewrite bool) (*unstructured.Unstructured, schema.GroupVersionResource, []string, error) {
+	unstructuredObj, err := recycleItem.Object.Unstructured()
+	if err != nil {
+		return nil, schema.GroupVersionResource{}, nil, fmt.Errorf("failed to get unstructured object: %w", err)
+	}
+	unstructuredObj, gvr, converted, err := r.served(ctx, unstructuredObj, recycleItem.Object.Resource)
+	if err != nil {
+		return nil, schema.GroupVersionResource{}, nil, err
+	}
+	if err := r.sanitizer.Sanitize(unstructuredObj); err != nil {
+		return nil, schema.GroupVersionResource{}, nil, err
+	}
+	if rewrite && name != recycleItem.Object.Name {
+		rewriteReferences(unstructuredObj, recycleItem.Object.Name, name)
+	}
+	unstructuredObj.SetNamespace(namespace)
+	unstructuredObj.SetName(name)
+	return unstructuredObj, gvr, converted, nil
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+	krbclient "github.com/wcrum/kube-recycle-bin/internal/client"
+	"github.com/wcrum/kube-recycle-bin/internal/client/fake"
+	"github.com/wcrum/kube-recycle-bin/internal/event"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// newConfigMapItem returns a RecycleItem of the deleted configmap dev/settings.
+func newConfigMapItem() *api.RecycleItem {
+	return api.NewRecycleItem(&api.RecycledObject{
+		Version:   "v1",
+		Resource:  "configmaps",
+		Kind:      "ConfigMap",
+		Namespace: "dev",
+		Name:      "settings",
+		UID:       "5d2c1b0a-9e8f-4d7c-a6b5-c4d3e2f1a0b9",
+		Raw:       []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"settings","namespace":"dev","uid":"5d2c1b0a-9e8f-4d7c-a6b5-c4d3e2f1a0b9","resourceVersion":"42","ownerReferences":[{"apiVersion":"apps/v1","kind":"Deployment","name":"web","uid":"9e8f7d6c-5b4a-4c3d-8e2f-1a0b9c8d7e6f"}]},"data":{"level":"debug"}}`),
+	}, types.UID("3f2e1d0c-8b7a-4c6d-9e5f-a4b3c2d1e0f9"))
+}
+
+// newRestorer returns a Restorer of fake clients serving the objects.
+func newRestorer(objects ...runtime.Object) (*Restorer, *krbclient.Clients) {
+	clients := fake.NewClients(objects...)
+	return NewRestorer(clients, event.NewRecorder(clients.Kubernetes, "krb-test"), DefaultSanitizer()), clients
+}
+
+// getRecycleItem returns the stored RecycleItem of the name.
+func getRecycleItem(t *testing.T, clients *krbclient.Clients, name string) *api.RecycleItem {
+	t.Helper()
+
+	recycleItem, err := clients.RecycleItem().Get(context.Background(), name, client.GetOptions{})
+	if err != nil {
+		t.Fatalf("✗ failed to get RecycleItem: %v", err)
+	}
+	return recycleItem
+}
+
+func TestRestore(t *testing.T) {
+	recycleItem := newConfigMapItem()
+	restorer, clients := newRestorer(recycleItem)
+
+	restored, err := restorer.Restore(context.Background(), recycleItem, Options{})
+	if err != nil {
+		t.Fatalf("✗ failed to restore: %v", err)
+	}
+	if restored.Outcome != OutcomeCreated {
+		t.Errorf("✗ expected outcome %s, got %s", OutcomeCreated, restored.Outcome)
+	}
+	if restored.Object.GetNamespace() != "dev" || restored.Object.GetName() != "settings" {
+		t.Errorf("✗ unexpected restored object %s/%s", restored.Object.GetNamespace(), restored.Object.GetName())
+	}
+
+	obj, err := clients.Dynamic.Resource(recycleItem.Object.GroupVersionResource()).Namespace("dev").Get(context.Background(), "settings", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("✗ failed to get restored object: %v", err)
+	}
+	if level, _, _ := unstructured.NestedString(obj.Object, "data", "level"); level != "debug" {
+		t.Errorf("✗ expected the data of the recycled object, got level %q", level)
+	}
+	if len(obj.GetOwnerReferences()) != 0 || obj.GetUID() == recycleItem.Object.UID {
+		t.Errorf("✗ expected the restored object to be sanitized, got %v", obj.Object["metadata"])
+	}
+	if got := getRecycleItem(t, clients, recycleItem.Name); got.Phase() != api.RecycleItemRestored {
+		t.Errorf("✗ expected phase %s, got %s", api.RecycleItemRestored, got.Phase())
+	}
+}
+
+func TestRestoreAlreadyRestored(t *testing.T) {
+	recycleItem := newConfigMapItem()
+	recycleItem.MarkRestored()
+	restorer, clients := newRestorer(recycleItem)
+
+	if _, err := restorer.Restore(context.Background(), recycleItem, Options{}); !errors.Is(err, ErrAlreadyRestored) {
+		t.Fatalf("✗ expected %v, got %v", ErrAlreadyRestored, err)
+	}
+	if _, err := clients.Dynamic.Resource(recycleItem.Object.GroupVersionResource()).Namespace("dev").Get(context.Background(), "settings", metav1.GetOptions{}); err == nil {
+		t.Error("✗ expected the object not to be restored again")
+	}
+}
+
+// newExistingConfigMap returns the configmap dev/settings created since the delete of the
+// recycled one.
+func newExistingConfigMap() *unstructured.Unstructured {
+	existing := &unstructured.Unstructured{}
+	existing.SetAPIVersion("v1")
+	existing.SetKind("ConfigMap")
+	existing.SetNamespace("dev")
+	existing.SetName("settings")
+	unstructured.SetNestedField(existing.Object, "info", "data", "level")
+	return existing
+}
+
+func TestRestoreFailure(t *testing.T) {
+	recycleItem := newConfigMapItem()
+	restorer, clients := newRestorer(recycleItem, newExistingConfigMap())
+
+	if _, err := restorer.Restore(context.Background(), recycleItem, Options{}); err == nil {
+		t.Fatal("✗ expected the restore to fail")
+	}
+	got := getRecycleItem(t, clients, recycleItem.Name)
+	if got.Phase() != api.RecycleItemRestoreFailed {
+		t.Errorf("✗ expected phase %s, got %s", api.RecycleItemRestoreFailed, got.Phase())
+	}
+	if got.Status.LastRestoreError == "" {
+		t.Error("✗ expected the restore error to be recorded")
+	}
+}
+
+func TestRestoreAs(t *testing.T) {
+	recycleItem := newConfigMapItem()
+	restorer, clients := newRestorer(recycleItem)
+
+	restored, err := restorer.Restore(context.Background(), recycleItem, Options{Namespace: "scratch", Name: "settings-copy"})
+	if err != nil {
+		t.Fatalf("✗ failed to restore: %v", err)
+	}
+	if restored.Object.GetNamespace() != "scratch" || restored.Object.GetName() != "settings-copy" {
+		t.Errorf("✗ unexpected restored object %s/%s", restored.Object.GetNamespace(), restored.Object.GetName())
+	}
+	if _, err := clients.Dynamic.Resource(recycleItem.Object.GroupVersionResource()).Namespace("scratch").Get(context.Background(), "settings-copy", metav1.GetOptions{}); err != nil {
+		t.Errorf("✗ failed to get restored object: %v", err)
+	}
+
+	// copies do not take the place of the recycled object, it can still be restored in place.
+	if got := getRecycleItem(t, clients, recycleItem.Name); got.Phase() != api.RecycleItemRecycled {
+		t.Errorf("✗ expected the RecycleItem to be left %s, got %s", api.RecycleItemRecycled, got.Phase())
+	}
+	if _, err := restorer.Restore(context.Background(), getRecycleItem(t, clients, recycleItem.Name), Options{}); err != nil {
+		t.Fatalf("✗ failed to restore in place after a copy: %v", err)
+	}
+	if _, err := restorer.Restore(context.Background(), getRecycleItem(t, clients, recycleItem.Name), Options{Name: "settings-copy-2"}); err != nil {
+		t.Errorf("✗ failed to copy a restored RecycleItem: %v", err)
+	}
+}
+
+func TestRestoreClusterScopedIntoNamespace(t *testing.T) {
+	recycleItem := api.NewRecycleItem(&api.RecycledObject{
+		Version:  "v1",
+		Resource: "namespaces",
+		Kind:     "Namespace",
+		Name:     "dev",
+		UID:      "0a9b8c7d-6e5f-4a3b-2c1d-0e9f8a7b6c5d",
+		Raw:      []byte(`{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"dev"}}`),
+	}, types.UID("1b0a9c8d-7e6f-4b5a-3c2d-1e0f9a8b7c6d"))
+	restorer, clients := newRestorer(recycleItem)
+
+	if _, err := restorer.Restore(context.Background(), recycleItem, Options{Namespace: "scratch"}); !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("✗ expected %v, got %v", ErrInvalidOptions, err)
+	}
+	if got := getRecycleItem(t, clients, recycleItem.Name); got.Phase() != api.RecycleItemRecycled {
+		t.Errorf("✗ expected the RecycleItem to be left %s, got %s", api.RecycleItemRecycled, got.Phase())
+	}
+}
+
+func TestRestoreOnConflict(t *testing.T) {
+	tests := []struct {
+		onConflict  ConflictStrategy
+		wantOutcome Outcome
+		wantPhase   api.RecycleItemPhase
+		// wantLevel is the level of the configmap dev/settings after the restore.
+		wantLevel string
+	}{
+		{onConflict: ConflictSkip, wantOutcome: OutcomeSkipped, wantPhase: api.RecycleItemRecycled, wantLevel: "info"},
+		{onConflict: ConflictOverwrite, wantOutcome: OutcomeOverwritten, wantPhase: api.RecycleItemRestored, wantLevel: "debug"},
+		{onConflict: ConflictRename, wantOutcome: OutcomeRenamed, wantPhase: api.RecycleItemRestored, wantLevel: "info"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.onConflict), func(t *testing.T) {
+			recycleItem := newConfigMapItem()
+			restorer, clients := newRestorer(recycleItem, newExistingConfigMap())
+
+			restored, err := restorer.Restore(context.Background(), recycleItem, Options{OnConflict: tt.onConflict})
+			if err != nil {
+				t.Fatalf("✗ failed to restore: %v", err)
+			}
+			if restored.Outcome != tt.wantOutcome {
+				t.Errorf("✗ expected outcome %s, got %s", tt.wantOutcome, restored.Outcome)
+			}
+			if got := getRecycleItem(t, clients, recycleItem.Name); got.Phase() != tt.wantPhase {
+				t.Errorf("✗ expected phase %s, got %s", tt.wantPhase, got.Phase())
+			}
+
+			configMaps := clients.Dynamic.Resource(recycleItem.Object.GroupVersionResource()).Namespace("dev")
+			existing, err := configMaps.Get(context.Background(), "settings", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("✗ failed to get existing object: %v", err)
+			}
+			if level, _, _ := unstructured.NestedString(existing.Object, "data", "level"); level != tt.wantLevel {
+				t.Errorf("✗ expected level %q, got %q", tt.wantLevel, level)
+			}
+
+			if tt.wantOutcome == OutcomeRenamed {
+				if !strings.HasPrefix(restored.Object.GetName(), "settings-restored-") {
+					t.Errorf("✗ unexpected renamed object %s", restored.Object.GetName())
+				}
+				if _, err := configMaps.Get(context.Background(), restored.Object.GetName(), metav1.GetOptions{}); err != nil {
+					t.Errorf("✗ failed to get renamed object: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreUnknownConflictStrategy(t *testing.T) {
+	recycleItem := newConfigMapItem()
+	restorer, _ := newRestorer(recycleItem)
+
+	if _, err := restorer.Restore(context.Background(), recycleItem, Options{OnConflict: "merge"}); !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("✗ expected %v, got %v", ErrInvalidOptions, err)
+	}
+}
+
+func TestRenamed(t *testing.T) {
+	for _, name := range []string{"settings", strings.Repeat("a", 63)} {
+		got := renamed(name)
+		if errs := validation.IsDNS1123Label(got); len(errs) != 0 {
+			t.Errorf("✗ expected a DNS label for %s, got %s: %v", name, got, errs)
+		}
+	}
+}
+
+func TestRestoreDryRun(t *testing.T) {
+	recycleItem := newConfigMapItem()
+	restorer, clients := newRestorer(recycleItem)
+
+	result, err := restorer.Restore(context.Background(), recycleItem, Options{DryRun: true})
+	if err != nil {
+		t.Fatalf("✗ failed to restore: %v", err)
+	}
+	if result.Outcome != OutcomeCreated {
+		t.Errorf("✗ expected outcome %s, got %s", OutcomeCreated, result.Outcome)
+	}
+	if result.Manifest.GetUID() != "" || len(result.Manifest.GetOwnerReferences()) != 0 {
+		t.Errorf("✗ expected a sanitized manifest, got %v", result.Manifest.Object["metadata"])
+	}
+	if got := getRecycleItem(t, clients, recycleItem.Name); got.Phase() != api.RecycleItemRecycled {
+		t.Errorf("✗ expected the RecycleItem to be left %s, got %s", api.RecycleItemRecycled, got.Phase())
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restore
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// labelPaths are the paths of the label maps and selectors of objects and their pod templates,
+// rewritten together so selectors keep matching the labels of the restored object.
+var labelPaths = []string{
+	"metadata.labels",
+	// Services and ReplicationControllers select by a plain map.
+	"spec.selector",
+	"spec.selector.matchLabels",
+	"spec.template.metadata.labels",
+	"spec.jobTemplate.spec.selector.matchLabels",
+	"spec.jobTemplate.spec.template.metadata.labels",
+}
+
+// labelExpressionPaths are the paths of the match expressions of selectors.
+var labelExpressionPaths = []string{
+	"spec.selector.matchExpressions.*",
+	"spec.jobTemplate.spec.selector.matchExpressions.*",
+}
+
+// podSpecPaths are the paths of the pod specs of Pods, workloads and CronJobs.
+var podSpecPaths = []string{
+	"spec",
+	"spec.template.spec",
+	"spec.jobTemplate.spec.template.spec",
+}
+
+// podSpecReferences are the ConfigMap and Secret references of a pod spec, as the path of the
+// maps holding the reference and the field of the referenced name.
+var podSpecReferences = []struct {
+	path  string
+	field string
+}{
+	{path: "volumes.*.configMap", field: "name"},
+	{path: "volumes.*.secret", field: "secretName"},
+	{path: "volumes.*.projected.sources.*.configMap", field: "name"},
+	{path: "volumes.*.projected.sources.*.secret", field: "name"},
+	{path: "imagePullSecrets.*", field: "name"},
+	{path: "initContainers.*.env.*.valueFrom.configMapKeyRef", field: "name"},
+	{path: "initContainers.*.env.*.valueFrom.secretKeyRef", field: "name"},
+	{path: "initContainers.*.envFrom.*.configMapRef", field: "name"},
+	{path: "initContainers.*.envFrom.*.secretRef", field: "name"},
+	{path: "containers.*.env.*.valueFrom.configMapKeyRef", field: "name"},
+	{path: "containers.*.env.*.valueFrom.secretKeyRef", field: "name"},
+	{path: "containers.*.envFrom.*.configMapRef", field: "name"},
+	{path: "containers.*.envFrom.*.secretRef", field: "name"},
+}
+
+// rewriteReferences rewrites the label values, selectors and ConfigMap/Secret references of the
+// object that hold from to to. Objects restored under the same new name keep referencing each
+// other, and their selectors do not match the objects still using the former name.
+func rewriteReferences(obj *unstructured.Unstructured, from, to string) {
+	rewriteField := func(field string) func(map[string]any) {
+		return func(m map[string]any) {
+			if m[field] == from {
+				m[field] = to
+			}
+		}
+	}
+
+	for _, path := range labelPaths {
+		visit(obj.Object, path, func(labels map[string]any) {
+			for key, value := range labels {
+				if value == from {
+					labels[key] = to
+				}
+			}
+		})
+	}
+	for _, path := range labelExpressionPaths {
+		visit(obj.Object, path, func(expression map[string]any) {
+			values, _ := expression["values"].([]any)
+			for i := range values {
+				if values[i] == from {
+					values[i] = to
+				}
+			}
+		})
+	}
+	for _, podSpecPath := range podSpecPaths {
+		for _, reference := range podSpecReferences {
+			visit(obj.Object, podSpecPath+"."+reference.path, rewriteField(reference.field))
+		}
+	}
+}
+
+// visit calls fn with the maps found at the dot-separated path in obj, a "*" segment visits
+// every item of a list.
+func visit(obj any, path string, fn func(map[string]any)) {
+	visitSegments(obj, strings.Split(path, "."), fn)
+}
+
+func visitSegments(obj any, segments []string, fn func(map[string]any)) {
+	switch obj := obj.(type) {
+	case map[string]any:
+		if len(segments) == 0 {
+			fn(obj)
+		} else if segments[0] != "*" {
+			visitSegments(obj[segments[0]], segments[1:], fn)
+		}
+	case []any:
+		if len(segments) > 0 && segments[0] == "*" {
+			for _, item := range obj {
+				visitSegments(item, segments[1:], fn)
+			}
+		}
+	}
+}
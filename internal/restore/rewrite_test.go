@@ -0,0 +1,54 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restore
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRewriteReferences(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		obj  string
+		want string
+	}{
+		{
+			name: "deployment",
+			obj:  `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"web","labels":{"app":"web","tier":"frontend"}},"spec":{"selector":{"matchLabels":{"app":"web"},"matchExpressions":[{"key":"component","operator":"In","values":["web","api"]}]},"template":{"metadata":{"labels":{"app":"web","component":"web"}},"spec":{"volumes":[{"name":"config","configMap":{"name":"web"}},{"name":"tls","secret":{"secretName":"web"}},{"name":"shared","configMap":{"name":"shared"}}],"containers":[{"name":"web","env":[{"name":"TOKEN","valueFrom":{"secretKeyRef":{"name":"web","key":"token"}}}],"envFrom":[{"configMapRef":{"name":"web"}}]}]}}}}`,
+			want: `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"web","labels":{"app":"web-copy","tier":"frontend"}},"spec":{"selector":{"matchLabels":{"app":"web-copy"},"matchExpressions":[{"key":"component","operator":"In","values":["web-copy","api"]}]},"template":{"metadata":{"labels":{"app":"web-copy","component":"web-copy"}},"spec":{"volumes":[{"name":"config","configMap":{"name":"web-copy"}},{"name":"tls","secret":{"secretName":"web-copy"}},{"name":"shared","configMap":{"name":"shared"}}],"containers":[{"name":"web","env":[{"name":"TOKEN","valueFrom":{"secretKeyRef":{"name":"web-copy","key":"token"}}}],"envFrom":[{"configMapRef":{"name":"web-copy"}}]}]}}}}`,
+		},
+		{
+			name: "service",
+			obj:  `{"apiVersion":"v1","kind":"Service","metadata":{"name":"web"},"spec":{"selector":{"app":"web"},"ports":[{"name":"web","port":80}]}}`,
+			want: `{"apiVersion":"v1","kind":"Service","metadata":{"name":"web"},"spec":{"selector":{"app":"web-copy"},"ports":[{"name":"web","port":80}]}}`,
+		},
+		{
+			name: "cronjob",
+			obj:  `{"apiVersion":"batch/v1","kind":"CronJob","metadata":{"name":"web"},"spec":{"jobTemplate":{"spec":{"template":{"metadata":{"labels":{"app":"web"}},"spec":{"imagePullSecrets":[{"name":"web"}],"containers":[{"name":"web"}]}}}}}}`,
+			want: `{"apiVersion":"batch/v1","kind":"CronJob","metadata":{"name":"web"},"spec":{"jobTemplate":{"spec":{"template":{"metadata":{"labels":{"app":"web-copy"}},"spec":{"imagePullSecrets":[{"name":"web-copy"}],"containers":[{"name":"web"}]}}}}}}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := objectOf(t, tt.obj)
+			rewriteReferences(obj, "web", "web-copy")
+			if want := objectOf(t, tt.want); !reflect.DeepEqual(obj.Object, want.Object) {
+				t.Errorf("✗ unexpected rewritten object\n got: %v\nwant: %v", obj.Object, want.Object)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restore
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Sanitizer prepares a recycled object to be created again, dropping the fields the api
+// server set on the deleted object and rejects or reallocates on create.
+type Sanitizer interface {
+	Sanitize(obj *unstructured.Unstructured) error
+}
+
+// SanitizerFunc is a Sanitizer function.
+type SanitizerFunc func(obj *unstructured.Unstructured) error
+
+func (f SanitizerFunc) Sanitize(obj *unstructured.Unstructured) error {
+	return f(obj)
+}
+
+// Sanitizers runs its generic Sanitizers on every object, then the ones registered for the
+// kind of the object.
+type Sanitizers struct {
+	generic []Sanitizer
+	kinds   map[schema.GroupKind][]Sanitizer
+}
+
+var _ Sanitizer = &Sanitizers{}
+
+// NewSanitizers returns Sanitizers running the generic ones on every object.
+func NewSanitizers(generic ...Sanitizer) *Sanitizers {
+	return &Sanitizers{
+		generic: generic,
+		kinds:   make(map[schema.GroupKind][]Sanitizer),
+	}
+}
+
+// Register adds Sanitizers for the objects of the kind, run after the generic ones in the
+// order they are registered.
+func (s *Sanitizers) Register(gk schema.GroupKind, sanitizers ...Sanitizer) *Sanitizers {
+	s.kinds[gk] = append(s.kinds[gk], sanitizers...)
+	return s
+}
+
+func (s *Sanitizers) Sanitize(obj *unstructured.Unstructured) error {
+	gk := obj.GroupVersionKind().GroupKind()
+	for _, sanitizers := range [][]Sanitizer{s.generic, s.kinds[gk]} {
+		for _, sanitizer := range sanitizers {
+			if err := sanitizer.Sanitize(obj); err != nil {
+				return fmt.Errorf("failed to sanitize %s %s: %w", gk, obj.GetName(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// DefaultSanitizer returns the Sanitizers of krb: the metadata cleaner, and the handlers of
+// the built-in kinds whose specs keep values allocated to the deleted object.
+func DefaultSanitizer() *Sanitizers {
+	return NewSanitizers(SanitizerFunc(sanitizeMetadata)).
+		Register(schema.GroupKind{Kind: "Service"}, SanitizerFunc(sanitizeService)).
+		Register(schema.GroupKind{Kind: "Pod"}, SanitizerFunc(sanitizePod)).
+		Register(schema.GroupKind{Kind: "PersistentVolumeClaim"}, SanitizerFunc(sanitizePersistentVolumeClaim)).
+		Register(schema.GroupKind{Kind: "PersistentVolume"}, SanitizerFunc(sanitizePersistentVolume)).
+		Register(schema.GroupKind{Group: "batch", Kind: "Job"}, SanitizerFunc(sanitizeJob))
+}
+
+// sanitizeMetadata drops the metadata the api server owns, and the status observed on the
+// deleted object.
+func sanitizeMetadata(obj *unstructured.Unstructured) error {
+	for _, field := range []string{
+		"uid",
+		"resourceVersion",
+		"generation",
+		"creationTimestamp",
+		"deletionTimestamp",
+		"deletionGracePeriodSeconds",
+		"managedFields",
+		"selfLink",
+		// owners were deleted with the object or are recreated with a new uid, a restored
+		// object referencing them would be garbage collected.
+		"ownerReferences",
+	} {
+		unstructured.RemoveNestedField(obj.Object, "metadata", field)
+	}
+	unstructured.RemoveNestedField(obj.Object, "status")
+	return nil
+}
+
+// sanitizeService drops the cluster IPs allocated to the deleted Service, they may have been
+// allocated to another Service since. Headless Services keep their None cluster IP.
+func sanitizeService(obj *unstructured.Unstructured) error {
+	clusterIP, _, err := unstructured.NestedString(obj.Object, "spec", "clusterIP")
+	if err != nil {
+		return err
+	}
+	if clusterIP == "None" {
+		return nil
+	}
+	unstructured.RemoveNestedField(obj.Object, "spec", "clusterIP")
+	unstructured.RemoveNestedField(obj.Object, "spec", "clusterIPs")
+	return nil
+}
+
+// serviceAccountVolumePrefix prefixes the projected service account token volume injected into
+// Pods on create.
+const serviceAccountVolumePrefix = "kube-api-access-"
+
+// sanitizePod drops the node the deleted Pod was scheduled to, and the service account token
+// volume injected into it, a new one is injected into the restored Pod.
+func sanitizePod(obj *unstructured.Unstructured) error {
+	unstructured.RemoveNestedField(obj.Object, "spec", "nodeName")
+
+	volumes, _, err := unstructured.NestedSlice(obj.Object, "spec", "volumes")
+	if err != nil {
+		return err
+	}
+	volumes = removeNamedPrefix(volumes, serviceAccountVolumePrefix)
+	if len(volumes) == 0 {
+		unstructured.RemoveNestedField(obj.Object, "spec", "volumes")
+	} else if err := unstructured.SetNestedSlice(obj.Object, volumes, "spec", "volumes"); err != nil {
+		return err
+	}
+
+	for _, field := range []string{"initContainers", "containers", "ephemeralContainers"} {
+		containers, found, err := unstructured.NestedSlice(obj.Object, "spec", field)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		for i := range containers {
+			container, ok := containers[i].(map[string]any)
+			if !ok {
+				continue
+			}
+			mounts, _, err := unstructured.NestedSlice(container, "volumeMounts")
+			if err != nil {
+				return err
+			}
+			mounts = removeNamedPrefix(mounts, serviceAccountVolumePrefix)
+			if len(mounts) == 0 {
+				delete(container, "volumeMounts")
+			} else {
+				container["volumeMounts"] = mounts
+			}
+		}
+		if err := unstructured.SetNestedSlice(obj.Object, containers, "spec", field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeNamedPrefix returns the items whose name does not start with the prefix.
+func removeNamedPrefix(items []any, prefix string) []any {
+	result := items[:0]
+	for _, item := range items {
+		if m, ok := item.(map[string]any); ok {
+			if name, _ := m["name"].(string); strings.HasPrefix(name, prefix) {
+				continue
+			}
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// persistentVolumeClaimBindAnnotations are set by the persistent volume controller when it
+// binds and provisions claims.
+var persistentVolumeClaimBindAnnotations = []string{
+	"pv.kubernetes.io/bind-completed",
+	"pv.kubernetes.io/bound-by-controller",
+	"volume.beta.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/selected-node",
+}
+
+// sanitizePersistentVolumeClaim drops the binding of the deleted claim. Its volume was
+// released with the claim and cannot be bound again, a new one is bound to the restored claim.
+func sanitizePersistentVolumeClaim(obj *unstructured.Unstructured) error {
+	unstructured.RemoveNestedField(obj.Object, "spec", "volumeName")
+	removeAnnotations(obj, persistentVolumeClaimBindAnnotations...)
+	return nil
+}
+
+// sanitizePersistentVolume drops the uid and resourceVersion of the claim bound to the deleted
+// volume, so the restored volume is bound to the claim of the same name.
+func sanitizePersistentVolume(obj *unstructured.Unstructured) error {
+	unstructured.RemoveNestedField(obj.Object, "spec", "claimRef", "uid")
+	unstructured.RemoveNestedField(obj.Object, "spec", "claimRef", "resourceVersion")
+	removeAnnotations(obj, "pv.kubernetes.io/bound-by-controller")
+	return nil
+}
+
+// jobControllerUIDLabels are the labels the Job controller selects the pods of a Job by.
+var jobControllerUIDLabels = []string{
+	"controller-uid",
+	"batch.kubernetes.io/controller-uid",
+}
+
+// sanitizeJob drops the selector and labels generated from the uid of the deleted Job, the
+// api server generates them again from the uid of the restored one. Jobs with a manual
+// selector keep it.
+func sanitizeJob(obj *unstructured.Unstructured) error {
+	manualSelector, _, err := unstructured.NestedBool(obj.Object, "spec", "manualSelector")
+	if err != nil {
+		return err
+	}
+	if manualSelector {
+		return nil
+	}
+	unstructured.RemoveNestedField(obj.Object, "spec", "selector")
+	for _, label := range jobControllerUIDLabels {
+		unstructured.RemoveNestedField(obj.Object, "metadata", "labels", label)
+		unstructured.RemoveNestedField(obj.Object, "spec", "template", "metadata", "labels", label)
+	}
+	return nil
+}
+
+// removeAnnotations removes the annotations from the object.
+func removeAnnotations(obj *unstructured.Unstructured, keys ...string) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		return
+	}
+	for _, key := range keys {
+		delete(annotations, key)
+	}
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	obj.SetAnnotations(annotations)
+}
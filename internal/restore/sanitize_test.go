@@ -0,0 +1,133 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restore
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// objectOf decodes the JSON object of a test case.
+func objectOf(t *testing.T, s string) *unstructured.Unstructured {
+	t.Helper()
+
+	obj := &unstructured.Unstructured{}
+	if err := json.Unmarshal([]byte(s), obj); err != nil {
+		t.Fatalf("✗ failed to decode %s: %v", s, err)
+	}
+	return obj
+}
+
+func TestDefaultSanitizer(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		obj  string
+		want string
+	}{
+		{
+			name: "metadata",
+			obj:  `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"settings","namespace":"dev","uid":"5d2c1b0a","resourceVersion":"42","creationTimestamp":"2025-01-01T00:00:00Z","deletionTimestamp":"2025-01-02T00:00:00Z","deletionGracePeriodSeconds":0,"managedFields":[{"manager":"kubectl"}],"ownerReferences":[{"kind":"Deployment","name":"web","uid":"9e8f"}],"labels":{"app":"web"}},"data":{"level":"debug"}}`,
+			want: `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"settings","namespace":"dev","labels":{"app":"web"}},"data":{"level":"debug"}}`,
+		},
+		{
+			name: "service",
+			obj:  `{"apiVersion":"v1","kind":"Service","metadata":{"name":"web","namespace":"dev"},"spec":{"clusterIP":"10.96.0.12","clusterIPs":["10.96.0.12"],"ports":[{"port":80}]},"status":{"loadBalancer":{}}}`,
+			want: `{"apiVersion":"v1","kind":"Service","metadata":{"name":"web","namespace":"dev"},"spec":{"ports":[{"port":80}]}}`,
+		},
+		{
+			name: "headless service",
+			obj:  `{"apiVersion":"v1","kind":"Service","metadata":{"name":"db","namespace":"dev"},"spec":{"clusterIP":"None","clusterIPs":["None"]}}`,
+			want: `{"apiVersion":"v1","kind":"Service","metadata":{"name":"db","namespace":"dev"},"spec":{"clusterIP":"None","clusterIPs":["None"]}}`,
+		},
+		{
+			name: "job",
+			obj:  `{"apiVersion":"batch/v1","kind":"Job","metadata":{"name":"migrate","namespace":"dev","labels":{"batch.kubernetes.io/controller-uid":"7a6b","batch.kubernetes.io/job-name":"migrate","controller-uid":"7a6b","job-name":"migrate"}},"spec":{"selector":{"matchLabels":{"batch.kubernetes.io/controller-uid":"7a6b"}},"template":{"metadata":{"labels":{"batch.kubernetes.io/controller-uid":"7a6b","controller-uid":"7a6b","job-name":"migrate"}},"spec":{"restartPolicy":"Never"}}}}`,
+			want: `{"apiVersion":"batch/v1","kind":"Job","metadata":{"name":"migrate","namespace":"dev","labels":{"batch.kubernetes.io/job-name":"migrate","job-name":"migrate"}},"spec":{"template":{"metadata":{"labels":{"job-name":"migrate"}},"spec":{"restartPolicy":"Never"}}}}`,
+		},
+		{
+			name: "job with manual selector",
+			obj:  `{"apiVersion":"batch/v1","kind":"Job","metadata":{"name":"migrate","namespace":"dev"},"spec":{"manualSelector":true,"selector":{"matchLabels":{"app":"migrate"}},"template":{"metadata":{"labels":{"app":"migrate"}}}}}`,
+			want: `{"apiVersion":"batch/v1","kind":"Job","metadata":{"name":"migrate","namespace":"dev"},"spec":{"manualSelector":true,"selector":{"matchLabels":{"app":"migrate"}},"template":{"metadata":{"labels":{"app":"migrate"}}}}}`,
+		},
+		{
+			name: "persistent volume claim",
+			obj:  `{"apiVersion":"v1","kind":"PersistentVolumeClaim","metadata":{"name":"data","namespace":"dev","annotations":{"pv.kubernetes.io/bind-completed":"yes","volume.kubernetes.io/storage-provisioner":"ebs.csi.aws.com"}},"spec":{"volumeName":"pvc-1f2e","resources":{"requests":{"storage":"1Gi"}}}}`,
+			want: `{"apiVersion":"v1","kind":"PersistentVolumeClaim","metadata":{"name":"data","namespace":"dev"},"spec":{"resources":{"requests":{"storage":"1Gi"}}}}`,
+		},
+		{
+			name: "persistent volume",
+			obj:  `{"apiVersion":"v1","kind":"PersistentVolume","metadata":{"name":"data"},"spec":{"claimRef":{"name":"data","namespace":"dev","uid":"1f2e","resourceVersion":"7"}}}`,
+			want: `{"apiVersion":"v1","kind":"PersistentVolume","metadata":{"name":"data"},"spec":{"claimRef":{"name":"data","namespace":"dev"}}}`,
+		},
+		{
+			name: "pod",
+			obj:  `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"web","namespace":"dev"},"spec":{"nodeName":"node-1","volumes":[{"name":"config"},{"name":"kube-api-access-x7k2p"}],"containers":[{"name":"web","volumeMounts":[{"name":"config"},{"name":"kube-api-access-x7k2p"}]},{"name":"sidecar","volumeMounts":[{"name":"kube-api-access-x7k2p"}]}]}}`,
+			want: `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"web","namespace":"dev"},"spec":{"volumes":[{"name":"config"}],"containers":[{"name":"web","volumeMounts":[{"name":"config"}]},{"name":"sidecar"}]}}`,
+		},
+		{
+			name: "kind of another group",
+			obj:  `{"apiVersion":"example.com/v1","kind":"Service","metadata":{"name":"web","namespace":"dev"},"spec":{"clusterIP":"10.96.0.12"}}`,
+			want: `{"apiVersion":"example.com/v1","kind":"Service","metadata":{"name":"web","namespace":"dev"},"spec":{"clusterIP":"10.96.0.12"}}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := objectOf(t, tt.obj)
+			if err := DefaultSanitizer().Sanitize(obj); err != nil {
+				t.Fatalf("✗ failed to sanitize: %v", err)
+			}
+			if want := objectOf(t, tt.want); !reflect.DeepEqual(obj.Object, want.Object) {
+				t.Errorf("✗ unexpected sanitized object\n got: %v\nwant: %v", obj.Object, want.Object)
+			}
+		})
+	}
+}
+
+func TestSanitizersRegister(t *testing.T) {
+	var calls []string
+	record := func(name string) Sanitizer {
+		return SanitizerFunc(func(*unstructured.Unstructured) error {
+			calls = append(calls, name)
+			return nil
+		})
+	}
+	sanitizers := NewSanitizers(record("generic")).
+		Register(schema.GroupKind{Group: "example.com", Kind: "Widget"}, record("widget"), record("widget-2"))
+
+	for _, obj := range []string{
+		`{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"w"}}`,
+		`{"apiVersion":"example.com/v1","kind":"Gadget","metadata":{"name":"g"}}`,
+	} {
+		if err := sanitizers.Sanitize(objectOf(t, obj)); err != nil {
+			t.Fatalf("✗ failed to sanitize: %v", err)
+		}
+	}
+	if want := []string{"generic", "widget", "widget-2", "generic"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("✗ expected calls %v, got %v", want, calls)
+	}
+
+	failing := NewSanitizers(SanitizerFunc(func(*unstructured.Unstructured) error {
+		return errors.New("boom")
+	}))
+	if err := failing.Sanitize(objectOf(t, `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"settings"}}`)); err == nil {
+		t.Error("✗ expected the error of the sanitizer")
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restore
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/wcrum/kube-recycle-bin/pkg/logging"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// movedResources are the built-in resources that moved to another group, by the group they
+// moved from, like deployments served by extensions/v1beta1 before apps/v1.
+var movedResources = map[schema.GroupResource]string{
+	{Group: "extensions", Resource: "daemonsets"}:      "apps",
+	{Group: "extensions", Resource: "deployments"}:     "apps",
+	{Group: "extensions", Resource: "replicasets"}:     "apps",
+	{Group: "extensions", Resource: "ingresses"}:       "networking.k8s.io",
+	{Group: "extensions", Resource: "networkpolicies"}: "networking.k8s.io",
+}
+
+// served returns the resource the recycled object is restored through, the object in its
+// version and the fields it lost to a conversion. Objects whose version the cluster stopped
+// serving, like a removed v1beta1 version of a CRD or an extensions/v1beta1 deployment, are
+// restored in a version the cluster serves of their kind, in the group it moved to for the
+// built-in kinds of movedResources. Built-in kinds are converted to the first of the versions
+// that converts, preferred first, a ConversionError is returned if none does. The other kinds,
+// whose versions share their fields when the api server does not convert them, are moved as
+// they are to the preferred version. The api server validates the moved objects on restore, see
+// validateMoved. ErrVersionUnavailable is returned when the kind is no longer served at all.
+func (r *Restorer) served(ctx context.Context, obj *unstructured.Unstructured, resource string) (*unstructured.Unstructured, schema.GroupVersionResource, []string, error) {
+	gvk := obj.GroupVersionKind()
+	mapper := r.clients.Discovery.RESTMapper()
+	_, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err == nil {
+		return obj, gvk.GroupVersion().WithResource(resource), nil, nil
+	}
+	if !meta.IsNoMatchError(err) {
+		return nil, schema.GroupVersionResource{}, nil, fmt.Errorf("failed to discover %s: %w", gvk, err)
+	}
+
+	groupKind := gvk.GroupKind()
+	if group, ok := movedResources[schema.GroupResource{Group: gvk.Group, Resource: resource}]; ok {
+		groupKind.Group = group
+	}
+	mappings, err := mapper.RESTMappings(groupKind)
+	if meta.IsNoMatchError(err) || (err == nil && len(mappings) == 0) {
+		return nil, schema.GroupVersionResource{}, nil, fmt.Errorf("%w: %s is not served by the cluster in any version", ErrVersionUnavailable, groupKind)
+	}
+	if err != nil {
+		return nil, schema.GroupVersionResource{}, nil, fmt.Errorf("failed to discover %s: %w", groupKind, err)
+	}
+	logger := logging.FromContext(ctx)
+
+	if !conversions.Recognizes(gvk) {
+		moved := obj.DeepCopy()
+		moved.SetAPIVersion(mappings[0].GroupVersionKind.GroupVersion().String())
+		logger.Info("version of recycled object is no longer served, restoring it in the preferred version", "from", gvk.GroupVersion().String(), "to", moved.GetAPIVersion())
+		return moved, mappings[0].Resource, nil, nil
+	}
+	served := make([]string, 0, len(mappings))
+	for _, mapping := range mappings {
+		converted, dropped, err := convert(obj, mapping.GroupVersionKind)
+		if err != nil {
+			logger.V(1).Info("recycled object cannot be converted", "to", mapping.GroupVersionKind.GroupVersion().String(), "reason", err.Error())
+			served = append(served, mapping.GroupVersionKind.GroupVersion().String())
+			continue
+		}
+		logger.Info("version of recycled object is no longer served, converted it to a served version", "from", gvk.GroupVersion().String(), "to", converted.GetAPIVersion())
+		return converted, mapping.Resource, dropped, nil
+	}
+	return nil, schema.GroupVersionResource{}, nil, &ConversionError{Stored: gvk, Served: served}
+}
+
+// validateMoved validates the object moved to another version by served with a dry run of its
+// restore, and returns the fields of the object the api server dropped, which the version does
+// not have. ErrVersionUnavailable is returned when the api server rejects the object. An object
+// conflicting with an existing one is only validated if overwrite is set, as it is not restored
+// otherwise.
+func validateMoved(ctx context.Context, resource dynamic.ResourceInterface, obj *unstructured.Unstructured, overwrite bool) ([]string, error) {
+	dryRun := []string{metav1.DryRunAll}
+	validated, err := resource.Create(ctx, obj, metav1.CreateOptions{DryRun: dryRun, FieldValidation: metav1.FieldValidationIgnore})
+	if apierrors.IsAlreadyExists(err) {
+		if !overwrite {
+			return nil, nil
+		}
+		validated, err = resource.Apply(ctx, obj.GetName(), obj, metav1.ApplyOptions{FieldManager: FieldManager, Force: true, DryRun: dryRun})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: recycled object is not valid in %s: %w", ErrVersionUnavailable, obj.GetAPIVersion(), err)
+	}
+	return droppedFields(obj.Object, validated.Object, ""), nil
+}
+
+// droppedFields returns the paths of the fields of the manifest missing from the object the api
+// server returned for it, sorted by key. Lists are compared item by item when
+// the api server kept their length.
+func droppedFields(manifest, returned map[string]any, path string) []string {
+	keys := make([]string, 0, len(manifest))
+	for key := range manifest {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var dropped []string
+	for _, key := range keys {
+		fieldPath := key
+		if path != "" {
+			fieldPath = path + "." + key
+		}
+		value, ok := returned[key]
+		if !ok {
+			dropped = append(dropped, fieldPath)
+			continue
+		}
+		dropped = append(dropped, droppedValues(manifest[key], value, fieldPath)...)
+	}
+	return dropped
+}
+
+// droppedValues returns the paths of the fields of the manifest value missing from the value
+// the api server returned for it, see droppedFields.
+func droppedValues(manifest, returned any, path string) []string {
+	switch manifest := manifest.(type) {
+	case map[string]any:
+		if returned, ok := returned.(map[string]any); ok {
+			return droppedFields(manifest, returned, path)
+		}
+	case []any:
+		returned, ok := returned.([]any)
+		if !ok || len(returned) != len(manifest) {
+			return nil
+		}
+		var dropped []string
+		for i := range manifest {
+			dropped = append(dropped, droppedValues(manifest[i], returned[i], path+"["+strconv.Itoa(i)+"]")...)
+		}
+		return dropped
+	}
+	return nil
+}
@@ -0,0 +1,386 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restore
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+	krbclient "github.com/wcrum/kube-recycle-bin/internal/client"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	fakekubernetes "k8s.io/client-go/kubernetes/fake"
+)
+
+// serve adds the resources of the group version to the fake discovery of the clients.
+func serve(clients *krbclient.Clients, groupVersion string, resources ...metav1.APIResource) {
+	fakeClient := clients.Kubernetes.(*fakekubernetes.Clientset)
+	fakeClient.Resources = append(fakeClient.Resources, &metav1.APIResourceList{GroupVersion: groupVersion, APIResources: resources})
+}
+
+// newWidgetItem returns a RecycleItem of the deleted dev/blue widget of a CRD of the group
+// example.com, recycled at the version.
+func newWidgetItem(version string) *api.RecycleItem {
+	return api.NewRecycleItem(&api.RecycledObject{
+		Group:     "example.com",
+		Version:   version,
+		Resource:  "widgets",
+		Kind:      "Widget",
+		Namespace: "dev",
+		Name:      "blue",
+		Raw:       []byte(`{"apiVersion":"example.com/` + version + `","kind":"Widget","metadata":{"name":"blue","namespace":"dev","resourceVersion":"7"},"spec":{"color":"blue"}}`),
+	}, types.UID("0b1c2d3e-4f5a-4b6c-8d7e-9f0a1b2c3d4e"))
+}
+
+// newCronJobItem returns a RecycleItem of the deleted batch/v1beta1 cronjob dev/backup.
+func newCronJobItem() *api.RecycleItem {
+	return api.NewRecycleItem(&api.RecycledObject{
+		Group:     "batch",
+		Version:   "v1beta1",
+		Resource:  "cronjobs",
+		Kind:      "CronJob",
+		Namespace: "dev",
+		Name:      "backup",
+		Raw:       []byte(`{"apiVersion":"batch/v1beta1","kind":"CronJob","metadata":{"name":"backup","namespace":"dev"},"spec":{"schedule":"0 3 * * *"}}`),
+	}, types.UID("1c2d3e4f-5a6b-4c7d-9e8f-0a1b2c3d4e5f"))
+}
+
+var (
+	widgetResource  = metav1.APIResource{Name: "widgets", SingularName: "widget", Kind: "Widget", Namespaced: true}
+	cronJobResource = metav1.APIResource{Name: "cronjobs", SingularName: "cronjob", Kind: "CronJob", Namespaced: true}
+)
+
+func TestRestoreServedVersion(t *testing.T) {
+	recycleItem := newWidgetItem("v1beta1")
+	restorer, clients := newRestorer(recycleItem)
+	serve(clients, "example.com/v1beta1", widgetResource)
+	serve(clients, "example.com/v1", widgetResource)
+
+	restored, err := restorer.Restore(context.Background(), recycleItem, Options{})
+	if err != nil {
+		t.Fatalf("✗ failed to restore: %v", err)
+	}
+	if restored.Object.GetAPIVersion() != "example.com/v1beta1" {
+		t.Errorf("✗ expected the object restored in its served version, got %s", restored.Object.GetAPIVersion())
+	}
+}
+
+func TestRestoreRemovedVersion(t *testing.T) {
+	recycleItem := newWidgetItem("v1beta1")
+	restorer, clients := newRestorer(recycleItem)
+	serve(clients, "example.com/v1", widgetResource)
+
+	restored, err := restorer.Restore(context.Background(), recycleItem, Options{})
+	if err != nil {
+		t.Fatalf("✗ failed to restore: %v", err)
+	}
+	if restored.Object.GetAPIVersion() != "example.com/v1" {
+		t.Errorf("✗ expected the object restored in the preferred version, got %s", restored.Object.GetAPIVersion())
+	}
+	gvr := recycleItem.Object.GroupVersionResource()
+	gvr.Version = "v1"
+	obj, err := clients.Dynamic.Resource(gvr).Namespace("dev").Get(context.Background(), "blue", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("✗ failed to get restored object: %v", err)
+	}
+	if color, _, _ := unstructured.NestedString(obj.Object, "spec", "color"); color != "blue" {
+		t.Errorf("✗ expected the spec of the recycled object, got color %q", color)
+	}
+}
+
+func TestRestoreKindNotServed(t *testing.T) {
+	recycleItem := newWidgetItem("v1")
+	restorer, clients := newRestorer(recycleItem)
+
+	if _, err := restorer.Restore(context.Background(), recycleItem, Options{}); !errors.Is(err, ErrVersionUnavailable) {
+		t.Fatalf("✗ expected %v, got %v", ErrVersionUnavailable, err)
+	}
+	if got := getRecycleItem(t, clients, recycleItem.Name); got.Phase() != api.RecycleItemRestoreFailed {
+		t.Errorf("✗ expected phase %s, got %s", api.RecycleItemRestoreFailed, got.Phase())
+	}
+}
+
+func TestRestoreMovedVersionBuiltin(t *testing.T) {
+	recycleItem := newCronJobItem()
+	restorer, clients := newRestorer(recycleItem)
+	serve(clients, "batch/v1", cronJobResource)
+
+	restored, err := restorer.Restore(context.Background(), recycleItem, Options{})
+	if err != nil {
+		t.Fatalf("✗ failed to restore: %v", err)
+	}
+	if restored.Object.GetAPIVersion() != "batch/v1" {
+		t.Errorf("✗ expected the object restored in batch/v1, got %s", restored.Object.GetAPIVersion())
+	}
+	if schedule, _, _ := unstructured.NestedString(restored.Object.Object, "spec", "schedule"); schedule != "0 3 * * *" {
+		t.Errorf("✗ expected the schedule of the recycled object, got %q", schedule)
+	}
+	if len(restored.DroppedFields) != 0 {
+		t.Errorf("✗ expected no dropped fields, got %v", restored.DroppedFields)
+	}
+}
+
+func TestRestoreMovedGroup(t *testing.T) {
+	recycleItem := api.NewRecycleItem(&api.RecycledObject{
+		Group:     "extensions",
+		Version:   "v1beta1",
+		Resource:  "deployments",
+		Kind:      "Deployment",
+		Namespace: "dev",
+		Name:      "web",
+		// extensions/v1beta1 defaulted the selector of deployments, apps/v1 requires it.
+		Raw: []byte(`{"apiVersion":"extensions/v1beta1","kind":"Deployment","metadata":{"name":"web","namespace":"dev"},` +
+			`"spec":{"replicas":2,"rollbackTo":{"revision":1},"template":{"metadata":{"labels":{"app":"web"}}}}}`),
+	}, types.UID("2d3e4f5a-6b7c-4d8e-8f9a-1b2c3d4e5f6a"))
+	restorer, clients := newRestorer(recycleItem)
+
+	restored, err := restorer.Restore(context.Background(), recycleItem, Options{})
+	if err != nil {
+		t.Fatalf("✗ failed to restore: %v", err)
+	}
+	if restored.Object.GetAPIVersion() != "apps/v1" {
+		t.Errorf("✗ expected the object moved to apps/v1, got %s", restored.Object.GetAPIVersion())
+	}
+	obj, err := clients.Dynamic.Resource(appsv1.SchemeGroupVersion.WithResource("deployments")).Namespace("dev").Get(context.Background(), "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("✗ failed to get restored deployment: %v", err)
+	}
+	if replicas, _, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas"); replicas != 2 {
+		t.Errorf("✗ expected the replicas of the recycled object, got %d", replicas)
+	}
+	if selector, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "selector", "matchLabels"); selector["app"] != "web" || len(selector) != 1 {
+		t.Errorf("✗ expected the selector defaulted to the labels of the template, got %v", selector)
+	}
+	if !slices.Equal(restored.DroppedFields, []string{"spec.rollbackTo"}) {
+		t.Errorf("✗ expected spec.rollbackTo dropped by the conversion, got %v", restored.DroppedFields)
+	}
+}
+
+func TestRestoreConvertedIngress(t *testing.T) {
+	recycleItem := api.NewRecycleItem(&api.RecycledObject{
+		Group:     "extensions",
+		Version:   "v1beta1",
+		Resource:  "ingresses",
+		Kind:      "Ingress",
+		Namespace: "dev",
+		Name:      "web",
+		Raw: []byte(`{"apiVersion":"extensions/v1beta1","kind":"Ingress","metadata":{"name":"web","namespace":"dev"},"spec":{` +
+			`"backend":{"serviceName":"default","servicePort":80},` +
+			`"rules":[{"host":"web.example.com","http":{"paths":[{"path":"/api","backend":{"serviceName":"api","servicePort":"http"}}]}}]}}`),
+	}, types.UID("4f5a6b7c-8d9e-4f0a-9b1c-3d4e5f6a7b8c"))
+	restorer, clients := newRestorer(recycleItem)
+	serve(clients, "networking.k8s.io/v1", metav1.APIResource{Name: "ingresses", SingularName: "ingress", Kind: "Ingress", Namespaced: true})
+
+	restored, err := restorer.Restore(context.Background(), recycleItem, Options{DryRun: true})
+	if err != nil {
+		t.Fatalf("✗ failed to restore: %v", err)
+	}
+	manifest := restored.Manifest.Object
+	if restored.Manifest.GetAPIVersion() != "networking.k8s.io/v1" {
+		t.Errorf("✗ expected the ingress converted to networking.k8s.io/v1, got %s", restored.Manifest.GetAPIVersion())
+	}
+	if name, _, _ := unstructured.NestedString(manifest, "spec", "defaultBackend", "service", "name"); name != "default" {
+		t.Errorf("✗ expected the backend converted to the default backend, got service %q", name)
+	}
+	if port, _, _ := unstructured.NestedInt64(manifest, "spec", "defaultBackend", "service", "port", "number"); port != 80 {
+		t.Errorf("✗ expected the port number of the default backend, got %d", port)
+	}
+	rules, _, _ := unstructured.NestedSlice(manifest, "spec", "rules")
+	if len(rules) != 1 {
+		t.Fatalf("✗ expected the rule of the ingress, got %v", rules)
+	}
+	paths, _, _ := unstructured.NestedSlice(rules[0].(map[string]any), "http", "paths")
+	if len(paths) != 1 {
+		t.Fatalf("✗ expected the path of the rule, got %v", paths)
+	}
+	path := paths[0].(map[string]any)
+	if port, _, _ := unstructured.NestedString(path, "backend", "service", "port", "name"); port != "http" {
+		t.Errorf("✗ expected the port name of the path backend, got %q", port)
+	}
+	if pathType, _, _ := unstructured.NestedString(path, "pathType"); pathType != "ImplementationSpecific" {
+		t.Errorf("✗ expected the path type defaulted as extensions/v1beta1 did, got %q", pathType)
+	}
+	if len(restored.DroppedFields) != 0 {
+		t.Errorf("✗ expected no dropped fields, got %v", restored.DroppedFields)
+	}
+}
+
+func TestRestoreCannotConvert(t *testing.T) {
+	recycleItem := api.NewRecycleItem(&api.RecycledObject{
+		Group:     "autoscaling",
+		Version:   "v2beta1",
+		Resource:  "horizontalpodautoscalers",
+		Kind:      "HorizontalPodAutoscaler",
+		Namespace: "dev",
+		Name:      "web",
+		Raw:       []byte(`{"apiVersion":"autoscaling/v2beta1","kind":"HorizontalPodAutoscaler","metadata":{"name":"web","namespace":"dev"},"spec":{"maxReplicas":3}}`),
+	}, types.UID("5a6b7c8d-9e0f-4a1b-8c2d-4e5f6a7b8c9d"))
+	restorer, clients := newRestorer(recycleItem)
+	serve(clients, "autoscaling/v2", metav1.APIResource{Name: "horizontalpodautoscalers", SingularName: "horizontalpodautoscaler", Kind: "HorizontalPodAutoscaler", Namespaced: true})
+
+	_, err := restorer.Restore(context.Background(), recycleItem, Options{})
+	var conversionErr *ConversionError
+	if !errors.As(err, &conversionErr) {
+		t.Fatalf("✗ expected a conversion error, got %v", err)
+	}
+	if !errors.Is(err, ErrVersionUnavailable) {
+		t.Errorf("✗ expected %v, got %v", ErrVersionUnavailable, err)
+	}
+	if conversionErr.Stored.GroupVersion().String() != "autoscaling/v2beta1" || !slices.Equal(conversionErr.Served, []string{"autoscaling/v2"}) {
+		t.Errorf("✗ expected the stored and served versions in the error, got %v", conversionErr)
+	}
+}
+
+// restrictedDynamicClient is a dynamic client whose creates drop the pruned field from the
+// objects, as an api server pruning a field the version does not have, and whose applies are
+// forbidden unless patch is set, as they are to krb-server without the overwrite permission.
+type restrictedDynamicClient struct {
+	dynamic.Interface
+	pruned []string
+	patch  bool
+}
+
+func (c restrictedDynamicClient) Resource(resource schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return restrictedResource{NamespaceableResourceInterface: c.Interface.Resource(resource), client: c, resource: resource}
+}
+
+type restrictedResource struct {
+	dynamic.NamespaceableResourceInterface
+	client   restrictedDynamicClient
+	resource schema.GroupVersionResource
+}
+
+func (r restrictedResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return restrictedNamespacedResource{ResourceInterface: r.NamespaceableResourceInterface.Namespace(namespace), client: r.client, resource: r.resource}
+}
+
+type restrictedNamespacedResource struct {
+	dynamic.ResourceInterface
+	client   restrictedDynamicClient
+	resource schema.GroupVersionResource
+}
+
+func (r restrictedNamespacedResource) Create(ctx context.Context, obj *unstructured.Unstructured, opts metav1.CreateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	created, err := r.ResourceInterface.Create(ctx, obj, opts, subresources...)
+	if err == nil && len(r.client.pruned) > 0 {
+		unstructured.RemoveNestedField(created.Object, r.client.pruned...)
+	}
+	return created, err
+}
+
+func (r restrictedNamespacedResource) Apply(ctx context.Context, name string, obj *unstructured.Unstructured, opts metav1.ApplyOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if !r.client.patch {
+		return nil, apierrors.NewForbidden(r.resource.GroupResource(), name, errors.New("patch is not allowed"))
+	}
+	return r.ResourceInterface.Apply(ctx, name, obj, opts, subresources...)
+}
+
+func TestRestoreRemovedVersionRenamed(t *testing.T) {
+	recycleItem := api.NewRecycleItem(&api.RecycledObject{
+		Group:     "example.com",
+		Version:   "v1beta1",
+		Resource:  "widgets",
+		Kind:      "Widget",
+		Namespace: "dev",
+		Name:      "blue",
+		Raw:       []byte(`{"apiVersion":"example.com/v1beta1","kind":"Widget","metadata":{"name":"blue","namespace":"dev"},"spec":{"color":"blue","size":3}}`),
+	}, types.UID("3e4f5a6b-7c8d-4e9f-8a0b-2c3d4e5f6a7b"))
+	existing := &unstructured.Unstructured{}
+	existing.SetAPIVersion("example.com/v1")
+	existing.SetKind("Widget")
+	existing.SetNamespace("dev")
+	existing.SetName("blue")
+	restorer, clients := newRestorer(recycleItem, existing)
+	serve(clients, "example.com/v1", widgetResource)
+	clients.Dynamic = restrictedDynamicClient{Interface: clients.Dynamic, pruned: []string{"spec", "size"}}
+
+	// the dropped fields are the ones of the renamed copy, not of the existing object.
+	restored, err := restorer.Restore(context.Background(), recycleItem, Options{OnConflict: ConflictRename})
+	if err != nil {
+		t.Fatalf("✗ failed to restore: %v", err)
+	}
+	if restored.Outcome != OutcomeRenamed || restored.Object.GetName() == "blue" {
+		t.Fatalf("✗ expected the object renamed, got %s as %s", restored.Outcome, restored.Object.GetName())
+	}
+	if restored.Manifest.GetName() != restored.Object.GetName() {
+		t.Errorf("✗ expected the manifest of the renamed copy, got %s", restored.Manifest.GetName())
+	}
+	if !slices.Equal(restored.DroppedFields, []string{"spec.size"}) {
+		t.Errorf("✗ expected spec.size dropped from the renamed copy, got %v", restored.DroppedFields)
+	}
+}
+
+func TestRestoreRemovedVersionConflict(t *testing.T) {
+	recycleItem := newWidgetItem("v1beta1")
+	existing := &unstructured.Unstructured{}
+	existing.SetAPIVersion("example.com/v1")
+	existing.SetKind("Widget")
+	existing.SetNamespace("dev")
+	existing.SetName("blue")
+	restorer, clients := newRestorer(recycleItem, existing)
+	serve(clients, "example.com/v1", widgetResource)
+	clients.Dynamic = restrictedDynamicClient{Interface: clients.Dynamic}
+
+	// the moved object is not restored over the existing one, it needs no patch to be validated.
+	for _, onConflict := range []ConflictStrategy{ConflictFail, ConflictSkip} {
+		_, err := restorer.Restore(context.Background(), getRecycleItem(t, clients, recycleItem.Name), Options{OnConflict: onConflict})
+		if apierrors.IsForbidden(err) || errors.Is(err, ErrVersionUnavailable) {
+			t.Errorf("✗ expected the %s restore not to validate the object against the existing one, got %v", onConflict, err)
+		}
+	}
+	if _, err := restorer.Restore(context.Background(), getRecycleItem(t, clients, recycleItem.Name), Options{OnConflict: ConflictOverwrite}); !apierrors.IsForbidden(err) {
+		t.Errorf("✗ expected the overwrite to be forbidden, got %v", err)
+	}
+}
+
+func TestDroppedFields(t *testing.T) {
+	manifest := map[string]any{
+		"apiVersion": "apps/v1",
+		"spec": map[string]any{
+			"replicas":   int64(2),
+			"rollbackTo": map[string]any{"revision": int64(1)},
+			"template": map[string]any{"spec": map[string]any{"containers": []any{
+				map[string]any{"name": "web", "image": "nginx"},
+				map[string]any{"name": "sidecar", "image": "envoy", "legacy": true},
+			}}},
+		},
+	}
+	returned := map[string]any{
+		"apiVersion": "apps/v1",
+		"metadata":   map[string]any{"uid": "0b1c"},
+		"spec": map[string]any{
+			"replicas": int64(2),
+			"template": map[string]any{"spec": map[string]any{"containers": []any{
+				map[string]any{"name": "web", "image": "nginx"},
+				map[string]any{"name": "sidecar", "image": "envoy"},
+			}}},
+		},
+	}
+
+	want := []string{"spec.rollbackTo", "spec.template.spec.containers[1].legacy"}
+	if got := droppedFields(manifest, returned, ""); !slices.Equal(got, want) {
+		t.Errorf("✗ expected dropped fields %v, got %v", want, got)
+	}
+}
@@ -17,54 +17,435 @@ limitations under the License.
 package webhook
 
 import (
+	"bytes"
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/wcrum/kube-recycle-bin/internal/consts"
-	"github.com/wcrum/kube-recycle-bin/pkg/kube"
-	"github.com/wcrum/kube-recycle-bin/pkg/tlog"
+	"github.com/wcrum/kube-recycle-bin/pkg/logging"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	certuitl "k8s.io/client-go/util/cert"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	toolscache "k8s.io/client-go/tools/cache"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
 )
 
-var (
-	cert, key []byte
+const (
+	// CACertKey and CAKeyKey hold the CA signing the serving certificate in the webhook TLS secret.
+	CACertKey = "ca.crt"
+	CAKeyKey  = "ca.key"
+	// NextCACertKey and NextCAKeyKey hold the CA replacing the current one during a rotation, it
+	// signs the serving certificate once every webhook configuration trusts it.
+	NextCACertKey = "ca-next.crt"
+	NextCAKeyKey  = "ca-next.key"
+
+	caValidity      = 10 * 365 * 24 * time.Hour
+	servingValidity = 30 * 24 * time.Hour
+	// certificates are renewed once less than a third of their validity is left.
+	renewBeforeFraction = 3
+	// certCheckInterval is how often the secret is checked for renewal, or for a rotation by another replica.
+	certCheckInterval = 10 * time.Minute
 )
 
-func FetchWebhookCertAndKey() ([]byte, []byte) {
-	if cert == nil {
-		if secret, err := kube.Client().CoreV1().Secrets(consts.WebhookNamespace).Get(context.Background(), consts.WebhookTLSCertSecretName, metav1.GetOptions{}); err != nil {
-			if k8serrors.IsNotFound(err) {
-				cert, key, err = certuitl.GenerateSelfSignedCertKey(consts.WebhookDNSName, nil, []string{consts.WebhookDNSName})
-				if err != nil {
-					tlog.Fatalf("✗ failed to generate self-signed cert and key: %v", err)
-				}
-				secret = &corev1.Secret{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      consts.WebhookTLSCertSecretName,
-						Namespace: consts.WebhookNamespace,
-					},
-					Data: map[string][]byte{
-						corev1.TLSCertKey:       cert,
-						corev1.TLSPrivateKeyKey: key,
-					},
-					Type: corev1.SecretTypeTLS,
-				}
-				if _, err := kube.Client().CoreV1().Secrets(consts.WebhookNamespace).Create(context.Background(), secret, metav1.CreateOptions{}); err != nil {
-					if !k8serrors.IsAlreadyExists(err) {
-						tlog.Fatalf("✗ failed to create secret [%s]: %v", consts.WebhookTLSCertSecretName, err)
-					}
-				}
-				tlog.Infof("✓ cert secret [%s] created.", consts.WebhookTLSCertSecretName)
-			} else {
-				tlog.Fatalf("✗ failed to get secret [%s]: %v", consts.WebhookTLSCertSecretName, err)
+// CABundleFromSecret returns the CA bundle webhook clients verify the serving certificate of
+// the webhook TLS secret with, including the next CA during a rotation. Secrets written before
+// the CA was introduced hold a self-signed serving certificate only, which is its own CA.
+func CABundleFromSecret(secret *corev1.Secret) []byte {
+	return caBundle(secret.Data)
+}
+
+func caBundle(data map[string][]byte) []byte {
+	caBundle := data[CACertKey]
+	if len(caBundle) == 0 {
+		caBundle = data[corev1.TLSCertKey]
+	}
+	return append(bytes.Clone(caBundle), data[NextCACertKey]...)
+}
+
+// CertManager keeps a long-lived CA and a short-lived serving certificate signed by it in the
+// webhook TLS secret, renews them before they expire and serves the current serving certificate
+// through GetCertificate, so renewals are picked up without restarting the server.
+//
+// In cert-manager mode the secret is issued by cert-manager, the CertManager only reloads it.
+type CertManager struct {
+	client     kubernetes.Interface
+	namespace  string
+	secretName string
+	dnsName    string
+	// external tells whether the secret is issued by cert-manager rather than by the CertManager.
+	external bool
+	// caPublished tells whether every webhook configuration trusts the CA.
+	caPublished func(ctx context.Context, ca *x509.Certificate) (bool, error)
+	now         func() time.Time
+	logger      logr.Logger
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+}
+
+// NewCertManager returns a CertManager for the webhook TLS secret of krb.
+func NewCertManager(client kubernetes.Interface) *CertManager {
+	m := &CertManager{
+		client:     client,
+		namespace:  consts.WebhookNamespace,
+		secretName: consts.WebhookTLSCertSecretName,
+		dnsName:    consts.WebhookDNSName,
+		now:        time.Now,
+		logger:     logging.Logger().WithName("cert").WithValues("secret", consts.WebhookNamespace+"/"+consts.WebhookTLSCertSecretName),
+	}
+	m.caPublished = m.webhookConfigurationTrusts
+	return m
+}
+
+// NewCertManagerForCertManager returns a CertManager serving the webhook TLS secret issued by cert-manager.
+func NewCertManagerForCertManager(client kubernetes.Interface) *CertManager {
+	m := NewCertManager(client)
+	m.external = true
+	return m
+}
+
+// GetCertificate returns the current serving certificate, for use in tls.Config.
+func (m *CertManager) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.certificate == nil {
+		return nil, errors.New("serving certificate is not loaded yet")
+	}
+	return m.certificate, nil
+}
+
+// Start reloads the serving certificate whenever the secret changes and, unless the secret is
+// issued by cert-manager, renews the certificates until the context is done.
+func (m *CertManager) Start(ctx context.Context) {
+	factory := informers.NewSharedInformerFactoryWithOptions(m.client, 0,
+		informers.WithNamespace(m.namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", m.secretName).String()
+		}))
+	reload := func(obj any) {
+		if secret, ok := obj.(*corev1.Secret); ok {
+			if err := m.load(secret.Data); err != nil {
+				m.logger.Error(err, "failed to reload webhook serving certificate")
+			}
+		}
+	}
+	if _, err := factory.Core().V1().Secrets().Informer().AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    reload,
+		UpdateFunc: func(_, obj any) { reload(obj) },
+	}); err != nil {
+		m.logger.Error(err, "failed to watch secret")
+	}
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+
+	if m.external {
+		<-ctx.Done()
+		return
+	}
+
+	ticker := time.NewTicker(certCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.EnsureCertificates(ctx); err != nil {
+				m.logger.Error(err, "failed to renew webhook certificates")
 			}
+		}
+	}
+}
+
+// EnsureCertificates loads the certificates from the secret, renews those expiring soon and
+// loads the serving certificate for GetCertificate. Replicas renewing concurrently converge
+// on the secret written first.
+func (m *CertManager) EnsureCertificates(ctx context.Context) error {
+	for range 3 {
+		err := m.ensureCertificates(ctx)
+		if !k8serrors.IsConflict(err) && !k8serrors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+	return fmt.Errorf("secret [%s/%s] kept changing while renewing certificates", m.namespace, m.secretName)
+}
+
+func (m *CertManager) ensureCertificates(ctx context.Context) error {
+	secret, err := m.client.CoreV1().Secrets(m.namespace).Get(ctx, m.secretName, metav1.GetOptions{})
+	exists := err == nil
+	if k8serrors.IsNotFound(err) {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      m.secretName,
+				Namespace: m.namespace,
+			},
+			Type: corev1.SecretTypeTLS,
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to get secret [%s/%s]: %w", m.namespace, m.secretName, err)
+	}
+
+	if m.external {
+		if !exists {
+			return fmt.Errorf("secret [%s/%s] not found, is it issued by cert-manager: %w", m.namespace, m.secretName, err)
+		}
+		return m.load(secret.Data)
+	}
+
+	data, renewed, err := m.renew(ctx, secret.Data)
+	if err != nil {
+		return err
+	}
+
+	if renewed {
+		secret.Data = data
+		if !exists {
+			_, err = m.client.CoreV1().Secrets(m.namespace).Create(ctx, secret, metav1.CreateOptions{})
 		} else {
-			tlog.Infof("✓ cert secret [%s] found.", consts.WebhookTLSCertSecretName)
-			cert = secret.Data[corev1.TLSCertKey]
-			key = secret.Data[corev1.TLSPrivateKeyKey]
+			_, err = m.client.CoreV1().Secrets(m.namespace).Update(ctx, secret, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			return err
+		}
+		m.logger.Info("webhook certificates renewed")
+	}
+
+	return m.load(data)
+}
+
+// renew returns the secret data with the CA and the serving certificate renewed if they are
+// missing or expire soon, and whether anything was renewed.
+//
+// The webhook configurations embed the CA bundle of the secret once krb-controller sees it
+// change, so a CA expiring soon is rotated in two steps: the next CA is added to the bundle
+// first, and signs the serving certificate once every webhook configuration trusts it. When
+// nothing trusted is served anyway, the CA is replaced at once.
+func (m *CertManager) renew(ctx context.Context, data map[string][]byte) (map[string][]byte, bool, error) {
+	data = maps.Clone(data)
+	if data == nil {
+		data = map[string][]byte{}
+	}
+	caCert, caKey, err := parseCertAndKey(data[CACertKey], data[CAKeyKey])
+	if err != nil {
+		caCert, caKey = nil, nil
+	}
+	servingCert, _, err := parseCertAndKey(data[corev1.TLSCertKey], data[corev1.TLSPrivateKeyKey])
+	servingTrusted := err == nil && m.trusts(caBundle(data), servingCert)
+
+	renewed, renewCA := false, false
+	nextCert, nextKey, err := parseCertAndKey(data[NextCACertKey], data[NextCAKeyKey])
+	switch {
+	case err == nil:
+		published := !servingTrusted
+		if !published {
+			if published, err = m.caPublished(ctx, nextCert); err != nil {
+				return nil, false, fmt.Errorf("failed to check the CA bundles of the webhook configurations: %w", err)
+			}
 		}
+		if !published {
+			m.logger.V(1).Info("waiting for the webhook configurations to trust the next webhook CA")
+			break
+		}
+		m.logger.Info("switching to the next webhook CA")
+		caCert, caKey, renewCA = nextCert, nextKey, true
+		delete(data, NextCACertKey)
+		delete(data, NextCAKeyKey)
+	case caCert != nil && !m.expiresSoon(caCert, caValidity):
+	case servingTrusted:
+		m.logger.Info("generating next webhook CA")
+		if nextCert, nextKey, err = m.newCA(); err != nil {
+			return nil, false, err
+		}
+		nextKeyPEM, err := keyutil.MarshalPrivateKeyToPEM(nextKey)
+		if err != nil {
+			return nil, false, err
+		}
+		data[NextCACertKey], data[NextCAKeyKey] = encodeCertPEM(nextCert.Raw), nextKeyPEM
+		renewed = true
+	default:
+		m.logger.Info("generating webhook CA")
+		if caCert, caKey, err = m.newCA(); err != nil {
+			return nil, false, err
+		}
+		renewCA = true
+	}
+
+	if renewCA {
+		caKeyPEM, err := keyutil.MarshalPrivateKeyToPEM(caKey)
+		if err != nil {
+			return nil, false, err
+		}
+		data[CACertKey], data[CAKeyKey] = encodeCertPEM(caCert.Raw), caKeyPEM
+		renewed = true
+	}
+
+	// secrets written before the CA was introduced have no CA to sign with until the next one
+	// is trusted.
+	renewServing := caCert != nil && (renewCA || servingCert == nil || m.expiresSoon(servingCert, servingValidity) || servingCert.CheckSignatureFrom(caCert) != nil)
+	if !renewServing {
+		return data, renewed, nil
+	}
+
+	m.logger.Info("generating webhook serving certificate")
+	certPEM, keyPEM, err := m.newServingCert(caCert, caKey)
+	if err != nil {
+		return nil, false, err
+	}
+	data[corev1.TLSCertKey], data[corev1.TLSPrivateKeyKey] = certPEM, keyPEM
+	return data, true, nil
+}
+
+// trusts returns whether the serving certificate is currently trusted by the CA bundle.
+func (m *CertManager) trusts(caBundle []byte, servingCert *x509.Certificate) bool {
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caBundle) {
+		return false
+	}
+	_, err := servingCert.Verify(x509.VerifyOptions{DNSName: m.dnsName, Roots: roots, CurrentTime: m.now()})
+	return err == nil
+}
+
+// webhookConfigurationTrusts returns whether every webhook of the webhook configuration of krb
+// trusts the CA. There is nothing to trust it while no RecyclePolicy exists.
+func (m *CertManager) webhookConfigurationTrusts(ctx context.Context, ca *x509.Certificate) (bool, error) {
+	configuration, err := m.client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, consts.WebhookName, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, webhook := range configuration.Webhooks {
+		certs, err := certutil.ParseCertsPEM(webhook.ClientConfig.CABundle)
+		if err != nil || !slices.ContainsFunc(certs, ca.Equal) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// load makes the serving certificate of the secret data the one served by GetCertificate.
+func (m *CertManager) load(data map[string][]byte) error {
+	certificate, err := tls.X509KeyPair(data[corev1.TLSCertKey], data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return fmt.Errorf("failed to load serving certificate: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse serving certificate: %w", err)
+	}
+	certificate.Leaf = leaf
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.certificate == nil || !bytes.Equal(m.certificate.Certificate[0], certificate.Certificate[0]) {
+		m.logger.Info("webhook serving certificate loaded", "notAfter", leaf.NotAfter.Format(time.RFC3339))
+	}
+	m.certificate = &certificate
+	return nil
+}
+
+func (m *CertManager) expiresSoon(cert *x509.Certificate, validity time.Duration) bool {
+	return m.now().After(cert.NotAfter.Add(-validity / renewBeforeFraction))
+}
+
+func (m *CertManager) newCA() (*x509.Certificate, crypto.Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := m.now()
+	template := &x509.Certificate{
+		SerialNumber:          newSerialNumber(),
+		Subject:               pkix.Name{CommonName: "krb-webhook-ca"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	return cert, key, err
+}
+
+func (m *CertManager) newServingCert(caCert *x509.Certificate, caKey crypto.Signer) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := m.now()
+	template := &x509.Certificate{
+		SerialNumber: newSerialNumber(),
+		Subject:      pkix.Name{CommonName: m.dnsName},
+		DNSNames:     []string{m.dnsName},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(servingValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, key.Public(), caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := keyutil.MarshalPrivateKeyToPEM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodeCertPEM(der), keyPEM, nil
+}
+
+// parseCertAndKey parses a PEM certificate and its PEM private key.
+func parseCertAndKey(certPEM, keyPEM []byte) (*x509.Certificate, crypto.Signer, error) {
+	if len(certPEM) == 0 || len(keyPEM) == 0 {
+		return nil, nil, errors.New("certificate or key is missing")
+	}
+	certs, err := certutil.ParseCertsPEM(certPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := keyutil.ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, errors.New("private key cannot sign")
+	}
+	return certs[0], signer, nil
+}
+
+func encodeCertPEM(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: certutil.CertificateBlockType, Bytes: der})
+}
+
+func newSerialNumber() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		// crypto/rand does not fail on supported platforms.
+		panic(err)
 	}
-	return cert, key
+	return serial
 }
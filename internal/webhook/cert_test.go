@@ -0,0 +1,212 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/wcrum/kube-recycle-bin/internal/consts"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	certutil "k8s.io/client-go/util/cert"
+)
+
+func getTLSSecret(t *testing.T, m *CertManager) *corev1.Secret {
+	t.Helper()
+	secret, err := m.client.CoreV1().Secrets(consts.WebhookNamespace).Get(context.Background(), consts.WebhookTLSCertSecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get TLS secret: %v", err)
+	}
+	return secret
+}
+
+// verifyServingCert checks that the served certificate is trusted by the CA bundle of the secret.
+func verifyServingCert(t *testing.T, m *CertManager) *x509.Certificate {
+	t.Helper()
+	certificate, err := m.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("failed to get serving certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(CABundleFromSecret(getTLSSecret(t, m))) {
+		t.Fatal("CA bundle holds no certificate")
+	}
+	if _, err := certificate.Leaf.Verify(x509.VerifyOptions{
+		DNSName:     consts.WebhookDNSName,
+		Roots:       roots,
+		CurrentTime: m.now(),
+	}); err != nil {
+		t.Fatalf("serving certificate is not trusted by the CA bundle: %v", err)
+	}
+	return certificate.Leaf
+}
+
+func TestCertManagerRenewsServingCert(t *testing.T) {
+	now := time.Now()
+	m := NewCertManager(fake.NewClientset())
+	m.now = func() time.Time { return now }
+
+	if err := m.EnsureCertificates(context.Background()); err != nil {
+		t.Fatalf("failed to ensure certificates: %v", err)
+	}
+	initial := verifyServingCert(t, m)
+	initialCA := getTLSSecret(t, m).Data[CACertKey]
+
+	// nothing is renewed while the serving certificate is fresh.
+	if err := m.EnsureCertificates(context.Background()); err != nil {
+		t.Fatalf("failed to ensure certificates: %v", err)
+	}
+	if !verifyServingCert(t, m).Equal(initial) {
+		t.Error("fresh serving certificate was renewed")
+	}
+
+	// the serving certificate is renewed before it expires, the CA is kept.
+	now = initial.NotAfter.Add(-time.Hour)
+	if err := m.EnsureCertificates(context.Background()); err != nil {
+		t.Fatalf("failed to ensure certificates: %v", err)
+	}
+	renewed := verifyServingCert(t, m)
+	if renewed.Equal(initial) {
+		t.Error("expiring serving certificate was not renewed")
+	}
+	if !bytes.Equal(getTLSSecret(t, m).Data[CACertKey], initialCA) {
+		t.Error("CA was renewed with the serving certificate")
+	}
+}
+
+func TestCertManagerMigratesSelfSignedSecret(t *testing.T) {
+	m := NewCertManager(fake.NewClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      consts.WebhookTLSCertSecretName,
+			Namespace: consts.WebhookNamespace,
+		},
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       []byte("self-signed cert"),
+			corev1.TLSPrivateKeyKey: []byte("self-signed key"),
+		},
+		Type: corev1.SecretTypeTLS,
+	}))
+
+	if err := m.EnsureCertificates(context.Background()); err != nil {
+		t.Fatalf("failed to ensure certificates: %v", err)
+	}
+	if len(getTLSSecret(t, m).Data[CACertKey]) == 0 {
+		t.Fatal("CA was not added to the secret")
+	}
+	verifyServingCert(t, m)
+}
+
+func TestCertManagerForCertManagerLoadsIssuedSecret(t *testing.T) {
+	issuer := NewCertManager(fake.NewClientset())
+	if err := issuer.EnsureCertificates(context.Background()); err != nil {
+		t.Fatalf("failed to issue certificates: %v", err)
+	}
+	issued := getTLSSecret(t, issuer)
+
+	client := fake.NewClientset()
+	m := NewCertManagerForCertManager(client)
+	if err := m.EnsureCertificates(context.Background()); err == nil {
+		t.Fatal("expected an error while the secret is not issued")
+	}
+
+	if _, err := client.CoreV1().Secrets(consts.WebhookNamespace).Create(context.Background(), issued, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("failed to create secret: %v", err)
+	}
+	if err := m.EnsureCertificates(context.Background()); err != nil {
+		t.Fatalf("failed to load issued secret: %v", err)
+	}
+	served := verifyServingCert(t, m)
+	if !served.Equal(verifyServingCert(t, issuer)) {
+		t.Error("served certificate is not the issued one")
+	}
+	if secret := getTLSSecret(t, m); !bytes.Equal(secret.Data[corev1.TLSCertKey], issued.Data[corev1.TLSCertKey]) {
+		t.Error("secret issued by cert-manager was modified")
+	}
+}
+
+func TestCertManagerRotatesCA(t *testing.T) {
+	now := time.Now()
+	client := fake.NewClientset()
+	m := NewCertManager(client)
+	m.now = func() time.Time { return now }
+	if err := m.EnsureCertificates(context.Background()); err != nil {
+		t.Fatalf("failed to ensure certificates: %v", err)
+	}
+	ca, err := certutil.ParseCertsPEM(getTLSSecret(t, m).Data[CACertKey])
+	if err != nil {
+		t.Fatalf("failed to parse CA: %v", err)
+	}
+	configuration := &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: consts.WebhookName},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+			Name:         "recycle-deployments." + consts.WebhookDNSName,
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{CABundle: CABundleFromSecret(getTLSSecret(t, m))},
+		}},
+	}
+	if _, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Create(context.Background(), configuration, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("failed to create webhook configuration: %v", err)
+	}
+	// publish tells krb-controller saw the secret change, and embedded its CA bundle.
+	publish := func() {
+		configuration.Webhooks[0].ClientConfig.CABundle = CABundleFromSecret(getTLSSecret(t, m))
+		if _, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Update(context.Background(), configuration, metav1.UpdateOptions{}); err != nil {
+			t.Fatalf("failed to update webhook configuration: %v", err)
+		}
+	}
+
+	// the serving certificate is renewed by the current CA until the CA expires soon.
+	now = ca[0].NotAfter.Add(-caValidity/renewBeforeFraction - time.Hour)
+	if err := m.EnsureCertificates(context.Background()); err != nil {
+		t.Fatalf("failed to ensure certificates: %v", err)
+	}
+	publish()
+	served := verifyServingCert(t, m)
+
+	// the next CA is added to the bundle, the serving certificate is kept until it is trusted.
+	now = now.Add(2 * time.Hour)
+	for range 2 {
+		if err := m.EnsureCertificates(context.Background()); err != nil {
+			t.Fatalf("failed to ensure certificates: %v", err)
+		}
+		secret := getTLSSecret(t, m)
+		if len(secret.Data[NextCACertKey]) == 0 {
+			t.Fatal("next CA was not added to the secret")
+		}
+		if !verifyServingCert(t, m).Equal(served) {
+			t.Fatal("serving certificate switched before the webhook configurations trust the next CA")
+		}
+	}
+	nextCA := getTLSSecret(t, m).Data[NextCACertKey]
+
+	publish()
+	if err := m.EnsureCertificates(context.Background()); err != nil {
+		t.Fatalf("failed to ensure certificates: %v", err)
+	}
+	secret := getTLSSecret(t, m)
+	if !bytes.Equal(secret.Data[CACertKey], nextCA) || len(secret.Data[NextCACertKey]) != 0 {
+		t.Fatal("next CA did not become the CA once trusted")
+	}
+	if verifyServingCert(t, m).Equal(served) {
+		t.Error("serving certificate was not signed by the next CA")
+	}
+}
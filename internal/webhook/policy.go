@@ -0,0 +1,201 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+	krbclient "github.com/wcrum/kube-recycle-bin/internal/client"
+	"github.com/wcrum/kube-recycle-bin/internal/consts"
+	"github.com/wcrum/kube-recycle-bin/pkg/logging"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// recyclePolicyNameFromPath returns the name of the RecyclePolicy the webhook was called for,
+// webhooks built by krb-controller are served at /validate/<policy-name>.
+func recyclePolicyNameFromPath(path string) string {
+	return strings.Trim(strings.TrimPrefix(path, consts.WebhookServicePath), "/")
+}
+
+// StartPolicyCache starts an informer-backed cache of the RecyclePolicies and of the metadata
+// of the namespaces, which the webhook matches deletes against without calling the api server,
+// and returns it once it is synced.
+func StartPolicyCache(ctx context.Context, config *rest.Config) (client.Reader, error) {
+	policyCache, err := cache.New(config, cache.Options{
+		Scheme: krbclient.Scheme(),
+		// reads of other objects fail instead of starting informers on the admission path.
+		ReaderFailOnMissingInformer: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create policy cache: %w", err)
+	}
+	for _, obj := range []client.Object{&api.RecyclePolicy{}, namespaceMetadata()} {
+		if _, err := policyCache.GetInformer(ctx, obj); err != nil {
+			return nil, fmt.Errorf("failed to watch %T: %w", obj, err)
+		}
+	}
+	go policyCache.Start(ctx)
+	if !policyCache.WaitForCacheSync(ctx) {
+		return nil, errors.New("failed to sync policy cache")
+	}
+	return policyCache, nil
+}
+
+// namespaceMetadata returns an empty namespace metadata, as the policy cache serves it.
+func namespaceMetadata() *metav1.PartialObjectMetadata {
+	namespace := &metav1.PartialObjectMetadata{}
+	namespace.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+	return namespace
+}
+
+// recycleResolution tells how the webhook of a RecyclePolicy handles the delete of an object.
+type recycleResolution struct {
+	// policy is the RecyclePolicy recycling the object, nil if unknown.
+	policy *api.RecyclePolicy
+	// owned tells whether the webhook must recycle the object.
+	owned bool
+	// denying is the oldest RecyclePolicy matching the delete that refuses it when the object cannot be
+	// recycled, nil if none does.
+	denying *api.RecyclePolicy
+}
+
+// resolveRecyclePolicy returns how the webhook of the named policy handles the object deleted
+// by the request.
+//
+// The api server calls the webhook of every policy matching a delete, so when policies
+// overlap only the oldest of them recycles the object and the others let it go. The delete
+// is refused when the object cannot be recycled if any of the matching policies says so.
+// An error is returned when the policies matching the delete cannot be told, with the
+// resolution of the named policy alone.
+//
+// The api server ignores the webhook of a policy letting deletes through on recycle failure
+// when it fails or times out, and admits the delete on the answers of the others. So when
+// the oldest policy lets deletes through, the policies refusing them recycle the object too,
+// on its behalf: RecycleItems are named after the admission request, the object is recycled
+// once whichever of these webhooks gets through.
+func (wh *Webhook) resolveRecyclePolicy(ctx context.Context, policyName string, request *admissionv1.AdmissionRequest) (*recycleResolution, error) {
+	if policyName == "" {
+		return &recycleResolution{owned: true}, nil
+	}
+	logger := logging.FromContext(ctx).WithValues(logging.KeyPolicy, policyName)
+
+	list := &api.RecyclePolicyList{}
+	if err := wh.cache.List(ctx, list); err != nil {
+		return &recycleResolution{owned: true}, fmt.Errorf("failed to list RecyclePolicies: %w", err)
+	}
+	recyclePolicies := list.Items
+
+	var policy *api.RecyclePolicy
+	for i := range recyclePolicies {
+		if recyclePolicies[i].Name == policyName {
+			policy = &recyclePolicies[i]
+		}
+	}
+	if policy == nil {
+		logger.Info("RecyclePolicy not found, recycling without retention")
+		return &recycleResolution{owned: true}, nil
+	}
+	resolution := &recycleResolution{policy: policy, owned: true}
+	if policy.DeniesOnRecycleFailure() {
+		resolution.denying = policy
+	}
+
+	matching, err := wh.matchingRecyclePolicies(ctx, recyclePolicies, request)
+	if err != nil {
+		return resolution, err
+	}
+	if len(matching) == 0 {
+		// the policies changed since the api server matched the webhook, trust the api server.
+		return resolution, nil
+	}
+
+	// the oldest policies come first, by name when created at the same time.
+	slices.SortFunc(matching, func(a, b *api.RecyclePolicy) int {
+		if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.CreationTimestamp.Compare(b.CreationTimestamp.Time)
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	owner := matching[0]
+	resolution.policy, resolution.owned = owner, owner.Name == policyName
+	if !owner.DeniesOnRecycleFailure() && policy.DeniesOnRecycleFailure() {
+		resolution.owned = true
+	}
+	if i := slices.IndexFunc(matching, (*api.RecyclePolicy).DeniesOnRecycleFailure); i >= 0 {
+		resolution.denying = matching[i]
+	}
+	return resolution, nil
+}
+
+// matchingRecyclePolicies returns the policies whose webhook matches the delete of the request.
+func (wh *Webhook) matchingRecyclePolicies(ctx context.Context, recyclePolicies []api.RecyclePolicy, request *admissionv1.AdmissionRequest) ([]*api.RecyclePolicy, error) {
+	logger := logging.FromContext(ctx)
+	gvr := requestGroupVersionResource(request)
+
+	var oldObject metav1.PartialObjectMetadata
+	if err := json.Unmarshal(request.OldObject.Raw, &oldObject); err != nil {
+		return nil, fmt.Errorf("failed to decode deleted object: %w", err)
+	}
+	objectLabels := labels.Set(oldObject.Labels)
+
+	// like the api server, match namespaces on their own labels and other cluster-scoped objects on none.
+	var namespaceLabels labels.Set
+	switch {
+	case gvr.GroupResource() == corev1.SchemeGroupVersion.WithResource("namespaces").GroupResource():
+		namespaceLabels = labels.Merge(objectLabels, labels.Set{corev1.LabelMetadataName: oldObject.Name})
+	case request.Namespace != "":
+		namespaceLabels = labels.Set{corev1.LabelMetadataName: request.Namespace}
+		// only read the namespace when its labels matter.
+		if slices.ContainsFunc(recyclePolicies, func(recyclePolicy api.RecyclePolicy) bool {
+			return recyclePolicy.Target.NamespaceSelector != nil
+		}) {
+			namespace := namespaceMetadata()
+			if err := wh.cache.Get(ctx, client.ObjectKey{Name: request.Namespace}, namespace); err != nil {
+				return nil, fmt.Errorf("failed to get labels of namespace %s: %w", request.Namespace, err)
+			}
+			namespaceLabels = labels.Merge(namespace.Labels, namespaceLabels)
+		}
+	}
+
+	var result []*api.RecyclePolicy
+	for i := range recyclePolicies {
+		if !recyclePolicies[i].DeletionTimestamp.IsZero() {
+			continue
+		}
+		matches, err := recyclePolicies[i].Matches(gvr, namespaceLabels, objectLabels)
+		if err != nil {
+			logger.Error(err, "failed to match RecyclePolicy", logging.KeyPolicy, recyclePolicies[i].Name)
+			continue
+		}
+		if matches {
+			result = append(result, &recyclePolicies[i])
+		}
+	}
+	return result, nil
+}
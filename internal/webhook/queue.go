@@ -0,0 +1,259 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+	krbclient "github.com/wcrum/kube-recycle-bin/internal/client"
+	"github.com/wcrum/kube-recycle-bin/internal/metrics"
+	"github.com/wcrum/kube-recycle-bin/pkg/logging"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// enqueueTimeout bounds the wait of the webhook for room in a full spool, well under the
+	// webhook timeouts set by krb-controller.
+	enqueueTimeout = 2 * time.Second
+	// createTimeout bounds each attempt to create a spooled RecycleItem.
+	createTimeout = 10 * time.Second
+	// validateTimeout bounds the dry run of the create of a RecycleItem of a delete refused on
+	// recycle failure, well under the webhook timeouts set by krb-controller.
+	validateTimeout = 5 * time.Second
+)
+
+// ErrSpoolFull is returned when the spool holds the maximum number of pending RecycleItems.
+var ErrSpoolFull = errors.New("recycle spool is full")
+
+// ItemRecorder records the outcome of the spooled RecycleItems.
+type ItemRecorder interface {
+	// Recycled is called for each RecycleItem once it is created.
+	Recycled(recycleItem *api.RecycleItem)
+	// RecycleRejected is called for each RecycleItem the api server rejects, with its error.
+	RecycleRejected(recycleItem *api.RecycleItem, err error)
+}
+
+// RecycleQueue creates the RecycleItems of the webhook asynchronously. Items are written to
+// the spool before the webhook acknowledges the delete, then created by a bounded number of
+// workers retrying with backoff until the api server accepts them, including after restarts.
+type RecycleQueue struct {
+	spool        *Spool
+	queue        workqueue.TypedRateLimitingInterface[string]
+	recycleItems krbclient.RecycleItemInterface
+	recorder     ItemRecorder
+	workers      int
+	// slots holds a token per pending RecycleItem, the webhook waits for room when it is full.
+	slots chan struct{}
+	// queued holds the keys of the queued RecycleItems, so repeated requests are queued once.
+	queuedMu sync.Mutex
+	queued   map[string]struct{}
+	logger   logr.Logger
+}
+
+// NewRecycleQueue returns a RecycleQueue persisting to the spool, creating RecycleItems with
+// the given number of workers and holding at most maxPending of them. The recorder records
+// the RecycleItems created or rejected.
+func NewRecycleQueue(spool *Spool, recycleItems krbclient.RecycleItemInterface, recorder ItemRecorder, workers, maxPending int) *RecycleQueue {
+	return &RecycleQueue{
+		spool: spool,
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.NewTypedItemExponentialFailureRateLimiter[string](100*time.Millisecond, time.Minute),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: "recycle"},
+		),
+		recycleItems: recycleItems,
+		recorder:     recorder,
+		workers:      max(workers, 1),
+		slots:        make(chan struct{}, max(maxPending, 1)),
+		queued:       map[string]struct{}{},
+		logger:       logging.Logger().WithName("queue"),
+	}
+}
+
+// Start starts the workers, queues the RecycleItems left in the spool by a previous run and
+// blocks until the context is done.
+func (q *RecycleQueue) Start(ctx context.Context) error {
+	defer q.queue.ShutDown()
+	for range q.workers {
+		go q.runWorker(ctx)
+	}
+
+	keys, err := q.spool.Keys()
+	if err != nil {
+		return fmt.Errorf("failed to read spool: %w", err)
+	}
+	if len(keys) > 0 {
+		q.logger.Info("replaying spooled RecycleItems", "count", len(keys))
+	}
+	for _, key := range keys {
+		// the workers free slots while the spool is replayed.
+		select {
+		case q.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil
+		}
+		if !q.track(key) {
+			<-q.slots
+			continue
+		}
+		q.queue.Add(key)
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+// Enqueue spools the RecycleItem and queues it for creation. It returns ErrSpoolFull if no
+// room is freed in time, and only returns nil once the RecycleItem is on disk. A RecycleItem
+// already queued, such as the one of a repeated admission request, is not queued again.
+func (q *RecycleQueue) Enqueue(ctx context.Context, recycleItem *api.RecycleItem) error {
+	if q.isQueued(spoolKey(recycleItem)) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, enqueueTimeout)
+	defer cancel()
+	select {
+	case q.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ErrSpoolFull
+	}
+
+	key, err := q.spool.Put(recycleItem)
+	if err != nil {
+		<-q.slots
+		return err
+	}
+	if !q.track(key) {
+		// queued concurrently by a repeated request.
+		<-q.slots
+		return nil
+	}
+	q.queue.Add(key)
+	return nil
+}
+
+// isQueued returns whether the RecycleItem of the key is queued.
+func (q *RecycleQueue) isQueued(key string) bool {
+	q.queuedMu.Lock()
+	defer q.queuedMu.Unlock()
+	_, ok := q.queued[key]
+	return ok
+}
+
+// track marks the RecycleItem of the key as queued, and returns false if it already was.
+func (q *RecycleQueue) track(key string) bool {
+	q.queuedMu.Lock()
+	defer q.queuedMu.Unlock()
+	if _, ok := q.queued[key]; ok {
+		return false
+	}
+	q.queued[key] = struct{}{}
+	metrics.SpoolDepth.Set(float64(len(q.queued)))
+	return true
+}
+
+// untrack marks the RecycleItem of the key as no longer queued.
+func (q *RecycleQueue) untrack(key string) {
+	q.queuedMu.Lock()
+	defer q.queuedMu.Unlock()
+	delete(q.queued, key)
+	metrics.SpoolDepth.Set(float64(len(q.queued)))
+}
+
+func (q *RecycleQueue) runWorker(ctx context.Context) {
+	for q.processNext(ctx) {
+	}
+}
+
+func (q *RecycleQueue) processNext(ctx context.Context) bool {
+	key, shutdown := q.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer q.queue.Done(key)
+
+	if q.create(ctx, key) {
+		q.queue.Forget(key)
+		q.release(key)
+	} else {
+		q.queue.AddRateLimited(key)
+	}
+	return true
+}
+
+// create creates the spooled RecycleItem of the key and returns whether it is done with it,
+// false if the creation must be retried.
+func (q *RecycleQueue) create(ctx context.Context, key string) bool {
+	logger := q.logger.WithValues("key", key)
+	recycleItem, err := q.spool.Get(key)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Error(err, "dropping unreadable spooled RecycleItem")
+		}
+		return true
+	}
+
+	obj := recycleItem.Object
+	metricLabels := []string{obj.Group, obj.Resource, obj.Namespace}
+	logger = logger.WithValues(logging.KeyItem, recycleItem.Name, logging.KeyObject, obj.Key())
+
+	createCtx, cancel := context.WithTimeout(logging.IntoContext(ctx, logger), createTimeout)
+	defer cancel()
+	err = q.recycleItems.Create(createCtx, recycleItem, client.CreateOptions{})
+	switch {
+	// the item is named after the object and the request, so it exists if an earlier attempt
+	// got through, including one of a previous run that crashed before it recorded it, or if
+	// the request was already recycled.
+	case err == nil, k8serrors.IsAlreadyExists(err):
+		metrics.RecycleSuccesses.WithLabelValues(metricLabels...).Inc()
+		q.recorder.Recycled(recycleItem)
+		logger.Info("recycled deleted object")
+		return true
+	case k8serrors.IsInvalid(err), k8serrors.IsBadRequest(err), k8serrors.IsRequestEntityTooLargeError(err):
+		// retrying cannot help, the item is kept out of the spool for inspection.
+		metrics.RecycleFailures.WithLabelValues(metricLabels...).Inc()
+		metrics.RecycleDeadLetters.WithLabelValues(metricLabels...).Inc()
+		q.recorder.RecycleRejected(recycleItem, err)
+		if deadLetterErr := q.spool.DeadLetter(key); deadLetterErr != nil {
+			logger.Error(deadLetterErr, "failed to dead-letter RecycleItem rejected by the api server, dropping it", "reason", err.Error())
+			return true
+		}
+		logger.Error(err, "dead-lettered RecycleItem rejected by the api server")
+		return true
+	default:
+		metrics.RecycleRetries.WithLabelValues(metricLabels...).Inc()
+		logger.Error(err, "failed to create RecycleItem, retrying", "attempts", q.queue.NumRequeues(key)+1)
+		return false
+	}
+}
+
+// release removes the RecycleItem of the key from the spool and frees its slot.
+func (q *RecycleQueue) release(key string) {
+	if err := q.spool.Remove(key); err != nil {
+		q.logger.Error(err, "failed to remove spooled RecycleItem", "key", key)
+	}
+	q.untrack(key)
+	<-q.slots
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+	"github.com/wcrum/kube-recycle-bin/internal/metrics"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// newSpooledItem returns a RecycleItem of a deleted configmap.
+func newSpooledItem(name string) *api.RecycleItem {
+	return api.NewRecycleItem(&api.RecycledObject{
+		Version:   "v1",
+		Resource:  "configmaps",
+		Kind:      "ConfigMap",
+		Namespace: "queue-test",
+		Name:      name,
+		UID:       types.UID(name + "-uid"),
+		Raw:       []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"` + name + `","namespace":"queue-test"}}`),
+	}, types.UID(name+"-request"))
+}
+
+func TestRecycleQueueRetriesUntilCreated(t *testing.T) {
+	failures := 2
+	clients := newFakeClients(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if failures > 0 {
+				failures--
+				return k8serrors.NewServiceUnavailable("api server is restarting")
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	})
+	queue, _ := startRecycleQueue(t, clients, t.TempDir(), 1)
+	retries := testutil.ToFloat64(metrics.RecycleRetries.WithLabelValues("", "configmaps", "queue-test"))
+
+	recycleItem := newSpooledItem("retried")
+	if err := queue.Enqueue(context.Background(), recycleItem); err != nil {
+		t.Fatalf("failed to enqueue RecycleItem: %v", err)
+	}
+	waitForDrain(t, queue)
+
+	if created := createdItems(t, clients); len(created) != 1 || created[0].Name != recycleItem.Name {
+		t.Fatalf("expected the RecycleItem to be created once, got %d", len(created))
+	}
+	if got := testutil.ToFloat64(metrics.RecycleRetries.WithLabelValues("", "configmaps", "queue-test")); got != retries+2 {
+		t.Errorf("expected %v retries, got %v", retries+2, got)
+	}
+	if keys, _ := queue.spool.Keys(); len(keys) != 0 {
+		t.Errorf("expected an empty spool, got %v", keys)
+	}
+}
+
+func TestRecycleQueueDeadLettersRejectedItems(t *testing.T) {
+	clients := newFakeClients(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			return k8serrors.NewInvalid(schema.GroupKind{Group: api.GroupVersion.Group, Kind: "RecycleItem"}, obj.GetName(), nil)
+		},
+	})
+	dir := t.TempDir()
+	queue, recorded := startRecycleQueue(t, clients, dir, 1)
+	failures := testutil.ToFloat64(metrics.RecycleFailures.WithLabelValues("", "configmaps", "queue-test"))
+	deadLetters := testutil.ToFloat64(metrics.RecycleDeadLetters.WithLabelValues("", "configmaps", "queue-test"))
+
+	recycleItem := newSpooledItem("rejected")
+	if err := queue.Enqueue(context.Background(), recycleItem); err != nil {
+		t.Fatalf("failed to enqueue RecycleItem: %v", err)
+	}
+	waitForDrain(t, queue)
+
+	if created := createdItems(t, clients); len(created) != 0 {
+		t.Errorf("expected no RecycleItem, got %d", len(created))
+	}
+	if got := testutil.ToFloat64(metrics.RecycleFailures.WithLabelValues("", "configmaps", "queue-test")); got != failures+1 {
+		t.Errorf("expected %v recycle failures, got %v", failures+1, got)
+	}
+	if got := testutil.ToFloat64(metrics.RecycleDeadLetters.WithLabelValues("", "configmaps", "queue-test")); got != deadLetters+1 {
+		t.Errorf("expected %v dead letters, got %v", deadLetters+1, got)
+	}
+	if names := recorded.rejectedNames(); len(names) != 1 || names[0] != recycleItem.Name {
+		t.Errorf("expected RecycleItem %q to be recorded as rejected, got %v", recycleItem.Name, names)
+	}
+
+	// the rejected item is kept out of the spool, and not replayed by the next run.
+	deadLettered, err := (&Spool{dir: filepath.Join(dir, deadLetterDir)}).Get(spoolKey(recycleItem))
+	if err != nil {
+		t.Fatalf("expected the RecycleItem in the dead-letter spool: %v", err)
+	}
+	if deadLettered.Name != recycleItem.Name {
+		t.Errorf("unexpected dead-lettered RecycleItem %q", deadLettered.Name)
+	}
+	queue, recorded = startRecycleQueue(t, clients, dir, 1)
+	waitForDrain(t, queue)
+	if names := recorded.rejectedNames(); len(names) != 0 {
+		t.Errorf("expected the dead-lettered RecycleItem not to be replayed, got %v", names)
+	}
+}
+
+func TestRecycleQueueReplaysSpoolOnStart(t *testing.T) {
+	clients := newFakeClients(interceptor.Funcs{})
+	dir := t.TempDir()
+
+	// a previous run spooled items it did not get to create.
+	spool, err := NewSpool(dir)
+	if err != nil {
+		t.Fatalf("failed to open spool: %v", err)
+	}
+	var names []string
+	for _, name := range []string{"first", "second", "third"} {
+		recycleItem := newSpooledItem(name)
+		if _, err := spool.Put(recycleItem); err != nil {
+			t.Fatalf("failed to spool RecycleItem: %v", err)
+		}
+		names = append(names, recycleItem.Name)
+	}
+
+	// replay waits for room when the spool holds more items than allowed.
+	queue, recorded := startRecycleQueue(t, clients, dir, 2)
+	waitForDrain(t, queue)
+
+	created := recorded.names()
+	if len(created) != len(names) {
+		t.Fatalf("expected %d RecycleItems, got %d", len(names), len(created))
+	}
+	for i, name := range created {
+		if name != names[i] {
+			t.Errorf("expected RecycleItem %q to be created in spool order, got %q", names[i], name)
+		}
+	}
+}
+
+func TestRecycleQueueRecordsItemsCreatedByPreviousRun(t *testing.T) {
+	clients := newFakeClients(interceptor.Funcs{})
+	dir := t.TempDir()
+
+	// a previous run created the item but stopped before it removed it from the spool.
+	spool, err := NewSpool(dir)
+	if err != nil {
+		t.Fatalf("failed to open spool: %v", err)
+	}
+	recycleItem := newSpooledItem("created")
+	if _, err := spool.Put(recycleItem); err != nil {
+		t.Fatalf("failed to spool RecycleItem: %v", err)
+	}
+	if err := clients.RecycleItem().Create(context.Background(), recycleItem.DeepCopy(), client.CreateOptions{}); err != nil {
+		t.Fatalf("failed to create RecycleItem: %v", err)
+	}
+	successes := testutil.ToFloat64(metrics.RecycleSuccesses.WithLabelValues("", "configmaps", "queue-test"))
+
+	queue, recorded := startRecycleQueue(t, clients, dir, 1)
+	waitForDrain(t, queue)
+
+	if names := recorded.names(); len(names) != 1 || names[0] != recycleItem.Name {
+		t.Errorf("expected a Recycled event of RecycleItem %q, got %v", recycleItem.Name, names)
+	}
+	if got := testutil.ToFloat64(metrics.RecycleSuccesses.WithLabelValues("", "configmaps", "queue-test")); got != successes+1 {
+		t.Errorf("expected %v recycle successes, got %v", successes+1, got)
+	}
+}
+
+func TestRecycleQueueAppliesBackPressure(t *testing.T) {
+	blocked := make(chan struct{})
+	clients := newFakeClients(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			<-blocked
+			return c.Create(ctx, obj, opts...)
+		},
+	})
+	queue, _ := startRecycleQueue(t, clients, t.TempDir(), 1)
+	t.Cleanup(func() { close(blocked) })
+
+	if err := queue.Enqueue(context.Background(), newSpooledItem("pending")); err != nil {
+		t.Fatalf("failed to enqueue RecycleItem: %v", err)
+	}
+	start := time.Now()
+	if err := queue.Enqueue(context.Background(), newSpooledItem("refused")); !errors.Is(err, ErrSpoolFull) {
+		t.Fatalf("expected %v, got %v", ErrSpoolFull, err)
+	}
+	if waited := time.Since(start); waited < enqueueTimeout {
+		t.Errorf("expected the enqueue to wait for room, gave up after %v", waited)
+	}
+}
+
+func TestRecycleQueueQueuesRepeatedItemsOnce(t *testing.T) {
+	blocked := make(chan struct{})
+	clients := newFakeClients(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			<-blocked
+			return c.Create(ctx, obj, opts...)
+		},
+	})
+	queue, _ := startRecycleQueue(t, clients, t.TempDir(), 1)
+
+	// the RecycleItem of a repeated request takes no room in a full spool.
+	for range 3 {
+		if err := queue.Enqueue(context.Background(), newSpooledItem("repeated")); err != nil {
+			t.Fatalf("failed to enqueue RecycleItem: %v", err)
+		}
+	}
+	if keys, _ := queue.spool.Keys(); len(keys) != 1 {
+		t.Errorf("expected 1 spooled RecycleItem, got %v", keys)
+	}
+	close(blocked)
+	waitForDrain(t, queue)
+
+	if created := createdItems(t, clients); len(created) != 1 {
+		t.Errorf("expected 1 RecycleItem, got %d", len(created))
+	}
+}
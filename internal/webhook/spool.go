@@ -0,0 +1,172 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+)
+
+const (
+	spoolFileSuffix = ".json"
+	spoolTempPrefix = ".tmp-"
+	// deadLetterDir is the subdirectory of the spool holding the RecycleItems rejected by the
+	// api server, out of the keys of the spool.
+	deadLetterDir = "dead-letter"
+)
+
+// Spool persists the RecycleItems waiting to be created, one file per item in a local
+// directory, so recycles acknowledged by the webhook survive its restarts.
+type Spool struct {
+	dir string
+}
+
+// NewSpool returns a Spool in the directory, created if missing, and removes the partial
+// writes left in it by a crash.
+func NewSpool(dir string) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	tmps, err := filepath.Glob(filepath.Join(dir, spoolTempPrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, tmp := range tmps {
+		os.Remove(tmp)
+	}
+	return &Spool{dir: dir}, nil
+}
+
+// spoolKey returns the key of the RecycleItem in the spool, the same for the RecycleItems of
+// a repeated admission request.
+func spoolKey(recycleItem *api.RecycleItem) string {
+	return recycleItem.Name + spoolFileSuffix
+}
+
+// Put writes the RecycleItem to the spool and returns its key once it is on disk, replacing
+// the RecycleItem of the same key.
+func (s *Spool) Put(recycleItem *api.RecycleItem) (string, error) {
+	data, err := json.Marshal(recycleItem)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode RecycleItem: %w", err)
+	}
+
+	key := spoolKey(recycleItem)
+	tmp, err := os.CreateTemp(s.dir, spoolTempPrefix)
+	if err != nil {
+		return "", fmt.Errorf("failed to spool RecycleItem: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to spool RecycleItem: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to spool RecycleItem: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to spool RecycleItem: %w", err)
+	}
+	// the rename makes the item visible complete or not at all.
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, key)); err != nil {
+		return "", fmt.Errorf("failed to spool RecycleItem: %w", err)
+	}
+	return key, s.syncDir()
+}
+
+// Get reads the RecycleItem of the key.
+func (s *Spool) Get(key string) (*api.RecycleItem, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, key))
+	if err != nil {
+		return nil, err
+	}
+	recycleItem := &api.RecycleItem{}
+	if err := json.Unmarshal(data, recycleItem); err != nil {
+		return nil, fmt.Errorf("failed to decode spooled RecycleItem [%s]: %w", key, err)
+	}
+	return recycleItem, nil
+}
+
+// Remove deletes the RecycleItem of the key from the spool.
+func (s *Spool) Remove(key string) error {
+	if err := os.Remove(filepath.Join(s.dir, key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// DeadLetter moves the RecycleItem of the key to the dead-letter directory of the spool, where
+// it is kept for inspection and no longer replayed.
+func (s *Spool) DeadLetter(key string) error {
+	dir := filepath.Join(s.dir, deadLetterDir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+	if err := os.Rename(filepath.Join(s.dir, key), filepath.Join(dir, key)); err != nil {
+		return fmt.Errorf("failed to dead-letter spooled RecycleItem: %w", err)
+	}
+	return s.syncDir()
+}
+
+// Keys returns the keys of the spooled RecycleItems, oldest first.
+func (s *Spool) Keys() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	modTimes := map[string]time.Time{}
+	for _, entry := range entries {
+		// partial writes are not picked up, Put is running concurrently.
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, spoolTempPrefix) || !strings.HasSuffix(name, spoolFileSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// removed since it was listed.
+			continue
+		}
+		keys = append(keys, name)
+		modTimes[name] = info.ModTime()
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		if c := modTimes[a].Compare(modTimes[b]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	return keys, nil
+}
+
+// syncDir flushes the directory entry of renamed files to disk.
+func (s *Spool) syncDir() error {
+	dir, err := os.Open(s.dir)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
@@ -18,61 +18,155 @@ package webhook
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/wcrum/kube-recycle-bin/internal/api"
 	krbclient "github.com/wcrum/kube-recycle-bin/internal/client"
 	"github.com/wcrum/kube-recycle-bin/internal/consts"
+	"github.com/wcrum/kube-recycle-bin/internal/event"
+	"github.com/wcrum/kube-recycle-bin/internal/metrics"
 	"github.com/wcrum/kube-recycle-bin/pkg/kube"
-	"github.com/wcrum/kube-recycle-bin/pkg/tlog"
+	"github.com/wcrum/kube-recycle-bin/pkg/logging"
 	"github.com/wcrum/kube-recycle-bin/pkg/util"
 	admissionv1 "k8s.io/api/admission/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
-func init() {
-	log.SetLogger(logr.New(log.NullLogSink{}))
+// Webhook recycles the objects deleted through the admission requests it is sent.
+type Webhook struct {
+	clients *krbclient.Clients
+	// cache serves the RecyclePolicies and the namespaces deletes are matched against, see
+	// StartPolicyCache.
+	cache client.Reader
+	queue *RecycleQueue
+}
+
+// NewWebhook returns a Webhook reading RecyclePolicies and namespaces from the cache and
+// creating RecycleItems through the queue.
+func NewWebhook(clients *krbclient.Clients, cache client.Reader, queue *RecycleQueue) *Webhook {
+	return &Webhook{
+		clients: clients,
+		cache:   cache,
+		queue:   queue,
+	}
+}
+
+// Handler returns the handler of the admission requests of the webhook.
+func (wh *Webhook) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(consts.WebhookServicePath, wh.recycleDeleteObjects)
+	mux.HandleFunc(consts.WebhookServicePath+"/", wh.recycleDeleteObjects)
+	return mux
 }
 
 // Run starts the webhook server.
 func Run() {
-	tlog.Info("» starting admission webhook server...")
+	var certManagerMode bool
+	var metricsAddr, spoolDir string
+	var workers, maxPending int
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	flag.BoolVar(&certManagerMode, "cert-manager", util.BoolEnv(consts.CertManagerEnv),
+		"If set, serve the TLS secret issued by cert-manager instead of issuing it. Defaults to $"+consts.CertManagerEnv+".")
+	flag.StringVar(&spoolDir, "spool-dir", "/var/lib/krb/spool", "The directory RecycleItems are spooled to until they are created.")
+	flag.IntVar(&workers, "workers", 4, "The number of workers creating spooled RecycleItems.")
+	flag.IntVar(&maxPending, "max-pending", 10000, "The maximum number of spooled RecycleItems, deletes wait for room beyond it.")
+	logOptions := logging.Options{Format: logging.FormatText}
+	logOptions.AddFlags(flag.CommandLine)
+	flag.Parse()
 
-	ensureTLSFiles()
-	http.HandleFunc(consts.WebhookServicePath, recycleDeleteObjects)
+	logger := logging.Setup(logOptions).WithName("webhook")
+	logger.Info("starting admission webhook server")
 
-	if err := http.ListenAndServeTLS(":443", consts.WebhookServiceTLSCertFile, consts.WebhookServiceTLSKeyFile, nil); err != nil {
-		tlog.Fatalf("✗ failed to listen and serve admission webhook: %v", err)
+	config, err := kube.RestConfig()
+	if err != nil {
+		logger.Error(err, "failed to load kubeconfig")
+		os.Exit(1)
+	}
+	clients, err := krbclient.NewClients(config)
+	if err != nil {
+		logger.Error(err, "failed to create clients")
+		os.Exit(1)
 	}
-}
 
-func ensureTLSFiles() {
-	cert, key := FetchWebhookCertAndKey()
+	ctx := logging.IntoContext(context.Background(), logger)
+	certManager := NewCertManager(clients.Kubernetes)
+	if certManagerMode {
+		logger.Info("serving TLS secret issued by cert-manager", "secret", consts.WebhookNamespace+"/"+consts.WebhookTLSCertSecretName)
+		certManager = NewCertManagerForCertManager(clients.Kubernetes)
+	}
+	if err := certManager.EnsureCertificates(ctx); err != nil {
+		logger.Error(err, "failed to ensure webhook certificates")
+		os.Exit(1)
+	}
+	go certManager.Start(ctx)
 
-	err := os.WriteFile(consts.WebhookServiceTLSCertFile, cert, 0644)
+	metrics.RegisterWebhook(prometheus.DefaultRegisterer)
+	go serveMetrics(logger, metricsAddr)
+
+	spool, err := NewSpool(spoolDir)
 	if err != nil {
-		tlog.Fatalf("✗ failed to write cert file: %v", err)
+		logger.Error(err, "failed to open recycle spool")
+		os.Exit(1)
 	}
-	// Security: Private keys should only be readable by owner (0600)
-	err = os.WriteFile(consts.WebhookServiceTLSKeyFile, key, 0600)
+	policyCache, err := StartPolicyCache(ctx, config)
 	if err != nil {
-		tlog.Fatalf("✗ failed to write key file: %v", err)
+		logger.Error(err, "failed to start policy cache")
+		os.Exit(1)
+	}
+
+	recorder := event.NewRecorder(clients.Kubernetes, consts.WebhookName)
+	queue := NewRecycleQueue(spool, clients.RecycleItem(), recorder, workers, maxPending)
+	go func() {
+		if err := queue.Start(ctx); err != nil {
+			logger.Error(err, "failed to start recycle queue")
+			os.Exit(1)
+		}
+	}()
+
+	server := &http.Server{
+		Addr:    ":443",
+		Handler: NewWebhook(clients, policyCache, queue).Handler(),
+		TLSConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			// renewed serving certificates are picked up without restarting the server.
+			GetCertificate: certManager.GetCertificate,
+		},
 	}
+	if err := server.ListenAndServeTLS("", ""); err != nil {
+		logger.Error(err, "failed to listen and serve admission webhook")
+		os.Exit(1)
+	}
+}
 
-	tlog.Infof("✓ cert and key files generated.")
+// serveMetrics serves the Prometheus metrics of the webhook over plain HTTP.
+func serveMetrics(logger logr.Logger, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		logger.Error(err, "failed to serve metrics")
+	}
 }
 
 // recycleDeleteObjects webhook handler for recycling deleted objects.
-func recycleDeleteObjects(w http.ResponseWriter, r *http.Request) {
-	tlog.Infof("» received request: %s", r.URL.Path)
+func (wh *Webhook) recycleDeleteObjects(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Logger().WithName("webhook")
+	logger.V(1).Info("received request", "path", r.URL.Path)
+	start := time.Now()
+	defer func() {
+		metrics.AdmissionDuration.Observe(time.Since(start).Seconds())
+	}()
 
 	// Security: Limit request body size to prevent DoS attacks
 	// Kubernetes admission reviews are typically small, but we set a reasonable limit
@@ -84,36 +178,126 @@ func recycleDeleteObjects(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("✗ failed to parse request: %v", err), http.StatusBadRequest)
 		return
 	}
+	if review.Request == nil {
+		http.Error(w, "✗ admission review holds no request", http.StatusBadRequest)
+		return
+	}
 
 	request := review.Request
+	logger = logger.WithValues(
+		logging.KeyRequestUID, request.UID,
+		logging.KeyGVR, requestGroupVersionResource(request).String(),
+		logging.KeyObject, objectKey(request.Namespace, request.Name),
+	)
+	ctx := logging.IntoContext(context.Background(), logger)
+
+	// Dry-run deletes must have no side effects, the webhooks are declared NoneOnDryRun.
+	if request.DryRun != nil && *request.DryRun {
+		logger.Info("dry-run delete, skipping recycle")
+		response(w, review)
+		return
+	}
 
-	// Create RecycleItem to recycle the deleted object.
-	recycledObj := buildRecycledObject(request)
-	if recycledObj != nil {
-		// Security: Validate resource size before processing to prevent storage exhaustion
-		const maxResourceSize = 5 * 1024 * 1024 // 5MB per resource
-		if len(recycledObj.Raw) > maxResourceSize {
-			tlog.Errorf("✗ resource [%s: %s] exceeds maximum size limit (%d bytes), skipping recycle", recycledObj.GroupResource().String(), recycledObj.Key(), maxResourceSize)
-			response(w, review)
+	resolution, err := wh.resolveRecyclePolicy(ctx, recyclePolicyNameFromPath(r.URL.Path), request)
+	switch {
+	case err != nil:
+		// the object may be recycled by another policy, recycling it here could duplicate it.
+		err = fmt.Errorf("failed to resolve RecyclePolicies matching the delete: %w", err)
+	case !resolution.owned:
+		logger.Info("object is recycled by another RecyclePolicy, skipping", logging.KeyPolicy, resolution.policy.Name)
+		response(w, review)
+		return
+	default:
+		// Create RecycleItem to recycle the deleted object.
+		err = wh.recycle(ctx, request, resolution.policy, resolution.denying != nil)
+	}
+	if err != nil {
+		if denying := resolution.denying; denying != nil {
+			logger.Error(err, "delete denied by RecyclePolicy", logging.KeyPolicy, denying.Name)
+			deny(w, review, fmt.Sprintf("krb: RecyclePolicy %s refuses the delete because the object cannot be recycled: %v", denying.Name, err))
 			return
 		}
+		logger.Error(err, "delete allowed")
+	}
+
+	response(w, review)
+}
+
+// recycle spools a RecycleItem holding the object deleted by the request. policy is the
+// RecyclePolicy recycling the object, nil if unknown. With validate, the RecycleItem is only
+// spooled once a dry run of its create is accepted by the api server, so deletes refused on
+// recycle failure are not let through with an item the api server will reject.
+func (wh *Webhook) recycle(ctx context.Context, request *admissionv1.AdmissionRequest, policy *api.RecyclePolicy, validate bool) error {
+	logger := logging.FromContext(ctx)
 
-		tlog.Infof("» prepare to recycle deleted object [%s: %s]", recycledObj.GroupResource().String(), recycledObj.Key())
-		recycleItem := api.NewRecycleItem(recycledObj)
-		if err := retry.OnError(retry.DefaultRetry, k8serrors.IsAlreadyExists, func() error {
-			if err := krbclient.RecycleItem().Create(context.Background(), recycleItem, client.CreateOptions{}); err != nil {
-				return err
-			}
+	metricLabels := []string{request.Resource.Group, request.Resource.Resource, request.Namespace}
+	metrics.RecycleAttempts.WithLabelValues(metricLabels...).Inc()
 
-			tlog.Infof("✓ recycle deleted object [%s: %s] done.", recycledObj.GroupResource().String(), recycledObj.Key())
+	recycledObj, err := wh.buildRecycledObject(request)
+	if err != nil {
+		metrics.RecycleFailures.WithLabelValues(metricLabels...).Inc()
+		return err
+	}
 
-			return nil
-		}); err != nil {
-			tlog.Errorf("✗ failed to recycle deleted object [%s: %s]: %v", recycledObj.GroupResource().String(), recycledObj.Key(), err)
+	// Security: Validate resource size before processing to prevent storage exhaustion.
+	// RecycleItems hold the object base64-encoded, which keeps the items of objects up to 1MiB
+	// under the 1.5MiB request limit of etcd.
+	const maxResourceSize = 1024 * 1024 // 1MiB per resource
+	if len(recycledObj.Raw) > maxResourceSize {
+		metrics.RecycleOversized.WithLabelValues(metricLabels...).Inc()
+		return fmt.Errorf("object [%s: %s] exceeds maximum size limit (%d bytes), skipping recycle", recycledObj.GroupResource().String(), recycledObj.Key(), maxResourceSize)
+	}
+
+	logger.V(1).Info("recycling deleted object", "deletedBy", request.UserInfo.Username)
+	recycleItem := api.NewRecycleItem(recycledObj, request.UID)
+	recycleItem.Deletion = api.NewRecycleDeletion(request.UID, request.UserInfo, request.Options.Raw)
+	if policy != nil {
+		recycleItem.SetRecyclePolicy(policy, time.Now())
+	}
+	if validate {
+		if err := wh.validate(ctx, recycleItem); err != nil {
+			metrics.RecycleFailures.WithLabelValues(metricLabels...).Inc()
+			return fmt.Errorf("failed to recycle deleted object [%s: %s]: %w", recycledObj.GroupResource().String(), recycledObj.Key(), err)
 		}
 	}
+	// the RecycleItem is created in the background once it is spooled, so the delete is
+	// only held up when the spool cannot take it.
+	if err := wh.queue.Enqueue(ctx, recycleItem); err != nil {
+		metrics.RecycleFailures.WithLabelValues(metricLabels...).Inc()
+		return fmt.Errorf("failed to recycle deleted object [%s: %s]: %w", recycledObj.GroupResource().String(), recycledObj.Key(), err)
+	}
 
-	response(w, review)
+	logger.Info("spooled deleted object", logging.KeyItem, recycleItem.Name)
+	return nil
+}
+
+// validate runs a dry run of the create of the RecycleItem on the api server. A RecycleItem
+// that already exists was created for an earlier attempt of the same request.
+func (wh *Webhook) validate(ctx context.Context, recycleItem *api.RecycleItem) error {
+	ctx, cancel := context.WithTimeout(ctx, validateTimeout)
+	defer cancel()
+	err := wh.clients.RecycleItem().Create(ctx, recycleItem.DeepCopy(), client.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		return fmt.Errorf("RecycleItem rejected by the api server: %w", err)
+	}
+	return nil
+}
+
+// requestGroupVersionResource returns the group/version/resource of the object of the request.
+func requestGroupVersionResource(request *admissionv1.AdmissionRequest) schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    request.Resource.Group,
+		Version:  request.Resource.Version,
+		Resource: request.Resource.Resource,
+	}
+}
+
+// objectKey returns the namespace/name key of an object, the name of cluster-scoped objects.
+func objectKey(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
 }
 
 // parseRequest parses the request of the admission webhook.
@@ -123,7 +307,7 @@ func parseRequest(r *http.Request) (*admissionv1.AdmissionReview, error) {
 	)
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		tlog.Errorf("✗ failed to decode request: %v", err)
+		logging.Logger().Error(err, "failed to decode request")
 		return nil, err
 	}
 
@@ -131,15 +315,14 @@ func parseRequest(r *http.Request) (*admissionv1.AdmissionReview, error) {
 }
 
 // buildRecycledObject constructs api.RecycledObject from the request
-func buildRecycledObject(request *admissionv1.AdmissionRequest) *api.RecycledObject {
-	namespaced, err := kube.IsResourceNamespaced(schema.GroupVersionResource{
-		Group:    request.Resource.Group,
-		Version:  request.Resource.Version,
-		Resource: request.Resource.Resource,
-	})
+func (wh *Webhook) buildRecycledObject(request *admissionv1.AdmissionRequest) (*api.RecycledObject, error) {
+	namespaced, err := wh.clients.Discovery.IsResourceNamespaced(requestGroupVersionResource(request))
 	if err != nil {
-		tlog.Errorf("✗ failed to check if resource is namespaced: %v", err)
-		return nil
+		return nil, fmt.Errorf("failed to check if resource is namespaced: %w", err)
+	}
+	var oldObject metav1.PartialObjectMetadata
+	if err := json.Unmarshal(request.OldObject.Raw, &oldObject); err != nil {
+		return nil, fmt.Errorf("failed to decode deleted object: %w", err)
 	}
 	return &api.RecycledObject{
 		Group:     request.Resource.Group,
@@ -148,8 +331,9 @@ func buildRecycledObject(request *admissionv1.AdmissionRequest) *api.RecycledObj
 		Kind:      request.Kind.Kind,
 		Namespace: util.If(namespaced, request.Namespace, ""),
 		Name:      request.Name,
+		UID:       oldObject.UID,
 		Raw:       request.OldObject.Raw,
-	}
+	}, nil
 }
 
 // response sends the response to the admission webhook.
@@ -166,10 +350,29 @@ func response(w http.ResponseWriter, request *admissionv1.AdmissionReview) {
 	encodeResponse(w, response)
 }
 
+// deny sends a response refusing the request of the admission webhook with the given message.
+func deny(w http.ResponseWriter, request *admissionv1.AdmissionReview, message string) {
+	response := &admissionv1.AdmissionReview{
+		TypeMeta: request.TypeMeta,
+		Response: &admissionv1.AdmissionResponse{
+			UID:     request.Request.UID,
+			Allowed: false,
+			Result: &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: message,
+				Reason:  metav1.StatusReasonForbidden,
+				Code:    http.StatusForbidden,
+			},
+		},
+	}
+
+	encodeResponse(w, response)
+}
+
 // encodeResponse encodes the response to the admission webhook.
 func encodeResponse(w http.ResponseWriter, response *admissionv1.AdmissionReview) {
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		tlog.Errorf("✗ failed to encode response: %v", err)
+		logging.Logger().Error(err, "failed to encode response")
 		http.Error(w, fmt.Sprintf("✗ failed to encode response: %v", err), http.StatusInternalServerError)
 	}
 }
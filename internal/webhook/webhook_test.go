@@ -0,0 +1,685 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+	krbclient "github.com/wcrum/kube-recycle-bin/internal/client"
+	"github.com/wcrum/kube-recycle-bin/internal/client/fake"
+	"github.com/wcrum/kube-recycle-bin/internal/consts"
+	"github.com/wcrum/kube-recycle-bin/internal/metrics"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// newDeploymentPolicy returns a RecyclePolicy recycling deployments, created at the given time.
+func newDeploymentPolicy(name string, createdAt time.Time) api.RecyclePolicy {
+	return api.RecyclePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(createdAt)},
+		Target: api.RecycleTarget{
+			Resources: []api.RecycleResourceRule{{Group: "apps", Resource: "deployments"}},
+		},
+	}
+}
+
+// newFakeClients returns fake clients serving the policies, recycling deployments by default,
+// and the dev namespace, whose creates of RecycleItems go through the interceptor funcs.
+func newFakeClients(funcs interceptor.Funcs, recyclePolicies ...api.RecyclePolicy) *krbclient.Clients {
+	if len(recyclePolicies) == 0 {
+		recyclePolicies = []api.RecyclePolicy{newDeploymentPolicy("recycle-deployments", time.Now())}
+	}
+
+	objects := []runtime.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "dev", Labels: map[string]string{"env": "dev"}}},
+	}
+	for i := range recyclePolicies {
+		objects = append(objects, &recyclePolicies[i])
+	}
+	return fake.NewClientsWithInterceptor(funcs, objects...)
+}
+
+// newTestCache returns a reader serving the RecyclePolicies and the namespaces of the fake
+// clients, as the policy cache of krb-webhook does.
+func newTestCache(t *testing.T, clients *krbclient.Clients) client.Reader {
+	t.Helper()
+
+	recyclePolicies, err := clients.RecyclePolicy().List(context.Background(), client.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list RecyclePolicies: %v", err)
+	}
+	namespaces, err := clients.Kubernetes.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list namespaces: %v", err)
+	}
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	api.AddToScheme(scheme)
+	builder := fakeclient.NewClientBuilder().WithScheme(scheme)
+	for i := range recyclePolicies.Items {
+		builder.WithObjects(&recyclePolicies.Items[i])
+	}
+	for i := range namespaces.Items {
+		builder.WithObjects(&namespaces.Items[i])
+	}
+	return builder.Build()
+}
+
+// createdItems returns the RecycleItems created with the clients.
+func createdItems(t *testing.T, clients *krbclient.Clients) []api.RecycleItem {
+	t.Helper()
+
+	list, err := clients.RecycleItem().List(context.Background(), client.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list RecycleItems: %v", err)
+	}
+	return list.Items
+}
+
+// recordedItems holds the RecycleItems a queue recorded Recycled Events for, in order, and
+// the ones it recorded as rejected.
+type recordedItems struct {
+	mu       sync.Mutex
+	items    []*api.RecycleItem
+	rejected []*api.RecycleItem
+}
+
+func (r *recordedItems) Recycled(recycleItem *api.RecycleItem) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.items = append(r.items, recycleItem)
+}
+
+func (r *recordedItems) RecycleRejected(recycleItem *api.RecycleItem, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rejected = append(r.rejected, recycleItem)
+}
+
+func (r *recordedItems) rejectedNames() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var names []string
+	for _, recycleItem := range r.rejected {
+		names = append(names, recycleItem.Name)
+	}
+	return names
+}
+
+func (r *recordedItems) names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var names []string
+	for _, recycleItem := range r.items {
+		names = append(names, recycleItem.Name)
+	}
+	return names
+}
+
+// startRecycleQueue starts a queue spooling to dir and creating RecycleItems with the clients
+// for the duration of the test, and returns it with the RecycleItems it records.
+func startRecycleQueue(t *testing.T, clients *krbclient.Clients, dir string, maxPending int) (*RecycleQueue, *recordedItems) {
+	t.Helper()
+
+	spool, err := NewSpool(dir)
+	if err != nil {
+		t.Fatalf("failed to open spool: %v", err)
+	}
+	recorded := &recordedItems{}
+	// a single worker keeps the interceptors of the tests free of data races.
+	queue := NewRecycleQueue(spool, clients.RecycleItem(), recorded, 1, maxPending)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go queue.Start(ctx)
+	return queue, recorded
+}
+
+// newTestWebhook returns a Webhook of the clients, with a queue started for the test, and the
+// RecycleItems its queue records.
+func newTestWebhook(t *testing.T, clients *krbclient.Clients) (*Webhook, *recordedItems) {
+	t.Helper()
+
+	queue, recorded := startRecycleQueue(t, clients, t.TempDir(), 1)
+	return NewWebhook(clients, newTestCache(t, clients), queue), recorded
+}
+
+// waitForDrain waits for the queue to create all of its spooled RecycleItems, each removed
+// from the spool once the queue is done with it.
+func waitForDrain(t *testing.T, queue *RecycleQueue) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		keys, err := queue.spool.Keys()
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatalf("failed to read spool: %v", err)
+		}
+		if len(keys) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("RecycleItems still spooled: %v", keys)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// review sends the AdmissionReview fixture to the webhook and returns its response once the
+// RecycleItems are created.
+func review(t *testing.T, wh *Webhook, fixture, policyName string) *admissionv1.AdmissionResponse {
+	t.Helper()
+
+	body, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	wh.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, consts.WebhookServicePath+"/"+policyName, bytes.NewReader(body)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	waitForDrain(t, wh.queue)
+
+	var result admissionv1.AdmissionReview
+	if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if result.Response == nil {
+		t.Fatal("response is missing")
+	}
+	return result.Response
+}
+
+func TestRecycleDeleteObjects(t *testing.T) {
+	clients := newFakeClients(interceptor.Funcs{})
+	wh, recorded := newTestWebhook(t, clients)
+	successes := testutil.ToFloat64(metrics.RecycleSuccesses.WithLabelValues("apps", "deployments", "dev"))
+
+	resp := review(t, wh, "delete-deployment.json", "recycle-deployments")
+	if !resp.Allowed {
+		t.Errorf("delete was not allowed: %v", resp.Result)
+	}
+	if resp.UID != "0df28fbd-5f5f-4a3d-9e1c-6a1a5f4c2b7e" {
+		t.Errorf("unexpected response UID %q", resp.UID)
+	}
+
+	created := createdItems(t, clients)
+	if len(created) != 1 {
+		t.Fatalf("expected 1 RecycleItem, got %d", len(created))
+	}
+	recycleItem := created[0]
+	if got := recycleItem.Object.Key(); got != "dev/nginx" {
+		t.Errorf("unexpected recycled object %q", got)
+	}
+	if got := recycleItem.RecyclePolicyName(); got != "recycle-deployments" {
+		t.Errorf("unexpected RecyclePolicy %q", got)
+	}
+	if recycleItem.Deletion == nil {
+		t.Fatal("deletion is not recorded")
+	}
+	if got := recycleItem.Deletion.DeletedBy.ServiceAccount; got != "ci/deployer" {
+		t.Errorf("unexpected service account %q", got)
+	}
+	if got := recycleItem.Deletion.PropagationPolicy; got == nil || *got != metav1.DeletePropagationForeground {
+		t.Errorf("unexpected propagation policy %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.RecycleSuccesses.WithLabelValues("apps", "deployments", "dev")); got != successes+1 {
+		t.Errorf("expected %v recycle successes, got %v", successes+1, got)
+	}
+	if names := recorded.names(); len(names) != 1 || names[0] != recycleItem.Name {
+		t.Errorf("expected a Recycled event for the RecycleItem, got %d events", len(names))
+	}
+}
+
+func TestRecycleDeleteObjectsWithoutRequest(t *testing.T) {
+	wh, _ := newTestWebhook(t, newFakeClients(interceptor.Funcs{}))
+
+	recorder := httptest.NewRecorder()
+	body := strings.NewReader(`{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview"}`)
+	wh.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, consts.WebhookServicePath+"/recycle-deployments", body))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected status code %d, got %d: %s", http.StatusBadRequest, recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestRecycleDeleteObjectsRepeatedRequest(t *testing.T) {
+	clients := newFakeClients(interceptor.Funcs{})
+	wh, recorded := newTestWebhook(t, clients)
+
+	// the api server retries the admission request with the same UID.
+	for range 3 {
+		if resp := review(t, wh, "delete-deployment.json", "recycle-deployments"); !resp.Allowed {
+			t.Fatalf("delete was not allowed: %v", resp.Result)
+		}
+	}
+
+	created := createdItems(t, clients)
+	if len(created) != 1 {
+		t.Fatalf("expected 1 RecycleItem, got %d", len(created))
+	}
+	recycleItem := created[0]
+	if got := recycleItem.Labels[api.ObjectUIDLabel]; got != "9b7c5d3e-1f2a-4b6c-8d0e-2f4a6b8c0d1e" {
+		t.Errorf("unexpected object UID label %q", got)
+	}
+	if got := recycleItem.Labels[api.RequestUIDLabel]; got != "0df28fbd-5f5f-4a3d-9e1c-6a1a5f4c2b7e" {
+		t.Errorf("unexpected request UID label %q", got)
+	}
+	// each acknowledged request is recorded, the Event recorder aggregates the repeated Events.
+	names := recorded.names()
+	if len(names) != 3 {
+		t.Errorf("expected 3 Recycled events, got %d", len(names))
+	}
+	for _, name := range names {
+		if name != recycleItem.Name {
+			t.Errorf("expected Recycled events of RecycleItem %q, got %q", recycleItem.Name, name)
+		}
+	}
+}
+
+func TestRecycleDeleteObjectsDryRun(t *testing.T) {
+	clients := newFakeClients(interceptor.Funcs{})
+	wh, _ := newTestWebhook(t, clients)
+
+	resp := review(t, wh, "delete-deployment-dry-run.json", "recycle-deployments")
+	if !resp.Allowed {
+		t.Errorf("delete was not allowed: %v", resp.Result)
+	}
+	if resp.UID != "6e1d3c2b-8a9f-4e7d-b6c5-a4f3e2d1c0b9" {
+		t.Errorf("unexpected response UID %q", resp.UID)
+	}
+	if created := createdItems(t, clients); len(created) != 0 {
+		t.Errorf("expected no RecycleItem for a dry-run delete, got %d", len(created))
+	}
+}
+
+func TestRecycleDeleteObjectsOnRecycleFailure(t *testing.T) {
+	tests := []struct {
+		name             string
+		onRecycleFailure api.RecycleFailurePolicy
+		wantAllowed      bool
+	}{
+		{name: "default", wantAllowed: true},
+		{name: "allow", onRecycleFailure: api.RecycleFailureAllow, wantAllowed: true},
+		{name: "deny", onRecycleFailure: api.RecycleFailureDeny, wantAllowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recyclePolicy := newDeploymentPolicy("recycle-deployments", time.Now())
+			recyclePolicy.OnRecycleFailure = tt.onRecycleFailure
+			clients := newFakeClients(interceptor.Funcs{}, recyclePolicy)
+			// the spool cannot be written once its directory is gone.
+			spoolDir := t.TempDir()
+			queue, _ := startRecycleQueue(t, clients, spoolDir, 1)
+			if err := os.RemoveAll(spoolDir); err != nil {
+				t.Fatalf("failed to remove spool: %v", err)
+			}
+
+			failures := testutil.ToFloat64(metrics.RecycleFailures.WithLabelValues("apps", "deployments", "dev"))
+			resp := review(t, NewWebhook(clients, newTestCache(t, clients), queue), "delete-deployment.json", "recycle-deployments")
+			if got := testutil.ToFloat64(metrics.RecycleFailures.WithLabelValues("apps", "deployments", "dev")); got != failures+1 {
+				t.Errorf("expected %v recycle failures, got %v", failures+1, got)
+			}
+			if resp.Allowed != tt.wantAllowed {
+				t.Errorf("expected allowed %v, got %v", tt.wantAllowed, resp.Allowed)
+			}
+			if !tt.wantAllowed && (resp.Result == nil || !strings.Contains(resp.Result.Message, "failed to spool RecycleItem")) {
+				t.Errorf("expected the denial to explain the failure, got %v", resp.Result)
+			}
+		})
+	}
+}
+
+func TestRecycleDeleteObjectsOverlappingPolicies(t *testing.T) {
+	now := time.Now()
+	devOnly := newDeploymentPolicy("recycle-dev", now.Add(-time.Hour))
+	devOnly.Target.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"env": "dev"}}
+	prodOnly := newDeploymentPolicy("recycle-prod", now.Add(-2*time.Hour))
+	prodOnly.Target.Namespaces = []string{"prod"}
+	all := newDeploymentPolicy("recycle-all", now)
+
+	tests := []struct {
+		name       string
+		policyName string
+		wantItems  int
+	}{
+		{name: "oldest matching policy recycles", policyName: "recycle-dev", wantItems: 1},
+		{name: "newer matching policy skips", policyName: "recycle-all", wantItems: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clients := newFakeClients(interceptor.Funcs{}, all, devOnly, prodOnly)
+			wh, _ := newTestWebhook(t, clients)
+
+			resp := review(t, wh, "delete-deployment.json", tt.policyName)
+			if !resp.Allowed {
+				t.Errorf("delete was not allowed: %v", resp.Result)
+			}
+			created := createdItems(t, clients)
+			if len(created) != tt.wantItems {
+				t.Fatalf("expected %d RecycleItems, got %d", tt.wantItems, len(created))
+			}
+			if tt.wantItems > 0 && created[0].RecyclePolicyName() != "recycle-dev" {
+				t.Errorf("unexpected RecyclePolicy %q", created[0].RecyclePolicyName())
+			}
+		})
+	}
+}
+
+func TestRecycleDeleteObjectsObjectSelector(t *testing.T) {
+	tests := []struct {
+		name        string
+		matchLabels map[string]string
+		wantItems   int
+	}{
+		// the older policy selects the deleted nginx deployment, and recycles it.
+		{name: "selected by older policy", matchLabels: map[string]string{"app": "nginx"}, wantItems: 0},
+		{name: "not selected by older policy", matchLabels: map[string]string{"app": "web"}, wantItems: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			selecting := newDeploymentPolicy("recycle-selected", now.Add(-time.Hour))
+			selecting.Target.ObjectSelector = &metav1.LabelSelector{MatchLabels: tt.matchLabels}
+			all := newDeploymentPolicy("recycle-all", now)
+			clients := newFakeClients(interceptor.Funcs{}, selecting, all)
+			wh, _ := newTestWebhook(t, clients)
+
+			if resp := review(t, wh, "delete-deployment.json", "recycle-all"); !resp.Allowed {
+				t.Errorf("delete was not allowed: %v", resp.Result)
+			}
+			if created := createdItems(t, clients); len(created) != tt.wantItems {
+				t.Errorf("expected %d RecycleItems, got %d", tt.wantItems, len(created))
+			}
+		})
+	}
+}
+
+func TestRecycleDeleteObjectsOverlappingDenyPolicy(t *testing.T) {
+	now := time.Now()
+	allow := newDeploymentPolicy("recycle-allow", now.Add(-time.Hour))
+	deny := newDeploymentPolicy("recycle-deny", now)
+	deny.OnRecycleFailure = api.RecycleFailureDeny
+
+	tests := []struct {
+		name        string
+		policyNames []string
+	}{
+		{name: "both webhooks recycle once", policyNames: []string{"recycle-allow", "recycle-deny"}},
+		// the api server ignores the webhook of the older policy when it times out.
+		{name: "webhook of the older policy ignored", policyNames: []string{"recycle-deny"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clients := newFakeClients(interceptor.Funcs{}, allow, deny)
+			wh, _ := newTestWebhook(t, clients)
+
+			for _, policyName := range tt.policyNames {
+				if resp := review(t, wh, "delete-deployment.json", policyName); !resp.Allowed {
+					t.Errorf("delete was not allowed by %s: %v", policyName, resp.Result)
+				}
+			}
+			created := createdItems(t, clients)
+			if len(created) != 1 {
+				t.Fatalf("expected 1 RecycleItem, got %d", len(created))
+			}
+			if got := created[0].RecyclePolicyName(); got != "recycle-allow" {
+				t.Errorf("expected the object recycled on behalf of the older policy, got %q", got)
+			}
+		})
+	}
+}
+
+func TestRecycleDeleteObjectsOverlappingDenyPolicyOnRecycleFailure(t *testing.T) {
+	now := time.Now()
+	allow := newDeploymentPolicy("recycle-allow", now.Add(-time.Hour))
+	deny := newDeploymentPolicy("recycle-deny", now)
+	deny.OnRecycleFailure = api.RecycleFailureDeny
+	clients := newFakeClients(interceptor.Funcs{}, allow, deny)
+	spoolDir := t.TempDir()
+	queue, _ := startRecycleQueue(t, clients, spoolDir, 1)
+	if err := os.RemoveAll(spoolDir); err != nil {
+		t.Fatalf("failed to remove spool: %v", err)
+	}
+	wh := NewWebhook(clients, newTestCache(t, clients), queue)
+
+	// both webhooks fail to recycle the object, and are refused the delete on behalf of the
+	// policy saying Deny.
+	for _, policyName := range []string{"recycle-allow", "recycle-deny"} {
+		resp := review(t, wh, "delete-deployment.json", policyName)
+		if resp.Allowed {
+			t.Fatalf("expected the delete to be denied by %s", policyName)
+		}
+		if resp.Result == nil || !strings.Contains(resp.Result.Message, "RecyclePolicy recycle-deny refuses the delete") {
+			t.Errorf("expected the denial to name the denying policy, got %v", resp.Result)
+		}
+	}
+}
+
+func TestResolveRecyclePolicy(t *testing.T) {
+	now := time.Now()
+	policy := func(name string, createdAt time.Time, onRecycleFailure api.RecycleFailurePolicy) api.RecyclePolicy {
+		recyclePolicy := newDeploymentPolicy(name, createdAt)
+		recyclePolicy.OnRecycleFailure = onRecycleFailure
+		return recyclePolicy
+	}
+	prodOnly := policy("prod-only", now.Add(-3*time.Hour), api.RecycleFailureDeny)
+	prodOnly.Target.Namespaces = []string{"prod"}
+
+	tests := []struct {
+		name        string
+		policies    []api.RecyclePolicy
+		policyName  string
+		wantPolicy  string
+		wantOwned   bool
+		wantDenying string
+	}{
+		{
+			name:       "single policy",
+			policies:   []api.RecyclePolicy{policy("a", now, api.RecycleFailureAllow)},
+			policyName: "a", wantPolicy: "a", wantOwned: true,
+		},
+		{
+			name:       "oldest policy owns",
+			policies:   []api.RecyclePolicy{policy("old", now.Add(-time.Hour), api.RecycleFailureAllow), policy("new", now, api.RecycleFailureAllow)},
+			policyName: "old", wantPolicy: "old", wantOwned: true,
+		},
+		{
+			name:       "newer policy skips",
+			policies:   []api.RecyclePolicy{policy("old", now.Add(-time.Hour), api.RecycleFailureAllow), policy("new", now, api.RecycleFailureAllow)},
+			policyName: "new", wantPolicy: "old", wantOwned: false,
+		},
+		{
+			name:       "same age ordered by name",
+			policies:   []api.RecyclePolicy{policy("b", now, api.RecycleFailureAllow), policy("a", now, api.RecycleFailureAllow)},
+			policyName: "b", wantPolicy: "a", wantOwned: false,
+		},
+		{
+			name:       "policies not matching the delete are ignored",
+			policies:   []api.RecyclePolicy{prodOnly, policy("new", now, api.RecycleFailureAllow)},
+			policyName: "new", wantPolicy: "new", wantOwned: true,
+		},
+		{
+			name:       "older allow owns, its webhook recycles",
+			policies:   []api.RecyclePolicy{policy("allow", now.Add(-time.Hour), api.RecycleFailureAllow), policy("deny", now, api.RecycleFailureDeny)},
+			policyName: "allow", wantPolicy: "allow", wantOwned: true, wantDenying: "deny",
+		},
+		{
+			name:       "newer deny recycles on behalf of older allow",
+			policies:   []api.RecyclePolicy{policy("allow", now.Add(-time.Hour), api.RecycleFailureAllow), policy("deny", now, api.RecycleFailureDeny)},
+			policyName: "deny", wantPolicy: "allow", wantOwned: true, wantDenying: "deny",
+		},
+		{
+			name:       "newer allow skips older deny",
+			policies:   []api.RecyclePolicy{policy("deny", now.Add(-time.Hour), api.RecycleFailureDeny), policy("allow", now, api.RecycleFailureAllow)},
+			policyName: "allow", wantPolicy: "deny", wantOwned: false, wantDenying: "deny",
+		},
+		{
+			name:       "newer deny skips older deny",
+			policies:   []api.RecyclePolicy{policy("old", now.Add(-time.Hour), api.RecycleFailureDeny), policy("new", now, api.RecycleFailureDeny)},
+			policyName: "new", wantPolicy: "old", wantOwned: false, wantDenying: "old",
+		},
+		{
+			name:       "unknown policy recycles without retention",
+			policies:   []api.RecyclePolicy{policy("a", now, api.RecycleFailureAllow)},
+			policyName: "deleted", wantOwned: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clients := newFakeClients(interceptor.Funcs{}, tt.policies...)
+			wh := NewWebhook(clients, newTestCache(t, clients), nil)
+			body, err := os.ReadFile(filepath.Join("testdata", "delete-deployment.json"))
+			if err != nil {
+				t.Fatalf("failed to read fixture: %v", err)
+			}
+			var admissionReview admissionv1.AdmissionReview
+			if err := json.Unmarshal(body, &admissionReview); err != nil {
+				t.Fatalf("failed to decode fixture: %v", err)
+			}
+
+			resolution, err := wh.resolveRecyclePolicy(context.Background(), tt.policyName, admissionReview.Request)
+			if err != nil {
+				t.Fatalf("failed to resolve RecyclePolicy: %v", err)
+			}
+			if got := policyNameOf(resolution.policy); got != tt.wantPolicy {
+				t.Errorf("expected policy %q, got %q", tt.wantPolicy, got)
+			}
+			if resolution.owned != tt.wantOwned {
+				t.Errorf("expected owned %v, got %v", tt.wantOwned, resolution.owned)
+			}
+			if got := policyNameOf(resolution.denying); got != tt.wantDenying {
+				t.Errorf("expected denying policy %q, got %q", tt.wantDenying, got)
+			}
+		})
+	}
+}
+
+// policyNameOf returns the name of the policy, empty if nil.
+func policyNameOf(recyclePolicy *api.RecyclePolicy) string {
+	if recyclePolicy == nil {
+		return ""
+	}
+	return recyclePolicy.Name
+}
+
+func TestRecycleDeleteObjectsUnresolvedPolicies(t *testing.T) {
+	tests := []struct {
+		name             string
+		onRecycleFailure api.RecycleFailurePolicy
+		wantAllowed      bool
+	}{
+		{name: "allow", onRecycleFailure: api.RecycleFailureAllow, wantAllowed: true},
+		{name: "deny", onRecycleFailure: api.RecycleFailureDeny, wantAllowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recyclePolicy := newDeploymentPolicy("recycle-dev", time.Now())
+			recyclePolicy.OnRecycleFailure = tt.onRecycleFailure
+			recyclePolicy.Target.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"env": "dev"}}
+			clients := newFakeClients(interceptor.Funcs{}, recyclePolicy)
+			queue, _ := startRecycleQueue(t, clients, t.TempDir(), 1)
+			cache := fakeclient.NewClientBuilder().WithScheme(krbclient.Scheme()).WithObjects(&recyclePolicy).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+						return errors.New("namespace not cached")
+					},
+				}).Build()
+
+			resp := review(t, NewWebhook(clients, cache, queue), "delete-deployment.json", "recycle-dev")
+			if resp.Allowed != tt.wantAllowed {
+				t.Errorf("expected allowed %v, got %v", tt.wantAllowed, resp.Allowed)
+			}
+			if created := createdItems(t, clients); len(created) != 0 {
+				t.Errorf("expected no RecycleItem when the matching policies are unknown, got %d", len(created))
+			}
+		})
+	}
+}
+
+func TestRecycleDeleteObjectsRejectedItem(t *testing.T) {
+	tests := []struct {
+		name             string
+		onRecycleFailure api.RecycleFailurePolicy
+		wantAllowed      bool
+		wantDryRuns      int
+	}{
+		{name: "allow", onRecycleFailure: api.RecycleFailureAllow, wantAllowed: true},
+		{name: "deny", onRecycleFailure: api.RecycleFailureDeny, wantAllowed: false, wantDryRuns: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recyclePolicy := newDeploymentPolicy("recycle-deployments", time.Now())
+			recyclePolicy.OnRecycleFailure = tt.onRecycleFailure
+			dryRuns := 0
+			// the api server rejects the RecycleItem, like etcd rejects objects over its size limit.
+			clients := newFakeClients(interceptor.Funcs{
+				Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+					createOptions := &client.CreateOptions{}
+					createOptions.ApplyOptions(opts)
+					if len(createOptions.DryRun) > 0 {
+						dryRuns++
+					}
+					return k8serrors.NewRequestEntityTooLargeError("limit is 1.5MiB")
+				},
+			}, recyclePolicy)
+			wh, recorded := newTestWebhook(t, clients)
+
+			resp := review(t, wh, "delete-deployment.json", "recycle-deployments")
+			if resp.Allowed != tt.wantAllowed {
+				t.Errorf("expected allowed %v, got %v", tt.wantAllowed, resp.Allowed)
+			}
+			if !tt.wantAllowed && (resp.Result == nil || !strings.Contains(resp.Result.Message, "RecycleItem rejected by the api server")) {
+				t.Errorf("expected the denial to explain the rejection, got %v", resp.Result)
+			}
+			if dryRuns != tt.wantDryRuns {
+				t.Errorf("expected %d dry runs, got %d", tt.wantDryRuns, dryRuns)
+			}
+			if names := recorded.names(); len(names) != 0 {
+				t.Errorf("expected no RecycleItem, got %v", names)
+			}
+		})
+	}
+}
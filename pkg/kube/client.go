@@ -17,44 +17,47 @@ limitations under the License.
 package kube
 
 import (
-	"k8s.io/client-go/discovery"
+	"fmt"
+
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	controllerruntime "sigs.k8s.io/controller-runtime"
 )
 
-var (
-	restConfig      *rest.Config
-	client          kubernetes.Interface
-	dynamicClient   dynamic.Interface
-	discoveryClient discovery.DiscoveryInterface
-)
-
-func RestConfig() *rest.Config {
-	if restConfig == nil {
-		restConfig = controllerruntime.GetConfigOrDie()
+// RestConfig returns the config of the cluster, read from the --kubeconfig flag, the KUBECONFIG
+// environment variable, the in-cluster config or ~/.kube/config.
+func RestConfig() (*rest.Config, error) {
+	config, err := controllerruntime.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
 	}
-	return restConfig
+	return config, nil
 }
 
-func Client() kubernetes.Interface {
-	if client == nil {
-		client = kubernetes.NewForConfigOrDie(RestConfig())
-	}
-	return client
+// Clients are the clients of a cluster.
+type Clients struct {
+	// Config is nil for clients not built from a config, like fakes.
+	Config     *rest.Config
+	Kubernetes kubernetes.Interface
+	Dynamic    dynamic.Interface
+	Discovery  *CachedDiscovery
 }
 
-func DynamicClient() dynamic.Interface {
-	if dynamicClient == nil {
-		dynamicClient = dynamic.NewForConfigOrDie(RestConfig())
+// NewClients returns the clients of the cluster of the config.
+func NewClients(config *rest.Config) (*Clients, error) {
+	kubernetesClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
 	}
-	return dynamicClient
-}
-
-func DiscoveryClient() discovery.DiscoveryInterface {
-	if discoveryClient == nil {
-		discoveryClient = Client().Discovery()
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
 	}
-	return discoveryClient
+	return &Clients{
+		Config:     config,
+		Kubernetes: kubernetesClient,
+		Dynamic:    dynamicClient,
+		Discovery:  NewCachedDiscovery(kubernetesClient.Discovery(), DefaultDiscoveryTTL),
+	}, nil
 }
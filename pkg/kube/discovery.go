@@ -0,0 +1,167 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/restmapper"
+)
+
+const (
+	// DefaultDiscoveryTTL is how long discovery is served from memory before it is refreshed.
+	DefaultDiscoveryTTL = 5 * time.Minute
+	// minMissRefreshInterval bounds how often lookups of unknown resources refresh discovery,
+	// so deletes of resources the cluster does not serve cannot flood the api server.
+	minMissRefreshInterval = 5 * time.Second
+)
+
+// CachedDiscovery serves the discovery of the api server from memory. It is refreshed once it
+// is older than its TTL, and when a lookup misses a resource the cluster may have added since.
+type CachedDiscovery struct {
+	client discovery.CachedDiscoveryInterface
+	mapper *restmapper.DeferredDiscoveryRESTMapper
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu          sync.Mutex
+	refreshedAt time.Time
+}
+
+// NewCachedDiscovery returns a CachedDiscovery of the discovery client, refreshed every ttl.
+func NewCachedDiscovery(delegate discovery.DiscoveryInterface, ttl time.Duration) *CachedDiscovery {
+	client := memory.NewMemCacheClient(delegate)
+	return &CachedDiscovery{
+		client: client,
+		mapper: restmapper.NewDeferredDiscoveryRESTMapper(client),
+		ttl:    ttl,
+		now:    time.Now,
+	}
+}
+
+// Client returns the cached discovery client.
+func (d *CachedDiscovery) Client() discovery.CachedDiscoveryInterface {
+	d.expire()
+	return d.client
+}
+
+// RESTMapper returns a RESTMapper backed by the cached discovery, refreshed when it does not
+// know the requested resource or kind.
+func (d *CachedDiscovery) RESTMapper() meta.RESTMapper {
+	return &cachedRESTMapper{discovery: d}
+}
+
+// Invalidate drops the cached discovery, the next lookup reads it from the api server.
+func (d *CachedDiscovery) Invalidate() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.invalidateLocked()
+}
+
+// refreshOnMiss invalidates the cached discovery after a lookup missed, unless it was refreshed
+// recently, and returns whether the lookup is worth retrying.
+func (d *CachedDiscovery) refreshOnMiss() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.now().Sub(d.refreshedAt) < minMissRefreshInterval {
+		return false
+	}
+	d.invalidateLocked()
+	return true
+}
+
+// expire invalidates the cached discovery once it is older than the TTL.
+func (d *CachedDiscovery) expire() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch {
+	case d.refreshedAt.IsZero():
+		d.refreshedAt = d.now()
+	case d.now().Sub(d.refreshedAt) > d.ttl:
+		d.invalidateLocked()
+	}
+}
+
+func (d *CachedDiscovery) invalidateLocked() {
+	// resetting the mapper also invalidates the client it reads from.
+	d.mapper.Reset()
+	d.refreshedAt = d.now()
+}
+
+// lookup runs f against the cached discovery, and once more against a refreshed one if f
+// reports a miss.
+func lookup[T any](d *CachedDiscovery, f func() (T, error), missed func(error) bool) (T, error) {
+	d.expire()
+	result, err := f()
+	if err != nil && missed(err) && d.refreshOnMiss() {
+		return f()
+	}
+	return result, err
+}
+
+// cachedRESTMapper is the RESTMapper of a CachedDiscovery.
+type cachedRESTMapper struct {
+	discovery *CachedDiscovery
+}
+
+var _ meta.RESTMapper = &cachedRESTMapper{}
+
+func (m *cachedRESTMapper) KindFor(resource schema.GroupVersionResource) (schema.GroupVersionKind, error) {
+	return lookup(m.discovery, func() (schema.GroupVersionKind, error) {
+		return m.discovery.mapper.KindFor(resource)
+	}, meta.IsNoMatchError)
+}
+
+func (m *cachedRESTMapper) KindsFor(resource schema.GroupVersionResource) ([]schema.GroupVersionKind, error) {
+	return lookup(m.discovery, func() ([]schema.GroupVersionKind, error) {
+		return m.discovery.mapper.KindsFor(resource)
+	}, meta.IsNoMatchError)
+}
+
+func (m *cachedRESTMapper) ResourceFor(input schema.GroupVersionResource) (schema.GroupVersionResource, error) {
+	return lookup(m.discovery, func() (schema.GroupVersionResource, error) {
+		return m.discovery.mapper.ResourceFor(input)
+	}, meta.IsNoMatchError)
+}
+
+func (m *cachedRESTMapper) ResourcesFor(input schema.GroupVersionResource) ([]schema.GroupVersionResource, error) {
+	return lookup(m.discovery, func() ([]schema.GroupVersionResource, error) {
+		return m.discovery.mapper.ResourcesFor(input)
+	}, meta.IsNoMatchError)
+}
+
+func (m *cachedRESTMapper) RESTMapping(gk schema.GroupKind, versions ...string) (*meta.RESTMapping, error) {
+	return lookup(m.discovery, func() (*meta.RESTMapping, error) {
+		return m.discovery.mapper.RESTMapping(gk, versions...)
+	}, meta.IsNoMatchError)
+}
+
+func (m *cachedRESTMapper) RESTMappings(gk schema.GroupKind, versions ...string) ([]*meta.RESTMapping, error) {
+	return lookup(m.discovery, func() ([]*meta.RESTMapping, error) {
+		return m.discovery.mapper.RESTMappings(gk, versions...)
+	}, meta.IsNoMatchError)
+}
+
+func (m *cachedRESTMapper) ResourceSingularizer(resource string) (string, error) {
+	m.discovery.expire()
+	return m.discovery.mapper.ResourceSingularizer(resource)
+}
@@ -0,0 +1,207 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+var (
+	deploymentsGVR = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	namespacesGVR  = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	widgetsGVR     = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+)
+
+// fakeDiscovery serves deployments and namespaces and counts the discovery calls it answers.
+func fakeDiscovery() *fakediscovery.FakeDiscovery {
+	return &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{
+		Resources: []*metav1.APIResourceList{
+			{
+				GroupVersion: "v1",
+				APIResources: []metav1.APIResource{{Name: "namespaces", Kind: "Namespace", Namespaced: false}},
+			},
+			{
+				GroupVersion: "apps/v1",
+				APIResources: []metav1.APIResource{{Name: "deployments", Kind: "Deployment", Namespaced: true}},
+			},
+		},
+	}}
+}
+
+// fakeClock is a settable clock for the TTLs of a CachedDiscovery.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Step(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newFakeDiscovery returns a CachedDiscovery of the fake, with its clock.
+func newFakeDiscovery(fake *fakediscovery.FakeDiscovery, ttl time.Duration) (*CachedDiscovery, *fakeClock) {
+	clock := &fakeClock{now: time.Now()}
+	d := NewCachedDiscovery(fake, ttl)
+	d.now = clock.Now
+	return d, clock
+}
+
+func TestCachedDiscoveryIsResourceNamespaced(t *testing.T) {
+	fake := fakeDiscovery()
+	d, _ := newFakeDiscovery(fake, DefaultDiscoveryTTL)
+
+	for range 10 {
+		for gvr, want := range map[schema.GroupVersionResource]bool{deploymentsGVR: true, namespacesGVR: false} {
+			namespaced, err := d.IsResourceNamespaced(gvr)
+			if err != nil {
+				t.Fatalf("✗ failed to check if %s is namespaced: %v", gvr, err)
+			}
+			if namespaced != want {
+				t.Errorf("✗ expected %s namespaced %v, got %v", gvr, want, namespaced)
+			}
+		}
+	}
+
+	calls := len(fake.Actions())
+	if _, err := d.IsResourceNamespaced(deploymentsGVR); err != nil {
+		t.Fatalf("✗ failed to check if %s is namespaced: %v", deploymentsGVR, err)
+	}
+	if got := len(fake.Actions()); got != calls {
+		t.Errorf("✗ expected a cached lookup, got %d more discovery calls", got-calls)
+	}
+}
+
+func TestCachedDiscoveryExpires(t *testing.T) {
+	fake := fakeDiscovery()
+	d, clock := newFakeDiscovery(fake, time.Minute)
+
+	if _, err := d.IsResourceNamespaced(deploymentsGVR); err != nil {
+		t.Fatalf("✗ failed to check if %s is namespaced: %v", deploymentsGVR, err)
+	}
+	calls := len(fake.Actions())
+
+	clock.Step(2 * time.Minute)
+	if _, err := d.IsResourceNamespaced(deploymentsGVR); err != nil {
+		t.Fatalf("✗ failed to check if %s is namespaced: %v", deploymentsGVR, err)
+	}
+	if got := len(fake.Actions()); got == calls {
+		t.Error("✗ expected the expired discovery to be refreshed")
+	}
+}
+
+func TestCachedDiscoveryRefreshesOnMiss(t *testing.T) {
+	fake := fakeDiscovery()
+	d, clock := newFakeDiscovery(fake, DefaultDiscoveryTTL)
+
+	if _, err := d.IsResourceNamespaced(widgetsGVR); err == nil {
+		t.Fatalf("✗ expected %s to be unknown", widgetsGVR)
+	}
+
+	// a CRD is installed, misses refresh the discovery once it is not too recent.
+	fake.Resources = append(fake.Resources, &metav1.APIResourceList{
+		GroupVersion: widgetsGVR.GroupVersion().String(),
+		APIResources: []metav1.APIResource{{Name: "widgets", Kind: "Widget", Namespaced: true}},
+	})
+	if _, err := d.IsResourceNamespaced(widgetsGVR); err == nil {
+		t.Fatalf("✗ expected %s to be unknown until the discovery may be refreshed", widgetsGVR)
+	}
+	clock.Step(minMissRefreshInterval)
+
+	namespaced, err := d.IsResourceNamespaced(widgetsGVR)
+	if err != nil {
+		t.Fatalf("✗ failed to check if %s is namespaced: %v", widgetsGVR, err)
+	}
+	if !namespaced {
+		t.Errorf("✗ expected %s to be namespaced", widgetsGVR)
+	}
+
+	clock.Step(minMissRefreshInterval)
+	gvk, err := d.RESTMapper().KindFor(widgetsGVR)
+	if err != nil {
+		t.Fatalf("✗ failed to map %s: %v", widgetsGVR, err)
+	}
+	if gvk.Kind != "Widget" {
+		t.Errorf("✗ expected kind Widget, got %s", gvk.Kind)
+	}
+}
+
+// BenchmarkIsResourceNamespaced compares the discovery calls of admission requests served
+// from the cache with the ones of a discovery refreshed on every lookup, as before caching.
+func BenchmarkIsResourceNamespaced(b *testing.B) {
+	for _, bb := range []struct {
+		name string
+		ttl  time.Duration
+	}{
+		{name: "uncached", ttl: 0},
+		{name: "cached", ttl: DefaultDiscoveryTTL},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			fake := fakeDiscovery()
+			d, clock := newFakeDiscovery(fake, bb.ttl)
+
+			b.ResetTimer()
+			for range b.N {
+				clock.Step(time.Millisecond)
+				if _, err := d.IsResourceNamespaced(deploymentsGVR); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(fake.Actions()))/float64(b.N), "discovery-calls/op")
+		})
+	}
+}
+
+// BenchmarkGetPreferredGroupVersionResourceFor compares the discovery calls of CLI lookups of
+// resource names with and without the cache.
+func BenchmarkGetPreferredGroupVersionResourceFor(b *testing.B) {
+	for _, bb := range []struct {
+		name string
+		ttl  time.Duration
+	}{
+		{name: "uncached", ttl: 0},
+		{name: "cached", ttl: DefaultDiscoveryTTL},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			fake := fakeDiscovery()
+			d, clock := newFakeDiscovery(fake, bb.ttl)
+
+			b.ResetTimer()
+			for range b.N {
+				clock.Step(time.Millisecond)
+				if _, err := d.GetPreferredGroupVersionResourceFor("deployments.apps"); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(fake.Actions()))/float64(b.N), "discovery-calls/op")
+		})
+	}
+}
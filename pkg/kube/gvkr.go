@@ -17,18 +17,26 @@ limitations under the License.
 package kube
 
 import (
+	"errors"
 	"fmt"
 	"slices"
 
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/discovery/cached/memory"
 )
 
-// GetAllGroupResources returns all group resources in the cluster.
-func GetAllGroupResources() ([]string, error) {
-	discoveryClient := DiscoveryClient()
+// errResourceNotFound is returned by lookups that miss the requested resource.
+var errResourceNotFound = errors.New("resource not found")
+
+// isDiscoveryMiss returns whether a discovery lookup failed because the resource is unknown.
+func isDiscoveryMiss(err error) bool {
+	return errors.Is(err, errResourceNotFound) || errors.Is(err, memory.ErrCacheNotFound) || k8serrors.IsNotFound(err)
+}
 
-	apiResourceLists, err := discoveryClient.ServerPreferredResources()
+// GetAllGroupResources returns all group resources in the cluster.
+func (d *CachedDiscovery) GetAllGroupResources() ([]string, error) {
+	apiResourceLists, err := d.Client().ServerPreferredResources()
 	if err != nil {
 		return nil, err
 	}
@@ -46,16 +54,8 @@ func GetAllGroupResources() ([]string, error) {
 }
 
 // GetResourceNameFromGroupVersionKind returns the resource name from the given GroupVersionKind.
-func GetResourceNameFromGroupVersionKind(gvk schema.GroupVersionKind) (string, error) {
-	discoveryClient := DiscoveryClient()
-
-	groupResources, err := restmapper.GetAPIGroupResources(discoveryClient)
-	if err != nil {
-		return "", err
-	}
-	mapper := restmapper.NewDiscoveryRESTMapper(groupResources)
-
-	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+func (d *CachedDiscovery) GetResourceNameFromGroupVersionKind(gvk schema.GroupVersionKind) (string, error) {
+	mapping, err := d.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
 	if err != nil {
 		return "", err
 	}
@@ -64,8 +64,8 @@ func GetResourceNameFromGroupVersionKind(gvk schema.GroupVersionKind) (string, e
 }
 
 // GetGroupVersionResourceFromGroupVersionKind returns the GroupVersionResource from the given GroupVersionKind.
-func GetGroupVersionResourceFromGroupVersionKind(gvk schema.GroupVersionKind) (schema.GroupVersionResource, error) {
-	resource, err := GetResourceNameFromGroupVersionKind(gvk)
+func (d *CachedDiscovery) GetGroupVersionResourceFromGroupVersionKind(gvk schema.GroupVersionKind) (schema.GroupVersionResource, error) {
+	resource, err := d.GetResourceNameFromGroupVersionKind(gvk)
 	if err != nil {
 		return schema.GroupVersionResource{}, err
 	}
@@ -79,37 +79,44 @@ func GetGroupVersionResourceFromGroupVersionKind(gvk schema.GroupVersionKind) (s
 
 // GetGroupVersionKindFromResourceName returns the GroupVersionKind from the given resource name.
 // resource name can be plural, singular or short names.
-func GetGroupVersionKindFromResourceName(resourceName string) ([]schema.GroupVersionKind, error) {
-	discoveryClient := DiscoveryClient()
-
-	apiResourceLists, err := discoveryClient.ServerPreferredResources()
-	if err != nil {
-		return nil, err
-	}
-
-	var result []schema.GroupVersionKind
-	for _, resourceList := range apiResourceLists {
-		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+func (d *CachedDiscovery) GetGroupVersionKindFromResourceName(resourceName string) ([]schema.GroupVersionKind, error) {
+	result, err := lookup(d, func() ([]schema.GroupVersionKind, error) {
+		apiResourceLists, err := d.Client().ServerPreferredResources()
 		if err != nil {
-			continue
+			return nil, err
 		}
 
-		for _, res := range resourceList.APIResources {
-			if res.Name == resourceName || res.SingularName == resourceName || slices.Contains(res.ShortNames, resourceName) {
-				result = append(result, schema.GroupVersionKind{
-					Group:   gv.Group,
-					Version: gv.Version,
-					Kind:    res.Kind,
-				})
+		var result []schema.GroupVersionKind
+		for _, resourceList := range apiResourceLists {
+			gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+			if err != nil {
+				continue
+			}
+
+			for _, res := range resourceList.APIResources {
+				if res.Name == resourceName || res.SingularName == resourceName || slices.Contains(res.ShortNames, resourceName) {
+					result = append(result, schema.GroupVersionKind{
+						Group:   gv.Group,
+						Version: gv.Version,
+						Kind:    res.Kind,
+					})
+				}
 			}
 		}
+		if len(result) == 0 {
+			return nil, errResourceNotFound
+		}
+		return result, nil
+	}, isDiscoveryMiss)
+	if errors.Is(err, errResourceNotFound) {
+		return nil, nil
 	}
-	return result, nil
+	return result, err
 }
 
 // GetPreferredGroupVersionResourceFor returns the preferred GroupVersionResource from the given resource name.
 // resource name can be plural, singular, short names or grouped resource name like deployments.apps
-func GetPreferredGroupVersionResourceFor(resource string) (*schema.GroupVersionResource, error) {
+func (d *CachedDiscovery) GetPreferredGroupVersionResourceFor(resource string) (*schema.GroupVersionResource, error) {
 	gvr, gr := schema.ParseResourceArg(resource)
 	if gvr == nil {
 		gvr = &schema.GroupVersionResource{
@@ -118,49 +125,57 @@ func GetPreferredGroupVersionResourceFor(resource string) (*schema.GroupVersionR
 		}
 	}
 
-	discoveryClient := DiscoveryClient()
-
-	apiResourceLists, err := discoveryClient.ServerPreferredResources()
-	if err != nil {
-		return nil, err
-	}
-
-	for _, resourceList := range apiResourceLists {
-		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+	result, err := lookup(d, func() (*schema.GroupVersionResource, error) {
+		apiResourceLists, err := d.Client().ServerPreferredResources()
 		if err != nil {
-			continue
+			return nil, err
 		}
 
-		if gr.Group != "" && gv.Group != gr.Group && gvr.Group != "" && gv.Group != gvr.Group {
-			continue
-		}
+		for _, resourceList := range apiResourceLists {
+			gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+			if err != nil {
+				continue
+			}
 
-		for _, res := range resourceList.APIResources {
-			if res.Name == gr.Resource || res.SingularName == gr.Resource || slices.Contains(res.ShortNames, gr.Resource) {
-				return &schema.GroupVersionResource{
-					Group:    gv.Group,
-					Version:  gv.Version,
-					Resource: res.Name,
-				}, nil
+			if gr.Group != "" && gv.Group != gr.Group && gvr.Group != "" && gv.Group != gvr.Group {
+				continue
+			}
+
+			for _, res := range resourceList.APIResources {
+				if res.Name == gr.Resource || res.SingularName == gr.Resource || slices.Contains(res.ShortNames, gr.Resource) {
+					return &schema.GroupVersionResource{
+						Group:    gv.Group,
+						Version:  gv.Version,
+						Resource: res.Name,
+					}, nil
+				}
 			}
 		}
+		return nil, errResourceNotFound
+	}, isDiscoveryMiss)
+	if errors.Is(err, errResourceNotFound) {
+		return nil, fmt.Errorf("can not find preferred GroupVersionResource for resource %s", resource)
 	}
-	return nil, fmt.Errorf("can not find preferred GroupVersionResource for resource %s", resource)
+	return result, err
 }
 
 // IsResourceNamespaced checks if the given GroupVersionResource is namespaced.
-func IsResourceNamespaced(gvr schema.GroupVersionResource) (bool, error) {
-	discoveryClient := DiscoveryClient()
-
-	apiResourceList, err := discoveryClient.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
-	if err != nil {
-		return false, err
-	}
+func (d *CachedDiscovery) IsResourceNamespaced(gvr schema.GroupVersionResource) (bool, error) {
+	namespaced, err := lookup(d, func() (bool, error) {
+		apiResourceList, err := d.Client().ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+		if err != nil {
+			return false, err
+		}
 
-	for _, apiResource := range apiResourceList.APIResources {
-		if apiResource.Name == gvr.Resource {
-			return apiResource.Namespaced, nil
+		for _, apiResource := range apiResourceList.APIResources {
+			if apiResource.Name == gvr.Resource {
+				return apiResource.Namespaced, nil
+			}
 		}
+		return false, errResourceNotFound
+	}, isDiscoveryMiss)
+	if errors.Is(err, errResourceNotFound) {
+		return false, fmt.Errorf("can not assert if resource %s is namespaced", gvr.GroupResource().String())
 	}
-	return false, fmt.Errorf("can not assert if resource %s is namespaced", gvr.GroupResource().String())
+	return namespaced, err
 }
@@ -22,6 +22,21 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
+// clusterDiscovery returns the discovery of the cluster of the kubeconfig.
+func clusterDiscovery(t *testing.T) *CachedDiscovery {
+	t.Helper()
+
+	config, err := RestConfig()
+	if err != nil {
+		t.Fatalf("✗ %v", err)
+	}
+	clients, err := NewClients(config)
+	if err != nil {
+		t.Fatalf("✗ %v", err)
+	}
+	return clients.Discovery
+}
+
 func TestGetResourceNameFromGroupVersionKind(t *testing.T) {
 	testdata := []struct {
 		name    string
@@ -48,9 +63,10 @@ func TestGetResourceNameFromGroupVersionKind(t *testing.T) {
 		},
 	}
 
+	discovery := clusterDiscovery(t)
 	for _, tt := range testdata {
 		t.Run(tt.name, func(t *testing.T) {
-			resourceName, err := GetResourceNameFromGroupVersionKind(tt.gvk)
+			resourceName, err := discovery.GetResourceNameFromGroupVersionKind(tt.gvk)
 			if err != nil {
 				t.Fatalf("✗ failed to get resource name: %v", err)
 			}
@@ -92,9 +108,10 @@ func TestGetGroupVersionKindFromResourceName(t *testing.T) {
 		},
 	}
 
+	discovery := clusterDiscovery(t)
 	for _, tt := range testdata {
 		t.Run(tt.name, func(t *testing.T) {
-			gvks, err := GetGroupVersionKindFromResourceName(tt.resource)
+			gvks, err := discovery.GetGroupVersionKindFromResourceName(tt.resource)
 			if err != nil {
 				t.Fatalf("✗ failed to get group version kind: %v", err)
 			}
@@ -175,9 +192,10 @@ func TestGetGroupVersionResourceFromResourceName(t *testing.T) {
 		},
 	}
 
+	discovery := clusterDiscovery(t)
 	for _, tt := range testdata {
 		t.Run(tt.name, func(t *testing.T) {
-			gvr, err := GetPreferredGroupVersionResourceFor(tt.resource)
+			gvr, err := discovery.GetPreferredGroupVersionResourceFor(tt.resource)
 			if err != nil {
 				t.Fatalf("✗ get group version resource: %v", err)
 			}
@@ -0,0 +1,98 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// consoleHandler writes one human-friendly line per log entry: a symbol of the level,
+// the message, the error and the fields, without time.
+//
+//	✓ created RecyclePolicy policy=recycle-deployments
+//	✗ failed to get RecycleItem: not found item=foo
+type consoleHandler struct {
+	mu     *sync.Mutex
+	output io.Writer
+	level  slog.Leveler
+	// attrs are the fields added with WithAttrs, keys prefixed by their groups.
+	attrs  []slog.Attr
+	prefix string
+}
+
+func newConsoleHandler(output io.Writer, level slog.Leveler) *consoleHandler {
+	return &consoleHandler{mu: &sync.Mutex{}, output: output, level: level}
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *consoleHandler) Handle(_ context.Context, record slog.Record) error {
+	var line strings.Builder
+	switch {
+	case record.Level >= slog.LevelError:
+		line.WriteString("✗ ")
+	case record.Level >= slog.LevelWarn:
+		line.WriteString("! ")
+	case record.Level >= slog.LevelInfo:
+		line.WriteString("✓ ")
+	default:
+		line.WriteString("» ")
+	}
+	line.WriteString(record.Message)
+
+	var fields []slog.Attr
+	fields = append(fields, h.attrs...)
+	record.Attrs(func(attr slog.Attr) bool {
+		// logr logs the error of Error calls as "err", print it after the message.
+		if attr.Key == "err" && h.prefix == "" {
+			fmt.Fprintf(&line, ": %v", attr.Value.Any())
+			return true
+		}
+		fields = append(fields, slog.Attr{Key: h.prefix + attr.Key, Value: attr.Value})
+		return true
+	})
+	for _, field := range fields {
+		fmt.Fprintf(&line, " %s=%v", field.Key, field.Value.Resolve())
+	}
+	line.WriteString("\n")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.output, line.String())
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	result := *h
+	result.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, attr := range attrs {
+		result.attrs = append(result.attrs, slog.Attr{Key: h.prefix + attr.Key, Value: attr.Value})
+	}
+	return &result
+}
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	result := *h
+	result.prefix = h.prefix + name + "."
+	return &result
+}
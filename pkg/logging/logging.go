@@ -0,0 +1,134 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package logging sets up the structured, levelled logger of the krb components.
+package logging
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/go-logr/logr"
+	"k8s.io/klog/v2"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Format is the output format of the logger.
+type Format string
+
+const (
+	// FormatText writes logfmt lines, for servers read by humans.
+	FormatText Format = "text"
+	// FormatJSON writes JSON lines, for servers read by log pipelines.
+	FormatJSON Format = "json"
+	// FormatConsole writes human-friendly lines, for the CLI.
+	FormatConsole Format = "console"
+)
+
+// Keys of the contextual fields of the log entries.
+const (
+	KeyPolicy     = "policy"
+	KeyItem       = "item"
+	KeyGVR        = "gvr"
+	KeyObject     = "object"
+	KeyRequestUID = "requestUID"
+)
+
+// Options configures the logger.
+type Options struct {
+	Format Format
+	// Verbosity enables the logs of V(n) levels up to it.
+	Verbosity int
+	// Output defaults to stderr.
+	Output io.Writer
+}
+
+// String implements flag.Value.
+func (f *Format) String() string {
+	return string(*f)
+}
+
+// Set implements flag.Value.
+func (f *Format) Set(value string) error {
+	switch format := Format(value); format {
+	case FormatText, FormatJSON, FormatConsole:
+		*f = format
+		return nil
+	}
+	return fmt.Errorf("must be one of: %s|%s|%s", FormatText, FormatJSON, FormatConsole)
+}
+
+// AddFlags registers the --log-format and --v flags, the current options are the defaults.
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	fs.Var(&o.Format, "log-format", fmt.Sprintf("Log format, one of: %s|%s|%s", FormatText, FormatJSON, FormatConsole))
+	fs.IntVar(&o.Verbosity, "v", o.Verbosity, "Log verbosity, higher levels log more details")
+}
+
+// New returns a logger configured by the options.
+func New(opts Options) logr.Logger {
+	return logr.FromSlogHandler(newHandler(opts))
+}
+
+func newHandler(opts Options) slog.Handler {
+	output := opts.Output
+	if output == nil {
+		output = os.Stderr
+	}
+	// logr V(n) logs at slog level -n.
+	level := slog.Level(-opts.Verbosity)
+
+	switch opts.Format {
+	case FormatJSON:
+		return slog.NewJSONHandler(output, &slog.HandlerOptions{Level: level})
+	case FormatConsole:
+		return newConsoleHandler(output, level)
+	default:
+		return slog.NewTextHandler(output, &slog.HandlerOptions{Level: level})
+	}
+}
+
+var logger = New(Options{Format: FormatText})
+
+// Setup makes the logger configured by the options the logger of krb, slog, controller-runtime and client-go.
+func Setup(opts Options) logr.Logger {
+	handler := newHandler(opts)
+	logger = logr.FromSlogHandler(handler)
+	slog.SetDefault(slog.New(handler))
+	ctrllog.SetLogger(logger)
+	klog.SetLogger(logger)
+	return logger
+}
+
+// Logger returns the logger of krb.
+func Logger() logr.Logger {
+	return logger
+}
+
+// FromContext returns the logger of the context, carrying its contextual fields, or the logger of krb.
+func FromContext(ctx context.Context) logr.Logger {
+	if l, err := logr.FromContext(ctx); err == nil {
+		return l
+	}
+	return logger
+}
+
+// IntoContext returns a context carrying the logger.
+func IntoContext(ctx context.Context, l logr.Logger) context.Context {
+	return logr.NewContext(ctx, l)
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJSONVerbosity(t *testing.T) {
+	var output bytes.Buffer
+	logger := New(Options{Format: FormatJSON, Verbosity: 1, Output: &output})
+
+	logger.WithValues(KeyPolicy, "recycle-deployments").V(1).Info("matched policy", KeyGVR, "apps/v1, Resource=deployments")
+	logger.V(2).Info("too verbose")
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 log line, got %d: %q", len(lines), output.String())
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("log line is not JSON: %v", err)
+	}
+	if entry["msg"] != "matched policy" || entry[KeyPolicy] != "recycle-deployments" || entry[KeyGVR] != "apps/v1, Resource=deployments" {
+		t.Errorf("unexpected log entry %v", entry)
+	}
+}
+
+func TestConsole(t *testing.T) {
+	var output bytes.Buffer
+	logger := New(Options{Format: FormatConsole, Output: &output})
+
+	logger.Info("created RecyclePolicy", KeyPolicy, "recycle-deployments")
+	logger.WithValues(KeyItem, "foo").Error(errors.New("not found"), "failed to get RecycleItem")
+	logger.V(1).Info("hidden")
+
+	want := "✓ created RecyclePolicy policy=recycle-deployments\n" +
+		"✗ failed to get RecycleItem: not found item=foo\n"
+	if got := output.String(); got != want {
+		t.Errorf("unexpected console output:\n%s\nwant:\n%s", got, want)
+	}
+}
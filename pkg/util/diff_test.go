@@ -0,0 +1,71 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := func(lines ...string) string {
+		return strings.Join(lines, "\n") + "\n"
+	}
+
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "equal",
+			a:    lines("a", "b"),
+			b:    lines("a", "b"),
+			want: "",
+		},
+		{
+			name: "created",
+			a:    "",
+			b:    lines("a", "b"),
+			want: lines("--- live", "+++ recycled", "@@ -0,0 +1,2 @@", "+a", "+b"),
+		},
+		{
+			name: "changed",
+			a:    lines("1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"),
+			b:    lines("1", "2", "3", "4", "five", "6", "7", "8", "9", "10", "11", "12", "13"),
+			want: lines(
+				"--- live", "+++ recycled",
+				"@@ -2,7 +2,7 @@", " 2", " 3", " 4", "-5", "+five", " 6", " 7", " 8",
+				"@@ -10,3 +10,4 @@", " 10", " 11", " 12", "+13",
+			),
+		},
+		{
+			name: "close changes share a hunk",
+			a:    lines("1", "2", "3", "4", "5", "6", "7", "8"),
+			b:    lines("one", "2", "3", "4", "5", "6", "7", "eight"),
+			want: lines("--- live", "+++ recycled", "@@ -1,8 +1,8 @@", "-1", "+one", " 2", " 3", " 4", " 5", " 6", " 7", "-8", "+eight"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnifiedDiff("live", "recycled", tt.a, tt.b); got != tt.want {
+				t.Errorf("✗ unexpected diff\n got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+	krbclient "github.com/wcrum/kube-recycle-bin/internal/client"
+	"github.com/wcrum/kube-recycle-bin/internal/restore"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// serverServiceAccount is the ServiceAccount krb-server runs as in manifests/deploy.yaml.
+	serverServiceAccount = "krb-server"
+	// serverNamespace is the namespace of serverServiceAccount.
+	serverNamespace = "krb-system"
+)
+
+// manifestClusterRole returns the ClusterRole of the name in the manifests file.
+func manifestClusterRole(t *testing.T, manifests, name string) *rbacv1.ClusterRole {
+	t.Helper()
+
+	file, err := os.Open(filepath.Join("..", "..", "manifests", manifests))
+	if err != nil {
+		t.Fatalf("✗ failed to open manifests: %v", err)
+	}
+	defer file.Close()
+
+	decoder := utilyaml.NewYAMLOrJSONDecoder(file, 4096)
+	for {
+		var clusterRole rbacv1.ClusterRole
+		if err := decoder.Decode(&clusterRole); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatalf("✗ failed to decode manifests: %v", err)
+		}
+		if clusterRole.Kind == "ClusterRole" && clusterRole.Name == name {
+			return &clusterRole
+		}
+	}
+	t.Fatalf("✗ ClusterRole %s not found in %s", name, manifests)
+	return nil
+}
+
+// serverClients returns clients impersonating the ServiceAccount of krb-server, bound to the
+// ClusterRoles for the test, once the api server authorizes the patch of configmaps as the
+// roles do.
+func serverClients(t *testing.T, clusterRoles ...*rbacv1.ClusterRole) *krbclient.Clients {
+	t.Helper()
+
+	ctx := context.Background()
+	rbac := clients.Kubernetes.RbacV1()
+	var patch bool
+	for _, clusterRole := range clusterRoles {
+		clusterRole.ResourceVersion = ""
+		if _, err := rbac.ClusterRoles().Create(ctx, clusterRole, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("✗ failed to create ClusterRole: %v", err)
+		}
+		t.Cleanup(func() {
+			rbac.ClusterRoles().Delete(context.Background(), clusterRole.Name, metav1.DeleteOptions{})
+		})
+		if _, err := rbac.ClusterRoleBindings().Create(ctx, &rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: clusterRole.Name},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: clusterRole.Name},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: serverServiceAccount, Namespace: serverNamespace}},
+		}, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("✗ failed to create ClusterRoleBinding: %v", err)
+		}
+		t.Cleanup(func() {
+			rbac.ClusterRoleBindings().Delete(context.Background(), clusterRole.Name, metav1.DeleteOptions{})
+		})
+		for _, rule := range clusterRole.Rules {
+			patch = patch || (slices.Contains(rule.Verbs, "patch") && slices.Contains(rule.Resources, "*"))
+		}
+	}
+
+	config := rest.CopyConfig(restConfig)
+	config.Impersonate = rest.ImpersonationConfig{UserName: "system:serviceaccount:" + serverNamespace + ":" + serverServiceAccount}
+	serverClients, err := krbclient.NewClients(config)
+	if err != nil {
+		t.Fatalf("✗ failed to create clients: %v", err)
+	}
+
+	// the api server authorizes the bindings once its RBAC informers observed them, and stops
+	// authorizing the ones of earlier tests once it observed their removal.
+	allowed := func(ctx context.Context, attributes *authorizationv1.ResourceAttributes) (bool, error) {
+		review, err := serverClients.Kubernetes.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: attributes},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return false, err
+		}
+		return review.Status.Allowed, nil
+	}
+	waitFor(t, timeout, func(ctx context.Context) (bool, error) {
+		updateStatus, err := allowed(ctx, &authorizationv1.ResourceAttributes{Group: api.GroupVersion.Group, Resource: "recycleitems", Subresource: "status", Verb: "update"})
+		if err != nil || !updateStatus {
+			return false, err
+		}
+		patchConfigMaps, err := allowed(ctx, &authorizationv1.ResourceAttributes{Resource: "configmaps", Verb: "patch"})
+		return patchConfigMaps == patch, err
+	})
+	return serverClients
+}
+
+// newConflictingItem returns the RecycleItem of a ConfigMap recycled in a new namespace, and
+// the ConfigMaps of the namespace, where a live ConfigMap of the same name was created since.
+func newConflictingItem(t *testing.T, policyName string) (*api.RecycleItem, typedcorev1.ConfigMapInterface) {
+	t.Helper()
+
+	namespace := newNamespace(t)
+	createRecyclePolicy(t, policyName, "", "configmaps", namespace)
+
+	configMaps := clients.Kubernetes.CoreV1().ConfigMaps(namespace)
+	recycleItem := recycle(t, func(ctx context.Context) (types.UID, error) {
+		configMap, err := configMaps.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "settings"},
+			Data:       map[string]string{"mode": "recycled"},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return "", err
+		}
+		return configMap.UID, nil
+	}, func(ctx context.Context) error {
+		return configMaps.Delete(ctx, "settings", metav1.DeleteOptions{})
+	})
+	if _, err := configMaps.Create(context.Background(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "settings"},
+		Data:       map[string]string{"mode": "live"},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("✗ failed to create conflicting ConfigMap: %v", err)
+	}
+	return recycleItem, configMaps
+}
+
+// TestRestoreWithServerRBAC restores an object next to an existing one with the permissions
+// krb-server is granted by default in the manifests, which do not let it overwrite the object.
+func TestRestoreWithServerRBAC(t *testing.T) {
+	requireCluster(t)
+	recycleItem, configMaps := newConflictingItem(t, "e2e-server-rbac")
+	restorer := restore.NewRestorer(serverClients(t, manifestClusterRole(t, "deploy.yaml", serverServiceAccount)), recorder, restore.DefaultSanitizer())
+
+	if _, err := restorer.Restore(context.Background(), recycleItem, restore.Options{OnConflict: restore.ConflictOverwrite}); !apierrors.IsForbidden(err) {
+		t.Fatalf("✗ expected the overwrite to be forbidden, got %v", err)
+	}
+	configMap, err := configMaps.Get(context.Background(), "settings", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("✗ failed to get live ConfigMap: %v", err)
+	}
+	if got := configMap.Data["mode"]; got != "live" {
+		t.Errorf("✗ expected the live ConfigMap untouched, got %q", got)
+	}
+
+	recycleItem, err = clients.RecycleItem().Get(context.Background(), recycleItem.Name, client.GetOptions{})
+	if err != nil {
+		t.Fatalf("✗ failed to get RecycleItem: %v", err)
+	}
+	result, err := restorer.Restore(context.Background(), recycleItem, restore.Options{OnConflict: restore.ConflictRename})
+	if err != nil {
+		t.Fatalf("✗ failed to restore RecycleItem as krb-server: %v", err)
+	}
+	if result.Outcome != restore.OutcomeRenamed {
+		t.Errorf("✗ expected outcome %s, got %s", restore.OutcomeRenamed, result.Outcome)
+	}
+}
+
+// TestRestoreOverwriteWithServerRBAC overwrites an existing object with the permissions
+// krb-server is granted by the manifests once overwrites are allowed.
+func TestRestoreOverwriteWithServerRBAC(t *testing.T) {
+	requireCluster(t)
+	recycleItem, configMaps := newConflictingItem(t, "e2e-server-rbac-overwrite")
+	serverClients := serverClients(t,
+		manifestClusterRole(t, "deploy.yaml", serverServiceAccount),
+		manifestClusterRole(t, "server-overwrite.yaml", serverServiceAccount+"-overwrite"),
+	)
+
+	result, err := restore.NewRestorer(serverClients, recorder, restore.DefaultSanitizer()).Restore(context.Background(), recycleItem, restore.Options{OnConflict: restore.ConflictOverwrite})
+	if err != nil {
+		t.Fatalf("✗ failed to restore RecycleItem as krb-server: %v", err)
+	}
+	if result.Outcome != restore.OutcomeOverwritten {
+		t.Errorf("✗ expected outcome %s, got %s", restore.OutcomeOverwritten, result.Outcome)
+	}
+	configMap, err := configMaps.Get(context.Background(), "settings", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("✗ failed to get restored ConfigMap: %v", err)
+	}
+	if got := configMap.Data["mode"]; got != "recycled" {
+		t.Errorf("✗ expected the recycled data to overwrite the live one, got %q", got)
+	}
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+	"github.com/wcrum/kube-recycle-bin/internal/restore"
+	"github.com/wcrum/kube-recycle-bin/pkg/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// timeout bounds the wait for the controller and the webhook to act.
+	timeout = 30 * time.Second
+	// recycleTimeout bounds the wait for the RecycleItem of a single delete, which is missed
+	// while the api server does not call the webhook of a new policy yet.
+	recycleTimeout = 5 * time.Second
+)
+
+// newNamespace creates a namespace for the test, deleted with it.
+func newNamespace(t *testing.T) string {
+	t.Helper()
+
+	namespace, err := clients.Kubernetes.CoreV1().Namespaces().Create(context.Background(), &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "e2e-"},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("✗ failed to create namespace: %v", err)
+	}
+	t.Cleanup(func() {
+		clients.Kubernetes.CoreV1().Namespaces().Delete(context.Background(), namespace.Name, metav1.DeleteOptions{})
+	})
+	return namespace.Name
+}
+
+// createRecyclePolicy creates a RecyclePolicy recycling the group/resource in the namespace,
+// deleted with the test, and waits for its webhook to be configured.
+func createRecyclePolicy(t *testing.T, name, group, resource, namespace string) {
+	t.Helper()
+
+	ctx := context.Background()
+	if err := clients.RecyclePolicy().Create(ctx, &api.RecyclePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Target: api.RecycleTarget{
+			Resources:  []api.RecycleResourceRule{{Group: group, Resource: resource}},
+			Namespaces: []string{namespace},
+		},
+	}, client.CreateOptions{}); err != nil {
+		t.Fatalf("✗ failed to create RecyclePolicy: %v", err)
+	}
+	t.Cleanup(func() {
+		clients.RecyclePolicy().Delete(context.Background(), name, client.DeleteOptions{})
+	})
+
+	waitFor(t, timeout, func(ctx context.Context) (bool, error) {
+		recyclePolicy, err := clients.RecyclePolicy().Get(ctx, name, client.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return meta.IsStatusConditionTrue(recyclePolicy.Status.Conditions, api.RecyclePolicyConditionWebhookConfigured), nil
+	})
+}
+
+// recycle creates and deletes an object until the delete is recycled, and returns the
+// RecycleItem of the deleted object. create returns the UID of the object it creates.
+func recycle(t *testing.T, create func(ctx context.Context) (types.UID, error), delete func(ctx context.Context) error) *api.RecycleItem {
+	t.Helper()
+
+	var recycleItem *api.RecycleItem
+	waitFor(t, timeout, func(ctx context.Context) (bool, error) {
+		uid, err := create(ctx)
+		if err != nil {
+			return false, err
+		}
+		if err := delete(ctx); err != nil {
+			return false, err
+		}
+
+		// RecycleItems are created in the background once the delete is admitted.
+		err = wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, recycleTimeout, true, func(ctx context.Context) (bool, error) {
+			list, err := clients.RecycleItem().List(ctx, client.ListOptions{
+				LabelSelector: labels.SelectorFromSet(labels.Set{api.ObjectUIDLabel: string(uid)}),
+			})
+			if err != nil || len(list.Items) == 0 {
+				return false, err
+			}
+			recycleItem = &list.Items[0]
+			return true, nil
+		})
+		return recycleItem != nil, nil
+	})
+	t.Cleanup(func() {
+		clients.RecycleItem().Delete(context.Background(), recycleItem.Name, client.DeleteOptions{})
+	})
+	return recycleItem
+}
+
+// waitFor waits for the condition to be met, and fails the test on timeout or errors.
+func waitFor(t *testing.T, timeout time.Duration, condition wait.ConditionWithContextFunc) {
+	t.Helper()
+
+	if err := wait.PollUntilContextTimeout(context.Background(), 200*time.Millisecond, timeout, true, condition); err != nil {
+		t.Fatalf("✗ condition not met: %v", err)
+	}
+}
+
+// restoreRecycleItem restores the object of the RecycleItem and checks the RecycleItem is
+// marked restored.
+func restoreRecycleItem(t *testing.T, recycleItem *api.RecycleItem) {
+	t.Helper()
+
+	ctx := context.Background()
+	if _, err := restore.NewRestorer(clients, recorder, restore.DefaultSanitizer()).Restore(ctx, recycleItem, restore.Options{}); err != nil {
+		t.Fatalf("✗ failed to restore RecycleItem: %v", err)
+	}
+	restored, err := clients.RecycleItem().Get(ctx, recycleItem.Name, client.GetOptions{})
+	if err != nil {
+		t.Fatalf("✗ failed to get RecycleItem: %v", err)
+	}
+	if restored.Phase() != api.RecycleItemRestored {
+		t.Errorf("✗ expected phase %s, got %s", api.RecycleItemRestored, restored.Phase())
+	}
+}
+
+func TestRecycleAndRestoreDeployment(t *testing.T) {
+	requireCluster(t)
+	namespace := newNamespace(t)
+	createRecyclePolicy(t, "e2e-deployments", "apps", "deployments", namespace)
+
+	deployments := clients.Kubernetes.AppsV1().Deployments(namespace)
+	recycleItem := recycle(t, func(ctx context.Context) (types.UID, error) {
+		deployment, err := deployments.Create(ctx, &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Labels: map[string]string{"app": "web"}},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: util.Ptr(int32(2)),
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}},
+					Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "nginx:1.27"}}},
+				},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return "", err
+		}
+		return deployment.UID, nil
+	}, func(ctx context.Context) error {
+		return deployments.Delete(ctx, "web", metav1.DeleteOptions{})
+	})
+
+	if got := recycleItem.Object.Key(); got != namespace+"/web" {
+		t.Errorf("✗ unexpected recycled object %q", got)
+	}
+	if got := recycleItem.RecyclePolicyName(); got != "e2e-deployments" {
+		t.Errorf("✗ unexpected RecyclePolicy %q", got)
+	}
+	if recycleItem.Deletion == nil || recycleItem.Deletion.DeletedBy.Username == "" {
+		t.Errorf("✗ expected the deletion to be recorded, got %+v", recycleItem.Deletion)
+	}
+
+	// a dry run is validated by the api server without persisting the deployment.
+	result, err := restore.NewRestorer(clients, recorder, restore.DefaultSanitizer()).Restore(context.Background(), recycleItem, restore.Options{DryRun: true})
+	if err != nil {
+		t.Fatalf("✗ failed to dry run restore: %v", err)
+	}
+	if result.Outcome != restore.OutcomeCreated {
+		t.Errorf("✗ expected outcome %s, got %s", restore.OutcomeCreated, result.Outcome)
+	}
+	if _, err := deployments.Get(context.Background(), "web", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("✗ expected the dry run not to restore the deployment, got %v", err)
+	}
+
+	restoreRecycleItem(t, recycleItem)
+	deployment, err := deployments.Get(context.Background(), "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("✗ failed to get restored deployment: %v", err)
+	}
+	if got := *deployment.Spec.Replicas; got != 2 {
+		t.Errorf("✗ expected 2 replicas, got %d", got)
+	}
+	if got := deployment.Spec.Template.Spec.Containers[0].Image; got != "nginx:1.27" {
+		t.Errorf("✗ unexpected image %q", got)
+	}
+}
+
+func TestRecycleAndRestoreSecret(t *testing.T) {
+	requireCluster(t)
+	namespace := newNamespace(t)
+	createRecyclePolicy(t, "e2e-secrets", "", "secrets", namespace)
+
+	secrets := clients.Kubernetes.CoreV1().Secrets(namespace)
+	recycleItem := recycle(t, func(ctx context.Context) (types.UID, error) {
+		secret, err := secrets.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-credentials"},
+			StringData: map[string]string{"password": "s3cr3t"},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return "", err
+		}
+		return secret.UID, nil
+	}, func(ctx context.Context) error {
+		return secrets.Delete(ctx, "db-credentials", metav1.DeleteOptions{})
+	})
+
+	if got := recycleItem.Object.Key(); got != namespace+"/db-credentials" {
+		t.Errorf("✗ unexpected recycled object %q", got)
+	}
+
+	restoreRecycleItem(t, recycleItem)
+	secret, err := secrets.Get(context.Background(), "db-credentials", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("✗ failed to get restored secret: %v", err)
+	}
+	if got := string(secret.Data["password"]); got != "s3cr3t" {
+		t.Errorf("✗ unexpected restored password %q", got)
+	}
+}
+
+func TestRestoreMovedGroupDropsFields(t *testing.T) {
+	requireCluster(t)
+	namespace := newNamespace(t)
+
+	// the api server no longer serves extensions/v1beta1, whose deployments had a rollbackTo and
+	// a selector defaulted to the labels of their template.
+	recycleItem := api.NewRecycleItem(&api.RecycledObject{
+		Group:     "extensions",
+		Version:   "v1beta1",
+		Resource:  "deployments",
+		Kind:      "Deployment",
+		Namespace: namespace,
+		Name:      "legacy",
+		Raw: []byte(`{"apiVersion":"extensions/v1beta1","kind":"Deployment","metadata":{"name":"legacy","namespace":"` + namespace + `"},` +
+			`"spec":{"rollbackTo":{"revision":1},` +
+			`"template":{"metadata":{"labels":{"app":"legacy"}},"spec":{"containers":[{"name":"web","image":"nginx:1.27"}]}}}}`),
+	}, types.UID("3e4f5a6b-7c8d-4e9f-8a0b-2c3d4e5f6a7b"))
+	ctx := context.Background()
+	if err := clients.RecycleItem().Create(ctx, recycleItem, client.CreateOptions{}); err != nil {
+		t.Fatalf("✗ failed to create RecycleItem: %v", err)
+	}
+	t.Cleanup(func() {
+		clients.RecycleItem().Delete(context.Background(), recycleItem.Name, client.DeleteOptions{})
+	})
+
+	result, err := restore.NewRestorer(clients, recorder, restore.DefaultSanitizer()).Restore(ctx, recycleItem, restore.Options{DryRun: true})
+	if err != nil {
+		t.Fatalf("✗ failed to dry run restore: %v", err)
+	}
+	if got := result.Manifest.GetAPIVersion(); got != "apps/v1" {
+		t.Errorf("✗ expected the deployment moved to apps/v1, got %s", got)
+	}
+	if len(result.DroppedFields) != 1 || result.DroppedFields[0] != "spec.rollbackTo" {
+		t.Errorf("✗ expected spec.rollbackTo to be dropped, got %v", result.DroppedFields)
+	}
+}
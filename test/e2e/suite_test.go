@@ -0,0 +1,215 @@
+/*
+Copyright 2025 The Ketches Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package e2e runs krb-controller and krb-webhook in-process against the api server and etcd
+// of envtest, and checks that deleted objects are recycled and restored.
+//
+// The suite needs the envtest binaries, it is skipped unless KUBEBUILDER_ASSETS points to
+// them, see make test-e2e.
+package e2e
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wcrum/kube-recycle-bin/internal/api"
+	krbclient "github.com/wcrum/kube-recycle-bin/internal/client"
+	"github.com/wcrum/kube-recycle-bin/internal/consts"
+	"github.com/wcrum/kube-recycle-bin/internal/controller"
+	"github.com/wcrum/kube-recycle-bin/internal/event"
+	"github.com/wcrum/kube-recycle-bin/internal/webhook"
+	"github.com/wcrum/kube-recycle-bin/pkg/logging"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	certutil "k8s.io/client-go/util/cert"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+var (
+	// clients are the clients of the envtest cluster, nil if the suite is skipped.
+	clients *krbclient.Clients
+	// restConfig is the admin config of the envtest cluster.
+	restConfig *rest.Config
+	// recorder records the Events of the restores of the tests.
+	recorder *event.Recorder
+	// skipReason tells why the suite is skipped.
+	skipReason string
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		skipReason = "KUBEBUILDER_ASSETS is not set, run make test-e2e"
+		os.Exit(m.Run())
+	}
+
+	logging.Setup(logging.Options{Format: logging.FormatText})
+	env := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "manifests", "crds.yaml")},
+		ErrorIfCRDPathMissing: true,
+	}
+	config, err := env.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start envtest: %v\n", err)
+		os.Exit(1)
+	}
+
+	restConfig = config
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = start(ctx, config)
+	code := 1
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start krb: %v\n", err)
+	} else {
+		code = m.Run()
+	}
+
+	cancel()
+	if err := env.Stop(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to stop envtest: %v\n", err)
+	}
+	os.Exit(code)
+}
+
+// requireCluster skips the test unless the envtest cluster is running.
+func requireCluster(t *testing.T) {
+	t.Helper()
+
+	if clients == nil {
+		t.Skip(skipReason)
+	}
+}
+
+// start runs krb-webhook and krb-controller against the cluster of the config until the
+// context is done.
+func start(ctx context.Context, config *rest.Config) error {
+	var err error
+	if clients, err = krbclient.NewClients(config); err != nil {
+		return err
+	}
+	recorder = event.NewRecorder(clients.Kubernetes, "krb-e2e")
+
+	webhookURL, err := startWebhook(ctx, config)
+	if err != nil {
+		return fmt.Errorf("failed to start webhook: %w", err)
+	}
+	if err := startController(ctx, config, webhookURL); err != nil {
+		return fmt.Errorf("failed to start controller: %w", err)
+	}
+	return nil
+}
+
+// startWebhook serves the webhook of the cluster of the config on a local port and returns
+// its URL. It serves a TLS secret issued for the local address, like cert-manager would.
+func startWebhook(ctx context.Context, config *rest.Config) (string, error) {
+	if _, err := clients.Kubernetes.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: consts.WebhookNamespace},
+	}, metav1.CreateOptions{}); err != nil {
+		return "", err
+	}
+	// the self-signed certificate is its own CA bundle.
+	certPEM, keyPEM, err := certutil.GenerateSelfSignedCertKey("127.0.0.1", []net.IP{net.ParseIP("127.0.0.1")}, nil)
+	if err != nil {
+		return "", err
+	}
+	if _, err := clients.Kubernetes.CoreV1().Secrets(consts.WebhookNamespace).Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: consts.WebhookTLSCertSecretName, Namespace: consts.WebhookNamespace},
+		Type:       corev1.SecretTypeTLS,
+		Data:       map[string][]byte{corev1.TLSCertKey: certPEM, corev1.TLSPrivateKeyKey: keyPEM},
+	}, metav1.CreateOptions{}); err != nil {
+		return "", err
+	}
+	certManager := webhook.NewCertManagerForCertManager(clients.Kubernetes)
+	if err := certManager.EnsureCertificates(ctx); err != nil {
+		return "", err
+	}
+
+	spoolDir, err := os.MkdirTemp("", "krb-spool-")
+	if err != nil {
+		return "", err
+	}
+	spool, err := webhook.NewSpool(spoolDir)
+	if err != nil {
+		return "", err
+	}
+	queue := webhook.NewRecycleQueue(spool, clients.RecycleItem(), event.NewRecorder(clients.Kubernetes, consts.WebhookName), 2, 100)
+	go queue.Start(ctx)
+
+	policyCache, err := webhook.StartPolicyCache(ctx, config)
+	if err != nil {
+		return "", err
+	}
+	server := httptest.NewUnstartedServer(webhook.NewWebhook(clients, policyCache, queue).Handler())
+	server.TLS = &tls.Config{GetCertificate: certManager.GetCertificate}
+	server.StartTLS()
+	go func() {
+		<-ctx.Done()
+		server.Close()
+		os.RemoveAll(spoolDir)
+	}()
+	return server.URL, nil
+}
+
+// startController runs the reconcilers of krb-controller, configuring the webhooks of the
+// policies at webhookURL.
+func startController(ctx context.Context, config *rest.Config, webhookURL string) error {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return err
+	}
+	if err := api.AddToScheme(scheme); err != nil {
+		return err
+	}
+
+	mgr, err := ctrl.NewManager(config, ctrl.Options{
+		Scheme:  scheme,
+		Metrics: metricsserver.Options{BindAddress: "0"},
+	})
+	if err != nil {
+		return err
+	}
+	if err := (&controller.RecyclePolicyReconciler{
+		Client:     mgr.GetClient(),
+		Scheme:     mgr.GetScheme(),
+		WebhookURL: webhookURL,
+	}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+	if err := (&controller.RecycleItemReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	go func() {
+		if err := mgr.Start(ctx); err != nil {
+			logging.Logger().Error(err, "manager stopped")
+		}
+	}()
+	return nil
+}
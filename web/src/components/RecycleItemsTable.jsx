@@ -40,6 +40,7 @@ function RecycleItemsTable({ items, onViewYAML, onRestore }) {
               <TableCell>API Version</TableCell>
               <TableCell>Kind</TableCell>
               <TableCell>Namespace</TableCell>
+              <TableCell>Phase</TableCell>
               <TableCell>Age</TableCell>
               <TableCell align="right">Actions</TableCell>
             </TableRow>
@@ -52,6 +53,7 @@ function RecycleItemsTable({ items, onViewYAML, onRestore }) {
                 <TableCell>{item.objectAPIVersion}</TableCell>
                 <TableCell>{item.objectKind}</TableCell>
                 <TableCell>{item.objectNamespace || '(cluster)'}</TableCell>
+                <TableCell title={item.lastRestoreError || ''}>{item.phase}</TableCell>
                 <TableCell>{formatAge(item.age)}</TableCell>
                 <TableCell align="right">
                   <Box sx={{ display: 'flex', gap: 1, justifyContent: 'flex-end' }}>
@@ -67,6 +69,7 @@ function RecycleItemsTable({ items, onViewYAML, onRestore }) {
                       size="small"
                       variant="contained"
                       color="success"
+                      disabled={item.phase === 'Restored'}
                       startIcon={<RestoreIcon />}
                       onClick={() => onRestore(item)}
                     >
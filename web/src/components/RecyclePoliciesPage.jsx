@@ -167,9 +167,10 @@ function RecyclePoliciesPage() {
                 <TableHead>
                   <TableRow>
                     <TableCell>Name</TableCell>
-                    <TableCell>Group</TableCell>
-                    <TableCell>Resource</TableCell>
+                    <TableCell>Resources</TableCell>
                     <TableCell>Namespaces</TableCell>
+                    <TableCell>Webhook</TableCell>
+                    <TableCell>Items</TableCell>
                     <TableCell>Age</TableCell>
                     <TableCell align="right">Actions</TableCell>
                   </TableRow>
@@ -178,8 +179,17 @@ function RecyclePoliciesPage() {
                   {policies.map((policy) => (
                     <TableRow key={policy.name} hover>
                       <TableCell>{policy.name}</TableCell>
-                      <TableCell>{policy.group || '(empty)'}</TableCell>
-                      <TableCell>{policy.resource}</TableCell>
+                      <TableCell>
+                        <Box sx={{ display: 'flex', gap: 0.5, flexWrap: 'wrap' }}>
+                          {(policy.resources && policy.resources.length > 0
+                            ? policy.resources
+                            : [{ group: policy.group, resource: policy.resource }]
+                          ).map((rule) => {
+                            const gr = rule.group ? `${rule.resource}.${rule.group}` : rule.resource
+                            return <Chip key={gr} label={gr} size="small" variant="outlined" />
+                          })}
+                        </Box>
+                      </TableCell>
                       <TableCell>
                         {policy.namespaces && policy.namespaces.length > 0 ? (
                           <Box sx={{ display: 'flex', gap: 0.5, flexWrap: 'wrap' }}>
@@ -193,6 +203,23 @@ function RecyclePoliciesPage() {
                           </Typography>
                         )}
                       </TableCell>
+                      <TableCell>
+                        {(() => {
+                          const condition = (policy.conditions || []).find((c) => c.type === 'WebhookConfigured')
+                          if (!condition) {
+                            return <Chip label="Unknown" size="small" />
+                          }
+                          return (
+                            <Chip
+                              label={condition.status === 'True' ? 'Configured' : 'Failed'}
+                              color={condition.status === 'True' ? 'success' : 'error'}
+                              title={condition.message}
+                              size="small"
+                            />
+                          )
+                        })()}
+                      </TableCell>
+                      <TableCell>{policy.itemCount ?? 0}</TableCell>
                       <TableCell>{formatAge(policy.age)}</TableCell>
                       <TableCell align="right">
                         <IconButton
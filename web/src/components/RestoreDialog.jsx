@@ -1,4 +1,4 @@
-import React from 'react'
+import React, { useState, useEffect } from 'react'
 import {
   Dialog,
   DialogTitle,
@@ -10,10 +10,85 @@ import {
   IconButton,
 } from '@mui/material'
 import { Close as CloseIcon, Restore as RestoreIcon } from '@mui/icons-material'
+import { Prism as SyntaxHighlighter } from 'react-syntax-highlighter'
+import { vscDarkPlus } from 'react-syntax-highlighter/dist/esm/styles/prism'
+import { oneLight } from 'react-syntax-highlighter/dist/esm/styles/prism'
+import { useTheme } from '@mui/material/styles'
+
+const API_BASE = '/api/v1'
 
 function RestoreDialog({ open, onClose, onConfirm, itemName }) {
+  const [diff, setDiff] = useState(null)
+  const [loading, setLoading] = useState(false)
+  const theme = useTheme()
+  const isDark = theme.palette.mode === 'dark'
+
+  useEffect(() => {
+    if (open && itemName) {
+      loadDiff()
+    } else {
+      setDiff(null)
+    }
+  }, [open, itemName])
+
+  const loadDiff = async () => {
+    setLoading(true)
+    try {
+      const response = await fetch(`${API_BASE}/recycle-items/${itemName}/diff`)
+      if (!response.ok) {
+        throw new Error(`Failed to load diff: ${response.statusText}`)
+      }
+      setDiff(await response.json())
+    } catch (err) {
+      setDiff({ error: err.message })
+      console.error('Error loading diff:', err)
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  const renderDiff = () => {
+    if (loading) {
+      return <Box sx={{ p: 2, textAlign: 'center' }}>Loading...</Box>
+    }
+    if (!diff) {
+      return null
+    }
+    if (diff.error) {
+      return <DialogContentText color="error">Error: {diff.error}</DialogContentText>
+    }
+    if (!diff.diff) {
+      return (
+        <DialogContentText>
+          <strong>{diff.objectKey}</strong> already matches the recycled object.
+        </DialogContentText>
+      )
+    }
+    return (
+      <>
+        <DialogContentText sx={{ mb: 1 }}>
+          {diff.liveExists
+            ? <>Changes to the live <strong>{diff.objectKey}</strong>:</>
+            : <><strong>{diff.objectKey}</strong> does not exist and will be created:</>}
+        </DialogContentText>
+        <SyntaxHighlighter
+          language="diff"
+          style={isDark ? vscDarkPlus : oneLight}
+          customStyle={{
+            margin: 0,
+            borderRadius: '4px',
+            fontSize: '13px',
+            maxHeight: '50vh',
+          }}
+        >
+          {diff.diff}
+        </SyntaxHighlighter>
+      </>
+    )
+  }
+
   return (
-    <Dialog open={open} onClose={onClose} maxWidth="sm" fullWidth>
+    <Dialog open={open} onClose={onClose} maxWidth="md" fullWidth>
       <DialogTitle>
         <Box sx={{ display: 'flex', justifyContent: 'space-between', alignItems: 'center' }}>
           Confirm Restore
@@ -26,6 +101,9 @@ function RestoreDialog({ open, onClose, onConfirm, itemName }) {
         <DialogContentText>
           Are you sure you want to restore <strong>{itemName}</strong>?
         </DialogContentText>
+        <Box sx={{ mt: 2, '& pre': { margin: 0, borderRadius: 1 } }}>
+          {renderDiff()}
+        </Box>
       </DialogContent>
       <DialogActions>
         <Button onClick={onClose}>Cancel</Button>
@@ -38,4 +116,3 @@ function RestoreDialog({ open, onClose, onConfirm, itemName }) {
 }
 
 export default RestoreDialog
-